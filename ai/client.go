@@ -2,22 +2,93 @@ package ai
 
 import (
 	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
 
+	"go.opentelemetry.io/otel"
 	"maragu.dev/gai"
 	openai "maragu.dev/gai-openai"
 )
 
+const (
+	chatCompleteModel = "llama3"
+	embedModel        = "mxbai-embed-large-v1-f16"
+	rerankModel       = "bge-reranker-v2-m3"
+)
+
+var tracer = otel.Tracer("app/ai")
+
 // Client wraps both a [gai.ChatCompleter] and a [gai.Embedder].
 type Client struct {
-	chatCompleter gai.ChatCompleter
-	embedder      gai.Embedder[float64]
-	log           *slog.Logger
+	chatCompleter        gai.ChatCompleter
+	chatCompleterBaseURL string
+	chatCompleterBreaker *breaker
+	chatCompleteTimeout  time.Duration
+	embedder             gai.Embedder[float64]
+	embedderBaseURL      string
+	embedderBreaker      *breaker
+	embedTimeout         time.Duration
+	httpClient           *http.Client
+	log                  *slog.Logger
+	maxAttempts          int
+	maxWait              time.Duration
+	rerankerBaseURL      string
+	rerankerBreaker      *breaker
+	rerankTimeout        time.Duration
+	retryBaseDelay       time.Duration
+	retryMaxDelay        time.Duration
+	schemaUnsupported    atomic.Bool
+	sem                  chan struct{}
 }
 
 type NewClientOptions struct {
+	// BreakerCooldown is how long calls to a model server fail fast with [ErrCircuitOpen] after its circuit opens,
+	// before a trial call is let through. Defaults to 30 seconds.
+	BreakerCooldown time.Duration
+
+	// BreakerThreshold is the number of consecutive failed calls to a model server that opens its circuit.
+	// Zero means circuits never open.
+	BreakerThreshold int
+
 	ChatCompleterBaseURL string
-	EmbedderBaseURL      string
-	Log                  *slog.Logger
+
+	// ChatCompleteTimeout is the maximum duration of each chat completion attempt, including reading the whole response.
+	// Zero means no timeout.
+	ChatCompleteTimeout time.Duration
+
+	EmbedderBaseURL string
+
+	// EmbedTimeout is the maximum duration of each embedding attempt. Zero means no timeout.
+	EmbedTimeout time.Duration
+
+	Log *slog.Logger
+
+	// MaxAttempts for calls to the model servers that fail with errors that could be transient,
+	// like timeouts, network errors, and server errors. Zero means 1, so no retries.
+	MaxAttempts int
+
+	// MaxConcurrency is the maximum number of concurrent calls to the model servers,
+	// shared between chat completion and embedding. Zero means no limit.
+	MaxConcurrency int
+
+	// MaxWait is how long a call waits for a free slot before failing with [ErrOverloaded].
+	// Zero means calls fail immediately if there's no free slot.
+	MaxWait time.Duration
+
+	// RerankerBaseURL of a reranker server with a rerank endpoint, like llama-server with a reranking model.
+	// If empty, [Client.Rerank] prompts the chat model for relevance scores instead.
+	RerankerBaseURL string
+
+	// RerankTimeout is the maximum duration of each call to the reranker server. Zero means no timeout.
+	RerankTimeout time.Duration
+
+	// RetryBaseDelay is the delay before the first retry, which doubles for each retry after that,
+	// with full jitter. Defaults to 250 milliseconds.
+	RetryBaseDelay time.Duration
+
+	// RetryMaxDelay caps the delay between retries. Defaults to 5 seconds.
+	RetryMaxDelay time.Duration
 }
 
 func NewClient(opts NewClientOptions) *Client {
@@ -25,13 +96,37 @@ func NewClient(opts NewClientOptions) *Client {
 		opts.Log = slog.New(slog.DiscardHandler)
 	}
 
+	if opts.MaxConcurrency < 0 {
+		panic("max concurrency cannot be negative")
+	}
+
+	if opts.MaxAttempts < 0 {
+		panic("max attempts cannot be negative")
+	}
+
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 1
+	}
+
+	if opts.RetryBaseDelay == 0 {
+		opts.RetryBaseDelay = 250 * time.Millisecond
+	}
+
+	if opts.RetryMaxDelay == 0 {
+		opts.RetryMaxDelay = 5 * time.Second
+	}
+
+	if opts.BreakerCooldown == 0 {
+		opts.BreakerCooldown = 30 * time.Second
+	}
+
 	c := openai.NewClient(openai.NewClientOptions{
 		BaseURL: opts.ChatCompleterBaseURL,
 		Log:     opts.Log,
 	})
 
 	cc := c.NewChatCompleter(openai.NewChatCompleterOptions{
-		Model: openai.ChatCompleteModel("llama3"),
+		Model: openai.ChatCompleteModel(chatCompleteModel),
 	})
 
 	c = openai.NewClient(openai.NewClientOptions{
@@ -41,12 +136,36 @@ func NewClient(opts NewClientOptions) *Client {
 
 	e := c.NewEmbedder(openai.NewEmbedderOptions{
 		Dimensions: 1024,
-		Model:      openai.EmbedModel("mxbai-embed-large-v1-f16"),
+		Model:      openai.EmbedModel(embedModel),
 	})
 
+	var sem chan struct{}
+	if opts.MaxConcurrency > 0 {
+		sem = make(chan struct{}, opts.MaxConcurrency)
+	}
+
+	newBreaker := func(server string) *breaker {
+		return &breaker{server: server, threshold: opts.BreakerThreshold, cooldown: opts.BreakerCooldown, log: opts.Log}
+	}
+
 	return &Client{
-		chatCompleter: cc,
-		embedder:      e,
-		log:           opts.Log,
+		chatCompleter:        cc,
+		chatCompleterBaseURL: opts.ChatCompleterBaseURL,
+		chatCompleterBreaker: newBreaker("chatCompleter"),
+		chatCompleteTimeout:  opts.ChatCompleteTimeout,
+		embedder:             e,
+		embedderBaseURL:      opts.EmbedderBaseURL,
+		embedderBreaker:      newBreaker("embedder"),
+		embedTimeout:         opts.EmbedTimeout,
+		httpClient:           &http.Client{Timeout: 10 * time.Second},
+		log:                  opts.Log,
+		maxAttempts:          opts.MaxAttempts,
+		maxWait:              opts.MaxWait,
+		rerankerBaseURL:      opts.RerankerBaseURL,
+		rerankerBreaker:      newBreaker("reranker"),
+		rerankTimeout:        opts.RerankTimeout,
+		retryBaseDelay:       opts.RetryBaseDelay,
+		retryMaxDelay:        opts.RetryMaxDelay,
+		sem:                  sem,
 	}
 }
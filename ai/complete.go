@@ -2,12 +2,120 @@ package ai
 
 import (
 	"context"
+	"iter"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"maragu.dev/gai"
+
+	"app/tracing"
 )
 
+// ChatComplete holds a concurrency slot until the response parts have been consumed, or the context is done.
+// The span for the call also ends when the response parts have been consumed.
+// Callers must range over the response parts, even just to stop right away, or cancel the context,
+// since a dropped response with a long-lived context keeps its slot and the underlying response stream forever.
+// Failed calls are retried until the response has produced its first part, see [NewClientOptions.MaxAttempts].
 func (c *Client) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
-	return c.chatCompleter.ChatComplete(ctx, req)
+	var promptTokens int
+	for _, m := range req.Messages {
+		for _, p := range m.Parts {
+			if p.Type == gai.MessagePartTypeText {
+				promptTokens += countTokens(p.Text())
+			}
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "ai.ChatComplete", trace.WithAttributes(
+		attribute.String("model", chatCompleteModel),
+		attribute.Int("messages", len(req.Messages)),
+		attribute.Int("tokens.prompt", promptTokens),
+	))
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		tracing.End(span, err)
+		return gai.ChatCompleteResponse{}, err
+	}
+	stop := context.AfterFunc(ctx, release)
+
+	start := time.Now()
+
+	// Each attempt has its own timeout for the whole response, and is retried until it has produced its first part,
+	// after which it can't be retried without the caller seeing parts twice
+	var cancel context.CancelFunc
+	var next func() (gai.MessagePart, error, bool)
+	var stopParts func()
+	var first gai.MessagePart
+	var hasFirst bool
+	err = c.call(ctx, c.chatCompleterBreaker, operationChatComplete, chatCompleteModel, func() error {
+		callCtx, callCancel := withTimeout(ctx, c.chatCompleteTimeout)
+
+		res, err := c.chatCompleter.ChatComplete(callCtx, req)
+		if err != nil {
+			callCancel()
+			return err
+		}
+
+		n, s := iter.Pull2(res.Parts())
+		part, err, ok := n()
+		if err != nil {
+			s()
+			callCancel()
+			return err
+		}
+
+		cancel, next, stopParts, first, hasFirst = callCancel, n, s, part, ok
+		return nil
+	})
+	if err != nil {
+		stop()
+		release()
+		callErrors.WithLabelValues(operationChatComplete, chatCompleteModel).Inc()
+		tracing.End(span, err)
+		return gai.ChatCompleteResponse{}, err
+	}
+
+	// Prompt tokens are only counted once the call has succeeded, so failed calls aren't counted
+	addTokens(ctx, operationChatComplete, chatCompleteModel, promptTokens, 0)
+
+	return gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		var completionTokens int
+		var failed error
+		defer func() {
+			stopParts()
+			cancel()
+			stop()
+			release()
+
+			span.SetAttributes(attribute.Int("tokens.completion", completionTokens))
+			tracing.End(span, failed)
+
+			callDuration.WithLabelValues(operationChatComplete, chatCompleteModel).Observe(time.Since(start).Seconds())
+			addTokens(ctx, operationChatComplete, chatCompleteModel, 0, completionTokens)
+			if failed != nil {
+				callErrors.WithLabelValues(operationChatComplete, chatCompleteModel).Inc()
+			}
+		}()
+
+		part, err, ok := first, error(nil), hasFirst
+		for ok {
+			if err != nil {
+				err = timeoutError(ctx, operationChatComplete, err)
+				c.chatCompleterBreaker.record(ctx, err)
+				failed = err
+			} else if part.Type == gai.MessagePartTypeText {
+				completionTokens += countTokens(part.Text())
+			}
+
+			if !yield(part, err) || err != nil {
+				return
+			}
+
+			part, err, ok = next()
+		}
+	}), nil
 }
 
 var _ gai.ChatCompleter = (*Client)(nil)
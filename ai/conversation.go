@@ -0,0 +1,27 @@
+package ai
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"app/tracing"
+)
+
+// CondenseQuery rephrases a follow-up question in a conversation to a standalone question, for retrieval.
+// The transcript is the conversation so far as text.
+func (c *Client) CondenseQuery(ctx context.Context, transcript, question string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.CondenseQuery", trace.WithAttributes(attribute.Int("transcript.length", len(transcript))))
+	defer func() { tracing.End(span, err) }()
+
+	return c.completeText(ctx, "condense-query", map[string]any{"Transcript": transcript, "Question": question})
+}
+
+// SummarizeConversation from the transcript, building on a previous summary of the messages before it, if any.
+func (c *Client) SummarizeConversation(ctx context.Context, previousSummary, transcript string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.SummarizeConversation", trace.WithAttributes(attribute.Int("transcript.length", len(transcript))))
+	defer func() { tracing.End(span, err) }()
+
+	return c.completeText(ctx, "summarize-conversation", map[string]any{"PreviousSummary": previousSummary, "Transcript": transcript})
+}
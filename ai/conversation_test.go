@@ -0,0 +1,29 @@
+package ai_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/aitest"
+)
+
+func TestClient_CondenseQuery(t *testing.T) {
+	t.Run("rephrases a follow-up question to a standalone question", func(t *testing.T) {
+		c := aitest.NewClient(t)
+
+		query, err := c.CondenseQuery(t.Context(), "User: What do sheep eat?\nAssistant: Grass.", "And goats?")
+		is.NotError(t, err)
+		is.True(t, len(query) > 0)
+	})
+}
+
+func TestClient_SummarizeConversation(t *testing.T) {
+	t.Run("summarizes a conversation", func(t *testing.T) {
+		c := aitest.NewClient(t)
+
+		summary, err := c.SummarizeConversation(t.Context(), "", "User: What do sheep eat?\nAssistant: Grass.")
+		is.NotError(t, err)
+		is.True(t, len(summary) > 0)
+	})
+}
@@ -4,18 +4,56 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"io"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
 	"maragu.dev/gai"
+
+	"app/tracing"
 )
 
 // Embed to a binary vector.
 // See https://huggingface.co/blog/embedding-quantization for details on binary embeddings.
-func (c *Client) Embed(ctx context.Context, req gai.EmbedRequest) (gai.EmbedResponse[float32], error) {
-	res, err := c.embedder.Embed(ctx, req)
+func (c *Client) Embed(ctx context.Context, req gai.EmbedRequest) (_ gai.EmbedResponse[float32], err error) {
+	ctx, span := tracer.Start(ctx, "ai.Embed", trace.WithAttributes(attribute.String("model", embedModel)))
+	defer func() { tracing.End(span, err) }()
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return gai.EmbedResponse[float32]{}, err
+	}
+	defer release()
+
+	// Read the input so we can count the tokens in it
+	input, err := io.ReadAll(req.Input)
+	if err != nil {
+		return gai.EmbedResponse[float32]{}, errors.Wrap(err, "error reading input")
+	}
+	tokens := countTokens(string(input))
+	span.SetAttributes(attribute.Int("input.length", len(input)), attribute.Int("tokens.prompt", tokens))
+
+	start := time.Now()
+	var res gai.EmbedResponse[float64]
+	err = c.call(ctx, c.embedderBreaker, operationEmbed, embedModel, func() error {
+		ctx, cancel := withTimeout(ctx, c.embedTimeout)
+		defer cancel()
+
+		// The input is read again for every attempt
+		req.Input = bytes.NewReader(input)
+		var err error
+		res, err = c.embedder.Embed(ctx, req)
+		return err
+	})
+	callDuration.WithLabelValues(operationEmbed, embedModel).Observe(time.Since(start).Seconds())
 	if err != nil {
+		callErrors.WithLabelValues(operationEmbed, embedModel).Inc()
 		return gai.EmbedResponse[float32]{}, err
 	}
+	addTokens(ctx, operationEmbed, embedModel, tokens, 0)
 
 	var embedding []float32
 	for _, v := range res.Embedding {
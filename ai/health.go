@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"maragu.dev/errors"
+)
+
+// PingChatCompleter checks that the chat completion server responds.
+func (c *Client) PingChatCompleter(ctx context.Context) error {
+	return c.ping(ctx, c.chatCompleterBaseURL)
+}
+
+// PingEmbedder checks that the embedding server responds.
+func (c *Client) PingEmbedder(ctx context.Context) error {
+	return c.ping(ctx, c.embedderBaseURL)
+}
+
+// ping the OpenAI-compatible models endpoint at the given base URL.
+// Any response that is not a server error counts as the server being up.
+func (c *Client) ping(ctx context.Context, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/models", nil)
+	if err != nil {
+		return errors.Wrap(err, "error creating request")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error pinging %v", baseURL)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return errors.Newf("error pinging %v, got status %v", baseURL, res.StatusCode)
+	}
+
+	return nil
+}
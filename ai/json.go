@@ -0,0 +1,350 @@
+package ai
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+
+	"app/tracing"
+)
+
+// ErrInvalidJSON is returned by [CompleteJSON] when the output isn't valid JSON for the type, after all attempts.
+// The returned error is a [*JSONError], which has the details.
+var ErrInvalidJSON = errors.New("invalid JSON output")
+
+// errSchemaUnsupported is returned by [Client.ChatCompleteWithSchema] when the chat completion server
+// doesn't support constraining the output to a JSON schema.
+var errSchemaUnsupported = errors.New("JSON schema response format not supported")
+
+// JSONError is returned by [CompleteJSON] when it gives up on getting valid JSON output. It matches [ErrInvalidJSON].
+type JSONError struct {
+	// Attempts is the number of completions that were tried.
+	Attempts int
+
+	// Output of the last attempt.
+	Output string
+
+	// Err is why the output of the last attempt is invalid.
+	Err error
+}
+
+func (e *JSONError) Error() string {
+	return fmt.Sprintf("%v after %v attempts: %v", ErrInvalidJSON, e.Attempts, e.Err)
+}
+
+func (e *JSONError) Is(target error) bool {
+	return target == ErrInvalidJSON
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// schemaChatCompleter can constrain chat completion output to a JSON schema, like [Client].
+type schemaChatCompleter interface {
+	ChatCompleteWithSchema(ctx context.Context, req gai.ChatCompleteRequest, s Schema) (gai.ChatCompleteResponse, error)
+}
+
+type CompleteJSONOptions struct {
+	// MaxAttempts is the maximum number of completions, where attempts after the first ask the model
+	// to correct its invalid output. Defaults to 3.
+	MaxAttempts int
+}
+
+const (
+	// defaultJSONMaxAttempts is the default for [CompleteJSONOptions.MaxAttempts].
+	defaultJSONMaxAttempts = 3
+
+	jsonInstructions = `
+
+Answer with JSON only, without any other text, matching this JSON schema:
+%v`
+
+	jsonCorrection = `Your answer is not valid: %v
+Answer again with JSON only, without any other text, matching the JSON schema.`
+)
+
+// CompleteJSON completes the prompt with the chat completer, and unmarshals the JSON output into a T.
+// The JSON schema of T from [SchemaFor] is added to the prompt, and the output is constrained to the schema
+// if the chat completer supports it, like [Client] with llama-server.
+// The output is validated against the schema. Invalid output is repaired if it's just surrounded by other text,
+// like Markdown code fences, and otherwise the model is asked to correct it, up to [CompleteJSONOptions.MaxAttempts].
+// When it gives up, the error is a [*JSONError], which matches [ErrInvalidJSON].
+func CompleteJSON[T any](ctx context.Context, cc gai.ChatCompleter, prompt string, opts CompleteJSONOptions) (_ T, err error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultJSONMaxAttempts
+	}
+
+	var v T
+	schema := SchemaFor[T]()
+
+	ctx, span := tracer.Start(ctx, "ai.CompleteJSON", trace.WithAttributes(attribute.String("type", fmt.Sprintf("%T", v))))
+	defer func() { tracing.End(span, err) }()
+
+	messages := []gai.Message{gai.NewUserTextMessage(prompt + fmt.Sprintf(jsonInstructions, schema))}
+
+	var output string
+	var invalid error
+	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		span.SetAttributes(attribute.Int("attempts", attempt))
+
+		output, err = completeJSONOutput(ctx, cc, messages, schema)
+		if err != nil {
+			return v, err
+		}
+
+		if invalid = unmarshalJSON(output, schema, &v); invalid == nil {
+			return v, nil
+		}
+
+		messages = append(messages,
+			gai.Message{Role: gai.MessageRoleModel, Parts: []gai.MessagePart{gai.TextMessagePart(output)}},
+			gai.NewUserTextMessage(fmt.Sprintf(jsonCorrection, invalid)),
+		)
+	}
+
+	return v, &JSONError{Attempts: opts.MaxAttempts, Output: output, Err: invalid}
+}
+
+// completeJSONOutput of the messages, constrained to the schema if the chat completer supports it.
+func completeJSONOutput(ctx context.Context, cc gai.ChatCompleter, messages []gai.Message, schema Schema) (string, error) {
+	req := gai.ChatCompleteRequest{
+		Messages:    messages,
+		Temperature: gai.Ptr(gai.Temperature(0)),
+	}
+
+	var res gai.ChatCompleteResponse
+	var err error
+	sc, ok := cc.(schemaChatCompleter)
+	if ok {
+		res, err = sc.ChatCompleteWithSchema(ctx, req, schema)
+	}
+	if !ok || errors.Is(err, errSchemaUnsupported) {
+		res, err = cc.ChatComplete(ctx, req)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "error chat completing")
+	}
+
+	var output strings.Builder
+	for part, err := range res.Parts() {
+		if err != nil {
+			return "", errors.Wrap(err, "error reading chat completion")
+		}
+		if part.Type == gai.MessagePartTypeText {
+			output.WriteString(part.Text())
+		}
+	}
+
+	return strings.TrimSpace(output.String()), nil
+}
+
+// unmarshalJSON output into v after validating it against the schema.
+// If the output isn't valid JSON, the JSON object or array in it is tried instead,
+// because models sometimes wrap it in a Markdown code block or surround it with text.
+func unmarshalJSON(output string, schema Schema, v any) error {
+	var decoded any
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		output = extractJSON(output)
+		if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+			return errors.Wrap(err, "error parsing JSON")
+		}
+	}
+
+	defs, _ := schema["$defs"].(Schema)
+	if err := validate(schema, defs, decoded, ""); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(output), v); err != nil {
+		return errors.Wrap(err, "error unmarshalling JSON")
+	}
+
+	return nil
+}
+
+// extractJSON object or array from the output, from the first opening brace or bracket to the last closing one.
+func extractJSON(output string) string {
+	start := strings.IndexAny(output, "{[")
+	if start < 0 {
+		return output
+	}
+
+	closing := "}"
+	if output[start] == '[' {
+		closing = "]"
+	}
+
+	end := strings.LastIndex(output, closing)
+	if end < start {
+		return output
+	}
+	return output[start : end+1]
+}
+
+type schemaChatCompleteRequest struct {
+	Model          string               `json:"model"`
+	Messages       []schemaChatMessage  `json:"messages"`
+	Temperature    *float64             `json:"temperature,omitempty"`
+	ResponseFormat schemaResponseFormat `json:"response_format"`
+}
+
+type schemaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type schemaResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string `json:"name"`
+		Strict bool   `json:"strict"`
+		Schema Schema `json:"schema"`
+	} `json:"json_schema"`
+}
+
+type schemaChatCompleteResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage reportedUsage `json:"usage"`
+}
+
+// ChatCompleteWithSchema is like [Client.ChatComplete], but constrains the output to the JSON schema,
+// with the response format of the OpenAI-compatible chat completions endpoint, which llama-server turns into a grammar.
+// The response isn't streamed, so it has a single text part.
+// If the server rejects the response format, later calls fail fast with an error, so the caller can fall back to
+// unconstrained completion.
+func (c *Client) ChatCompleteWithSchema(ctx context.Context, req gai.ChatCompleteRequest, s Schema) (_ gai.ChatCompleteResponse, err error) {
+	if c.schemaUnsupported.Load() {
+		return gai.ChatCompleteResponse{}, errSchemaUnsupported
+	}
+
+	ctx, span := tracer.Start(ctx, "ai.ChatCompleteWithSchema", trace.WithAttributes(
+		attribute.String("model", chatCompleteModel),
+		attribute.Int("messages", len(req.Messages)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	body := schemaChatCompleteRequest{Model: chatCompleteModel}
+	if req.Temperature != nil {
+		t := float64(*req.Temperature)
+		body.Temperature = &t
+	}
+	body.ResponseFormat.Type = "json_schema"
+	body.ResponseFormat.JSONSchema.Name = "response"
+	body.ResponseFormat.JSONSchema.Strict = true
+	body.ResponseFormat.JSONSchema.Schema = s
+
+	var promptTokens int
+	for _, m := range req.Messages {
+		role := "user"
+		if m.Role == gai.MessageRoleModel {
+			role = "assistant"
+		}
+
+		var content strings.Builder
+		for _, p := range m.Parts {
+			if p.Type == gai.MessagePartTypeText {
+				content.WriteString(p.Text())
+			}
+		}
+		promptTokens += countTokens(content.String())
+		body.Messages = append(body.Messages, schemaChatMessage{Role: role, Content: content.String()})
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return gai.ChatCompleteResponse{}, errors.Wrap(err, "error marshalling chat completion request")
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return gai.ChatCompleteResponse{}, err
+	}
+	defer release()
+
+	start := time.Now()
+	var output string
+	var usage reportedUsage
+	err = c.call(ctx, c.chatCompleterBreaker, operationChatComplete, chatCompleteModel, func() error {
+		ctx, cancel := withTimeout(ctx, c.chatCompleteTimeout)
+		defer cancel()
+
+		var err error
+		output, usage, err = c.postChatCompletion(ctx, b)
+		return err
+	})
+	callDuration.WithLabelValues(operationChatComplete, chatCompleteModel).Observe(time.Since(start).Seconds())
+	if err != nil {
+		if !errors.Is(err, errSchemaUnsupported) {
+			callErrors.WithLabelValues(operationChatComplete, chatCompleteModel).Inc()
+		}
+		return gai.ChatCompleteResponse{}, err
+	}
+
+	// Token counts reported by the server are exact, so they're preferred over the estimates
+	promptTokens = cmp.Or(usage.PromptTokens, promptTokens)
+	completionTokens := cmp.Or(usage.CompletionTokens, countTokens(output))
+	span.SetAttributes(attribute.Int("tokens.prompt", promptTokens), attribute.Int("tokens.completion", completionTokens))
+	addTokens(ctx, operationChatComplete, chatCompleteModel, promptTokens, completionTokens)
+
+	return gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		yield(gai.TextMessagePart(output), nil)
+	}), nil
+}
+
+// postChatCompletion to the chat completions endpoint of the chat completion server,
+// returning the message content and the token usage reported by the server, if any.
+// A HTTP 400 Bad Request that mentions the response format means the server doesn't support JSON schemas,
+// which is remembered. Other 400 errors, like a prompt that's too long, are about the particular request.
+func (c *Client) postChatCompletion(ctx context.Context, body []byte) (string, reportedUsage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.chatCompleterBaseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", reportedUsage{}, errors.Wrap(err, "error creating chat completion request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", reportedUsage{}, errors.Wrap(err, "error calling chat completer")
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode == http.StatusBadRequest {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+		if bytes.Contains(message, []byte("response_format")) || bytes.Contains(message, []byte("json_schema")) {
+			c.schemaUnsupported.Store(true)
+			c.log.InfoContext(ctx, "Chat completion server does not support JSON schema response format, falling back to prompting")
+			return "", reportedUsage{}, errSchemaUnsupported
+		}
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", reportedUsage{}, statusError{server: "chat completer", code: res.StatusCode}
+	}
+
+	var completion schemaChatCompleteResponse
+	if err := json.NewDecoder(res.Body).Decode(&completion); err != nil {
+		return "", reportedUsage{}, errors.Wrap(err, "error decoding chat completion response")
+	}
+
+	if len(completion.Choices) == 0 {
+		return "", reportedUsage{}, errors.New("no choices in chat completion response")
+	}
+
+	return completion.Choices[0].Message.Content, completion.Usage, nil
+}
@@ -0,0 +1,194 @@
+package ai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	"app/ai"
+)
+
+type chatCompleterMock struct {
+	outputs  []string
+	requests []gai.ChatCompleteRequest
+}
+
+func (c *chatCompleterMock) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	c.requests = append(c.requests, req)
+	output := c.outputs[min(len(c.requests), len(c.outputs))-1]
+	return gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		yield(gai.TextMessagePart(output), nil)
+	}), nil
+}
+
+type sentiment struct {
+	Label      string   `json:"label" enum:"positive,negative"`
+	Confidence float64  `json:"confidence"`
+	Reasons    []string `json:"reasons,omitempty"`
+}
+
+func TestSchemaFor(t *testing.T) {
+	t.Run("derives a JSON schema from a struct", func(t *testing.T) {
+		s := ai.SchemaFor[sentiment]()
+		is.Equal(t, `{"additionalProperties":false,"properties":{"confidence":{"type":"number"},"label":{"enum":["positive","negative"],"type":"string"},"reasons":{"items":{"type":"string"},"type":"array"}},"required":["label","confidence"],"type":"object"}`, s.String())
+	})
+
+	t.Run("defines recursive types once and references them", func(t *testing.T) {
+		s := ai.SchemaFor[topic]()
+		is.Equal(t, `{"$defs":{"topic":{"additionalProperties":false,"properties":{"name":{"type":"string"},"subtopics":{"items":{"$ref":"#/$defs/topic"},"type":"array"}},"required":["name","subtopics"],"type":"object"}},"additionalProperties":false,"properties":{"name":{"type":"string"},"subtopics":{"items":{"$ref":"#/$defs/topic"},"type":"array"}},"required":["name","subtopics"],"type":"object"}`, s.String())
+	})
+
+	t.Run("inlines the fields of embedded structs, with outer fields winning", func(t *testing.T) {
+		s := ai.SchemaFor[labelledSentiment]()
+		is.Equal(t, `{"additionalProperties":false,"properties":{"confidence":{"type":"number"},"label":{"type":"string"},"reasons":{"items":{"type":"string"},"type":"array"}},"required":["label","confidence"],"type":"object"}`, s.String())
+	})
+
+	t.Run("derives base64-encoded strings from byte slices", func(t *testing.T) {
+		s := ai.SchemaFor[attachment]()
+		is.Equal(t, `{"additionalProperties":false,"properties":{"data":{"contentEncoding":"base64","type":"string"},"name":{"type":"string"}},"required":["name","data"],"type":"object"}`, s.String())
+	})
+}
+
+type attachment struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+type topic struct {
+	Name      string  `json:"name"`
+	Subtopics []topic `json:"subtopics"`
+}
+
+type labelledSentiment struct {
+	sentiment
+	Label string `json:"label"`
+}
+
+func TestCompleteJSON(t *testing.T) {
+	t.Run("validates recursive types", func(t *testing.T) {
+		cc := &chatCompleterMock{outputs: []string{`{"name": "Animals", "subtopics": [{"name": "Sheep"}]}`, `{"name": "Animals", "subtopics": [{"name": "Sheep", "subtopics": []}]}`}}
+
+		v, err := ai.CompleteJSON[topic](t.Context(), cc, "List topics.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "Sheep", v.Subtopics[0].Name)
+		is.Equal(t, 2, len(cc.requests))
+	})
+
+	t.Run("validates byte slices as base64-encoded strings", func(t *testing.T) {
+		cc := &chatCompleterMock{outputs: []string{`{"name": "sheep.txt", "data": "not base64!"}`, `{"name": "sheep.txt", "data": "YmFh"}`}}
+
+		v, err := ai.CompleteJSON[attachment](t.Context(), cc, "Attach a sheep.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "baa", string(v.Data))
+		is.Equal(t, 2, len(cc.requests))
+		is.True(t, strings.Contains(cc.requests[1].Messages[2].Parts[0].Text(), "data must be base64-encoded"))
+	})
+
+	t.Run("unmarshals the output, with the schema in the prompt", func(t *testing.T) {
+		cc := &chatCompleterMock{outputs: []string{`{"label": "positive", "confidence": 0.9}`}}
+
+		s, err := ai.CompleteJSON[sentiment](t.Context(), cc, "Classify: I love sheep.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "positive", s.Label)
+		is.Equal(t, 0.9, s.Confidence)
+		is.Equal(t, 1, len(cc.requests))
+
+		prompt := cc.requests[0].Messages[0].Parts[0].Text()
+		is.True(t, strings.HasPrefix(prompt, "Classify: I love sheep."))
+		is.True(t, strings.HasSuffix(prompt, ai.SchemaFor[sentiment]().String()))
+	})
+
+	t.Run("repairs output surrounded by other text", func(t *testing.T) {
+		cc := &chatCompleterMock{outputs: []string{"Sure!\n```json\n{\"label\": \"negative\", \"confidence\": 1}\n```"}}
+
+		s, err := ai.CompleteJSON[sentiment](t.Context(), cc, "Classify: I hate rain.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "negative", s.Label)
+		is.Equal(t, 1, len(cc.requests))
+	})
+
+	t.Run("asks the model to correct invalid output", func(t *testing.T) {
+		cc := &chatCompleterMock{outputs: []string{
+			`{"label": "neutral", "confidence": 0.5}`,
+			`{"label": "positive", "confidence": 0.5}`,
+		}}
+
+		s, err := ai.CompleteJSON[sentiment](t.Context(), cc, "Classify: Sheep exist.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "positive", s.Label)
+		is.Equal(t, 2, len(cc.requests))
+
+		messages := cc.requests[1].Messages
+		is.Equal(t, 3, len(messages))
+		is.Equal(t, gai.MessageRoleModel, messages[1].Role)
+		is.True(t, strings.Contains(messages[2].Parts[0].Text(), "label must be one of positive, negative"))
+	})
+
+	t.Run("gives up with a JSON error after the max attempts", func(t *testing.T) {
+		cc := &chatCompleterMock{outputs: []string{`{"label": "positive"}`}}
+
+		_, err := ai.CompleteJSON[sentiment](t.Context(), cc, "Classify: Sheep exist.", ai.CompleteJSONOptions{MaxAttempts: 2})
+		is.Error(t, ai.ErrInvalidJSON, err)
+		is.Equal(t, 2, len(cc.requests))
+
+		var jsonErr *ai.JSONError
+		is.True(t, errors.As(err, &jsonErr))
+		is.Equal(t, 2, jsonErr.Attempts)
+		is.Equal(t, `{"label": "positive"}`, jsonErr.Output)
+		is.True(t, strings.Contains(jsonErr.Err.Error(), "missing required property confidence"))
+	})
+
+	t.Run("constrains the output to the schema with the chat completion server", func(t *testing.T) {
+		var body map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/v1/chat/completions", r.URL.Path)
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			_, _ = w.Write([]byte(`{"choices": [{"message": {"content": "{\"label\": \"positive\", \"confidence\": 0.8}"}}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{ChatCompleterBaseURL: server.URL + "/v1"})
+
+		s, err := ai.CompleteJSON[sentiment](t.Context(), c, "Classify: I love sheep.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "positive", s.Label)
+
+		responseFormat, _ := body["response_format"].(map[string]any)
+		is.Equal(t, "json_schema", responseFormat["type"])
+	})
+
+	t.Run("returns other bad requests as errors, and keeps using the schema", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			is.True(t, body["response_format"] != nil)
+
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": {"message": "the request exceeds the available context size"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"choices": [{"message": {"content": "{\"label\": \"positive\", \"confidence\": 0.8}"}}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{ChatCompleterBaseURL: server.URL})
+
+		_, err := ai.CompleteJSON[sentiment](t.Context(), c, "Classify: I love sheep.", ai.CompleteJSONOptions{})
+		is.True(t, err != nil)
+
+		s, err := ai.CompleteJSON[sentiment](t.Context(), c, "Classify: I love sheep.", ai.CompleteJSONOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "positive", s.Label)
+		is.Equal(t, int32(2), calls.Load())
+	})
+}
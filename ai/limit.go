@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"maragu.dev/errors"
+)
+
+// ErrOverloaded is returned when no free slot for a call to the model servers could be acquired in time.
+var ErrOverloaded = errors.New("model servers are overloaded")
+
+// acquire a slot for a call to the model servers, waiting at most [Client.maxWait].
+// The returned release function is safe to call more than once.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.sem == nil {
+		return func() {}, nil
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-c.sem
+		})
+	}
+
+	// Try without waiting first, so a zero max wait still lets calls through when there is room
+	select {
+	case c.sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if c.maxWait <= 0 {
+		c.log.InfoContext(ctx, "Model servers overloaded, rejecting call")
+		return nil, ErrOverloaded
+	}
+
+	timer := time.NewTimer(c.maxWait)
+	defer timer.Stop()
+
+	select {
+	case c.sem <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		c.log.InfoContext(ctx, "Model servers overloaded, rejecting call", "waited", c.maxWait)
+		return nil, ErrOverloaded
+	}
+}
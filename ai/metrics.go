@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "app_ai_call_duration_seconds",
+		Help:    "Duration of calls to the model servers.",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation", "model"})
+
+	callErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "app_ai_call_errors_total",
+		Help: "Number of failed calls to the model servers.",
+	}, []string{"operation", "model"})
+
+	callRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "app_ai_call_retries_total",
+		Help: "Number of retried calls to the model servers.",
+	}, []string{"operation", "model"})
+
+	tokenCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "app_ai_tokens_total",
+		Help: "Number of tokens sent to and received from the model servers, estimated with a naive word tokenizer.",
+	}, []string{"operation", "model", "type"})
+)
+
+const (
+	operationChatComplete = "chat_complete"
+	operationEmbed        = "embed"
+	operationRerank       = "rerank"
+)
+
+// countTokens in s, the same way as [gai.NaiveWordTokenizer].
+// It's only an estimate, because the model servers don't report token usage.
+func countTokens(s string) int {
+	return len(strings.Fields(s))
+}
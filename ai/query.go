@@ -0,0 +1,107 @@
+package ai
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+
+	"app/prompt"
+	"app/tracing"
+)
+
+// RewriteQuery into at most n search queries with the chat model, for retrieving with each of them.
+// The query itself is not included in the result.
+func (c *Client) RewriteQuery(ctx context.Context, query string, n int) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.RewriteQuery", trace.WithAttributes(attribute.Int("n", n)))
+	defer func() { tracing.End(span, err) }()
+
+	output, err := c.completeText(ctx, "rewrite-query", map[string]any{"Query": query, "N": n})
+	if err != nil {
+		return nil, err
+	}
+
+	queries := parseLines(output, n)
+	span.SetAttributes(attribute.Int("queries", len(queries)))
+	return queries, nil
+}
+
+// ExpandQuery with at most n synonyms and acronym expansions with the chat model, for full-text search.
+func (c *Client) ExpandQuery(ctx context.Context, query string, n int) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.ExpandQuery", trace.WithAttributes(attribute.Int("n", n)))
+	defer func() { tracing.End(span, err) }()
+
+	output, err := c.completeText(ctx, "expand-query", map[string]any{"Query": query, "N": n})
+	if err != nil {
+		return nil, err
+	}
+
+	terms := parseLines(output, n)
+	span.SetAttributes(attribute.Int("terms", len(terms)))
+	return terms, nil
+}
+
+// HypotheticalDocument that answers the query, generated with the chat model.
+// Embedding it instead of the query is called HyDE, see https://arxiv.org/abs/2212.10496.
+func (c *Client) HypotheticalDocument(ctx context.Context, query string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "ai.HypotheticalDocument")
+	defer func() { tracing.End(span, err) }()
+
+	return c.completeText(ctx, "hypothetical-document", map[string]any{"Query": query})
+}
+
+// completeText of the named prompt template rendered with the data, see [prompt.Render],
+// with [Client.ChatComplete], returning the trimmed text of the response.
+// The template ID is recorded on the span in the context.
+func (c *Client) completeText(ctx context.Context, name string, data any) (string, error) {
+	p, t, err := prompt.Render(ctx, name, data)
+	if err != nil {
+		return "", err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("prompt", t.ID()))
+
+	res, err := c.ChatComplete(ctx, gai.ChatCompleteRequest{
+		Messages:    []gai.Message{gai.NewUserTextMessage(p)},
+		Temperature: gai.Ptr(gai.Temperature(0)),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "error chat completing")
+	}
+
+	var output strings.Builder
+	for part, err := range res.Parts() {
+		if err != nil {
+			return "", errors.Wrap(err, "error reading chat completion")
+		}
+		if part.Type == gai.MessagePartTypeText {
+			output.WriteString(part.Text())
+		}
+	}
+
+	return strings.TrimSpace(output.String()), nil
+}
+
+var listMarkerPattern = regexp.MustCompile(`^(\d+[.)]|[-*•])\s*`)
+
+// parseLines of output into at most n distinct values, without list markers, quotes, and empty lines.
+func parseLines(output string, n int) []string {
+	var values []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(listMarkerPattern.ReplaceAllString(strings.TrimSpace(line), ""))
+		line = strings.Trim(line, `"'`)
+		if line == "" || seen[strings.ToLower(line)] {
+			continue
+		}
+		if len(values) == n {
+			break
+		}
+		seen[strings.ToLower(line)] = true
+		values = append(values, line)
+	}
+	return values
+}
@@ -0,0 +1,39 @@
+package ai_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/aitest"
+)
+
+func TestClient_RewriteQuery(t *testing.T) {
+	t.Run("rewrites a query into at most n queries", func(t *testing.T) {
+		c := aitest.NewClient(t)
+
+		queries, err := c.RewriteQuery(t.Context(), "Who was Ada Lovelace and what did Charles Babbage build?", 3)
+		is.NotError(t, err)
+		is.True(t, len(queries) > 0 && len(queries) <= 3)
+	})
+}
+
+func TestClient_ExpandQuery(t *testing.T) {
+	t.Run("expands a query into at most n terms", func(t *testing.T) {
+		c := aitest.NewClient(t)
+
+		terms, err := c.ExpandQuery(t.Context(), "NASA rocket", 5)
+		is.NotError(t, err)
+		is.True(t, len(terms) > 0 && len(terms) <= 5)
+	})
+}
+
+func TestClient_HypotheticalDocument(t *testing.T) {
+	t.Run("generates a passage that answers the query", func(t *testing.T) {
+		c := aitest.NewClient(t)
+
+		document, err := c.HypotheticalDocument(t.Context(), "What is the capital of Denmark?")
+		is.NotError(t, err)
+		is.True(t, len(document) > 0)
+	})
+}
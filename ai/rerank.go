@@ -0,0 +1,184 @@
+package ai
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+	"maragu.dev/errors"
+
+	"app/tracing"
+)
+
+const (
+	// maxRerankerDocuments is the maximum number of documents to rerank at once with a reranker server.
+	maxRerankerDocuments = 100
+
+	// maxChatRerankDocuments is the maximum number of documents to rerank at once with the chat model,
+	// which takes a chat completion per document.
+	maxChatRerankDocuments = 20
+)
+
+// MaxRerankDocuments is the maximum number of documents that should be passed to [Client.Rerank] at once.
+// It's lower without a reranker server, since each document is then scored with a chat completion.
+func (c *Client) MaxRerankDocuments() int {
+	if c.rerankerBaseURL != "" {
+		return maxRerankerDocuments
+	}
+	return maxChatRerankDocuments
+}
+
+// Rerank the documents by relevance to the query, returning a score for each document in the same order.
+// Higher scores are more relevant. With a reranker server, the scores are from the reranking model.
+// Otherwise, the chat model is prompted for a score from 0 to 10 for each document.
+func (c *Client) Rerank(ctx context.Context, query string, documents []string) (_ []float64, err error) {
+	ctx, span := tracer.Start(ctx, "ai.Rerank", trace.WithAttributes(
+		attribute.Int("documents", len(documents)),
+		attribute.Bool("reranker", c.rerankerBaseURL != ""),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	if c.rerankerBaseURL != "" {
+		return c.rerankWithReranker(ctx, query, documents)
+	}
+	return c.rerankWithChatCompleter(ctx, query, documents)
+}
+
+type rerankRequest struct {
+	Model     string   `json:"model"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+}
+
+type rerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+	Usage reportedUsage `json:"usage"`
+}
+
+// rerankWithReranker calls the rerank endpoint of the reranker server, as served by llama-server.
+func (c *Client) rerankWithReranker(ctx context.Context, query string, documents []string) ([]float64, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	body, err := json.Marshal(rerankRequest{Model: rerankModel, Query: query, Documents: documents})
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling rerank request")
+	}
+
+	start := time.Now()
+	var scores []float64
+	var usage reportedUsage
+	err = c.call(ctx, c.rerankerBreaker, operationRerank, rerankModel, func() error {
+		ctx, cancel := withTimeout(ctx, c.rerankTimeout)
+		defer cancel()
+
+		var err error
+		scores, usage, err = c.postRerank(ctx, body, len(documents))
+		return err
+	})
+	callDuration.WithLabelValues(operationRerank, rerankModel).Observe(time.Since(start).Seconds())
+	if err != nil {
+		callErrors.WithLabelValues(operationRerank, rerankModel).Inc()
+		return nil, err
+	}
+
+	// The query is paired with each document, so it's counted once per document if the server doesn't report usage
+	tokens := cmp.Or(usage.PromptTokens, countTokens(query)*len(documents)+countTokens(strings.Join(documents, " ")))
+	addTokens(ctx, operationRerank, rerankModel, tokens, 0)
+
+	return scores, nil
+}
+
+// postRerank to the rerank endpoint, returning a score for each of the n documents,
+// and the token usage reported by the server, if any.
+func (c *Client) postRerank(ctx context.Context, body []byte, n int) ([]float64, reportedUsage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.rerankerBaseURL, "/")+"/rerank", bytes.NewReader(body))
+	if err != nil {
+		return nil, reportedUsage{}, errors.Wrap(err, "error creating rerank request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, reportedUsage{}, errors.Wrap(err, "error calling reranker")
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, reportedUsage{}, statusError{server: "reranker", code: res.StatusCode}
+	}
+
+	var rerankRes rerankResponse
+	if err := json.NewDecoder(res.Body).Decode(&rerankRes); err != nil {
+		return nil, reportedUsage{}, errors.Wrap(err, "error decoding rerank response")
+	}
+
+	scores := make([]float64, n)
+	for _, r := range rerankRes.Results {
+		if r.Index < 0 || r.Index >= n {
+			return nil, reportedUsage{}, errors.Newf("invalid index %v in rerank response", r.Index)
+		}
+		scores[r.Index] = r.RelevanceScore
+	}
+
+	return scores, rerankRes.Usage, nil
+}
+
+// rerankWithChatCompleter by prompting the chat model for a relevance score for each document.
+// Documents are scored concurrently, at most as many at a time as the client has concurrency slots.
+// A response that isn't a number scores 0.
+func (c *Client) rerankWithChatCompleter(ctx context.Context, query string, documents []string) ([]float64, error) {
+	scores := make([]float64, len(documents))
+
+	eg, ctx := errgroup.WithContext(ctx)
+	if c.sem != nil {
+		eg.SetLimit(cap(c.sem))
+	}
+	for i, document := range documents {
+		eg.Go(func() error {
+			output, err := c.completeText(ctx, "rerank", map[string]any{"Query": query, "Passage": document})
+			if err != nil {
+				return err
+			}
+
+			scores[i] = parseScore(output)
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return scores, nil
+}
+
+var scorePattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// parseScore from the first number in the output, clamped to 0 to 10. Returns 0 if there is no number.
+func parseScore(output string) float64 {
+	score, err := strconv.ParseFloat(scorePattern.FindString(output), 64)
+	if err != nil {
+		return 0
+	}
+	return min(score, 10)
+}
@@ -0,0 +1,43 @@
+package ai_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/ai"
+)
+
+func TestClient_Rerank(t *testing.T) {
+	t.Run("returns scores from the reranker server in document order", func(t *testing.T) {
+		var body map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/v1/rerank", r.URL.Path)
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			_, _ = w.Write([]byte(`{"results": [{"index": 1, "relevance_score": 0.9}, {"index": 0, "relevance_score": 0.1}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL + "/v1"})
+
+		scores, err := c.Rerank(t.Context(), "fluffy animals", []string{"Tractors", "Sheep"})
+		is.NotError(t, err)
+		is.EqualSlice(t, []float64{0.1, 0.9}, scores)
+		is.Equal(t, "fluffy animals", body["query"])
+	})
+
+	t.Run("errors on reranker server errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL})
+
+		_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.True(t, err != nil)
+	})
+}
@@ -0,0 +1,258 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	openaiapi "github.com/openai/openai-go"
+	"maragu.dev/errors"
+)
+
+var (
+	// ErrCircuitOpen is returned when calls to a model server fail fast, because the calls before them failed.
+	ErrCircuitOpen = errors.New("model server is unavailable")
+
+	// ErrTimeout is returned when a call to a model server takes longer than its timeout.
+	ErrTimeout = errors.New("model server call timed out")
+)
+
+// CircuitState of the circuit breaker for a model server, see [Client.CircuitStates].
+type CircuitState string
+
+const (
+	// CircuitStateClosed lets calls through.
+	CircuitStateClosed = CircuitState("closed")
+
+	// CircuitStateOpen fails calls fast with [ErrCircuitOpen].
+	CircuitStateOpen = CircuitState("open")
+
+	// CircuitStateHalfOpen lets a single trial call through, which closes the circuit if it succeeds.
+	CircuitStateHalfOpen = CircuitState("half-open")
+)
+
+// breaker is a circuit breaker for a model server.
+// It opens after threshold consecutive failed calls, and lets a single trial call through after the cooldown.
+// A zero threshold means the breaker never opens.
+type breaker struct {
+	server    string
+	threshold int
+	cooldown  time.Duration
+	log       *slog.Logger
+
+	lock     sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// allow a call, or return [ErrCircuitOpen].
+func (b *breaker) allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+
+	if b.trial || time.Since(b.openedAt) < b.cooldown {
+		return errors.Wrap(ErrCircuitOpen, "%v", b.server)
+	}
+
+	b.trial = true
+	return nil
+}
+
+// record the result of a call. Only [retryable] errors count as failures,
+// because other errors mean the server responded. Calls ended by the caller count as neither.
+func (b *breaker) record(ctx context.Context, err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.trial = false
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil || !retryable(ctx, err) {
+		if !b.openedAt.IsZero() {
+			b.log.InfoContext(ctx, "Model server recovered, closing circuit", "server", b.server)
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+
+	b.failures++
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return
+	}
+
+	if b.openedAt.IsZero() {
+		b.log.InfoContext(ctx, "Model server failing, opening circuit", "server", b.server, "failures", b.failures, "cooldown", b.cooldown, "error", err)
+	} else {
+		b.log.InfoContext(ctx, "Model server still failing, keeping circuit open", "server", b.server, "error", err)
+	}
+	b.openedAt = time.Now()
+}
+
+// state of the circuit.
+func (b *breaker) state() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch {
+	case b.openedAt.IsZero():
+		return CircuitStateClosed
+	case b.trial || time.Since(b.openedAt) >= b.cooldown:
+		return CircuitStateHalfOpen
+	default:
+		return CircuitStateOpen
+	}
+}
+
+// CircuitStates of the model servers, by server name: chatCompleter, embedder, and reranker if there is one.
+func (c *Client) CircuitStates() map[string]CircuitState {
+	states := map[string]CircuitState{
+		c.chatCompleterBreaker.server: c.chatCompleterBreaker.state(),
+		c.embedderBreaker.server:      c.embedderBreaker.state(),
+	}
+	if c.rerankerBaseURL != "" {
+		states[c.rerankerBreaker.server] = c.rerankerBreaker.state()
+	}
+	return states
+}
+
+// statusError is returned when a model server responds with an unexpected HTTP status code.
+type statusError struct {
+	server string
+	code   int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("error calling %v, got status %v", e.server, e.code)
+}
+
+// retryable errors are the ones that could be transient problems with the model server:
+// timeouts, network errors, and HTTP 408 Request Timeout, 429 Too Many Requests, or server errors.
+// Other HTTP client errors, like a prompt that's too long for the model context, are not retryable,
+// and neither are errors after the context of the caller is done.
+// Other errors from the client library for the model servers are retried, because their cause isn't known.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr statusError
+	var apiErr *openaiapi.Error
+	switch {
+	case errors.Is(err, ErrOverloaded), errors.Is(err, ErrCircuitOpen), errors.Is(err, errSchemaUnsupported):
+		return false
+	case errors.As(err, &statusErr):
+		return retryableStatus(statusErr.code)
+	case errors.As(err, &apiErr):
+		return retryableStatus(apiErr.StatusCode)
+	default:
+		return true
+	}
+}
+
+// IsUnavailable is true for errors from model servers that are unavailable, like when they're down, overloaded,
+// or too slow, as opposed to errors from a particular call, like invalid output or a prompt that's too long.
+func IsUnavailable(err error) bool {
+	var statusErr statusError
+	var apiErr *openaiapi.Error
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrOverloaded), errors.Is(err, ErrTimeout):
+		return true
+	case errors.As(err, &statusErr):
+		return retryableStatus(statusErr.code)
+	case errors.As(err, &apiErr):
+		return retryableStatus(apiErr.StatusCode)
+	case errors.As(err, &netErr):
+		return true
+	default:
+		return false
+	}
+}
+
+// retryableStatus is true for HTTP status codes that could be from transient problems with the server.
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// call fn with retries of [retryable] errors up to the max attempts of the client,
+// with exponential backoff and full jitter between attempts, as long as the context deadline allows.
+// The circuit breaker is checked before and told the result after each attempt.
+func (c *Client) call(ctx context.Context, b *breaker, operation, model string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := b.allow(); err != nil {
+			c.log.InfoContext(ctx, "Model server unavailable, failing fast", "server", b.server, "operation", operation)
+			return err
+		}
+
+		err := timeoutError(ctx, operation, fn())
+		b.record(ctx, err)
+
+		if err == nil || attempt >= c.maxAttempts || !retryable(ctx, err) {
+			return err
+		}
+
+		delay := backoff(c.retryBaseDelay, c.retryMaxDelay, attempt)
+
+		// Don't retry if the caller would give up before the next attempt starts, like a request with a deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			c.log.InfoContext(ctx, "Not retrying model server call, deadline too close", "operation", operation, "attempt", attempt, "error", err)
+			return err
+		}
+
+		c.log.InfoContext(ctx, "Retrying model server call", "operation", operation, "attempt", attempt, "delay", delay, "error", err)
+		callRetries.WithLabelValues(operation, model).Inc()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// timeoutError returns [ErrTimeout] if err is from a per-call timeout, which is when the deadline is exceeded
+// while the context of the caller isn't done. Otherwise, err is returned as is.
+func timeoutError(ctx context.Context, operation string, err error) error {
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return errors.Wrap(ErrTimeout, "%v", operation)
+	}
+	return err
+}
+
+// withTimeout is like [context.WithTimeout], but without a timeout if it's zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// backoff before the attempt after the given one, exponential in the attempt number and capped at maxDelay,
+// with full jitter so clients retrying at the same time spread out.
+// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	d := base << min(attempt-1, 30)
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d + 1)
+}
@@ -0,0 +1,219 @@
+package ai_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maragu.dev/errors"
+	"maragu.dev/is"
+
+	"app/ai"
+)
+
+func TestClient_retries(t *testing.T) {
+	t.Run("retries server errors until a call succeeds", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			MaxAttempts:     3,
+			RerankerBaseURL: server.URL,
+			RetryBaseDelay:  time.Millisecond,
+		})
+
+		scores, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.NotError(t, err)
+		is.EqualSlice(t, []float64{0.9}, scores)
+		is.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("gives up after the max attempts", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			MaxAttempts:     2,
+			RerankerBaseURL: server.URL,
+			RetryBaseDelay:  time.Millisecond,
+		})
+
+		_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.True(t, err != nil)
+		is.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			MaxAttempts:     3,
+			RerankerBaseURL: server.URL,
+			RetryBaseDelay:  time.Millisecond,
+		})
+
+		_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.True(t, err != nil)
+		is.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("does not retry client errors from the embedder, and does not open the circuit", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "input is too large to process", "type": "invalid_request_error"}}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			BreakerThreshold: 2,
+			EmbedderBaseURL:  server.URL,
+			MaxAttempts:      3,
+			RetryBaseDelay:   time.Millisecond,
+		})
+
+		for range 3 {
+			_, err := c.EmbedString(t.Context(), "Sheep")
+			is.True(t, err != nil)
+			is.True(t, !errors.Is(err, ai.ErrCircuitOpen))
+		}
+		is.Equal(t, ai.CircuitStateClosed, c.CircuitStates()["embedder"])
+		is.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("does not retry if the context deadline is too close for another attempt", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			MaxAttempts:     3,
+			RerankerBaseURL: server.URL,
+			RetryBaseDelay:  24 * time.Hour,
+			RetryMaxDelay:   24 * time.Hour,
+		})
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+
+		_, err := c.Rerank(ctx, "fluffy animals", []string{"Sheep"})
+		is.True(t, err != nil)
+		is.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("times out slow calls", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(200 * time.Millisecond):
+			}
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			RerankerBaseURL: server.URL,
+			RerankTimeout:   10 * time.Millisecond,
+		})
+
+		_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.True(t, errors.Is(err, ai.ErrTimeout))
+	})
+}
+
+func TestClient_CircuitStates(t *testing.T) {
+	t.Run("opens the circuit after consecutive failures, fails fast, and closes it after a successful trial call", func(t *testing.T) {
+		var calls atomic.Int32
+		var down atomic.Bool
+		down.Store(true)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			if down.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{
+			BreakerCooldown:  50 * time.Millisecond,
+			BreakerThreshold: 2,
+			RerankerBaseURL:  server.URL,
+		})
+		is.Equal(t, ai.CircuitStateClosed, c.CircuitStates()["reranker"])
+
+		for range 2 {
+			_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+			is.True(t, err != nil)
+			is.True(t, !errors.Is(err, ai.ErrCircuitOpen))
+		}
+		is.Equal(t, ai.CircuitStateOpen, c.CircuitStates()["reranker"])
+		is.Equal(t, ai.CircuitStateClosed, c.CircuitStates()["chatCompleter"])
+
+		_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.True(t, errors.Is(err, ai.ErrCircuitOpen))
+		is.Equal(t, int32(2), calls.Load())
+
+		down.Store(false)
+		time.Sleep(60 * time.Millisecond)
+		is.Equal(t, ai.CircuitStateHalfOpen, c.CircuitStates()["reranker"])
+
+		scores, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.NotError(t, err)
+		is.EqualSlice(t, []float64{0.9}, scores)
+		is.Equal(t, ai.CircuitStateClosed, c.CircuitStates()["reranker"])
+	})
+}
+
+func TestIsUnavailable(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		status   int
+		expected bool
+	}{
+		{name: "is true for server errors", status: http.StatusServiceUnavailable, expected: true},
+		{name: "is true for too many requests", status: http.StatusTooManyRequests, expected: true},
+		{name: "is false for other client errors", status: http.StatusBadRequest, expected: false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			c := ai.NewClient(ai.NewClientOptions{MaxAttempts: 1, RerankerBaseURL: server.URL})
+
+			_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+			is.True(t, err != nil)
+			is.Equal(t, test.expected, ai.IsUnavailable(err))
+		})
+	}
+
+	t.Run("is true for open circuits", func(t *testing.T) {
+		is.True(t, ai.IsUnavailable(ai.ErrCircuitOpen))
+	})
+}
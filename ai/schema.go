@@ -0,0 +1,287 @@
+package ai
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema, see https://json-schema.org.
+type Schema map[string]any
+
+// SchemaFor the JSON representation of T.
+// Struct fields are named by their json tag, and are required unless they have the omitempty option.
+// Fields of embedded structs without a json tag are inlined, like encoding/json does.
+// Fields with an enum tag, like `enum:"positive,negative"`, only allow those values, or items with those values for slices.
+// Byte slices are base64-encoded strings, like encoding/json does.
+// Recursive types are defined once in $defs, and referenced with $ref.
+func SchemaFor[T any]() Schema {
+	b := schemaBuilder{defs: Schema{}, names: map[reflect.Type]string{}, visiting: map[reflect.Type]bool{}}
+	s := b.schemaOf(reflect.TypeFor[T]())
+	if len(b.defs) == 0 {
+		return s
+	}
+
+	// Inline the root if it's a reference itself, so it's still an object
+	if ref, ok := s["$ref"].(string); ok {
+		s = maps.Clone(b.defs[strings.TrimPrefix(ref, "#/$defs/")].(Schema))
+	}
+	s["$defs"] = b.defs
+	return s
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schemaBuilder keeps track of struct types while building a schema, so recursive types are only defined once.
+type schemaBuilder struct {
+	// defs of recursive types by their name
+	defs Schema
+	// names of recursive types in defs
+	names map[reflect.Type]string
+	// visiting struct types that are being built, which are recursive if they're seen again
+	visiting map[reflect.Type]bool
+}
+
+func (b *schemaBuilder) schemaOf(t reflect.Type) Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if b.visiting[t] {
+			return Schema{"$ref": "#/$defs/" + b.name(t)}
+		}
+		b.visiting[t] = true
+		defer delete(b.visiting, t)
+
+		properties := Schema{}
+		required := []string{}
+		for _, f := range structFields(t, nil) {
+			name := jsonName(f)
+			_, options, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+			s := b.schemaOf(f.Type)
+			if enum := f.Tag.Get("enum"); enum != "" {
+				// Enums of slices apply to the items
+				if items, ok := s["items"].(Schema); ok {
+					items["enum"] = strings.Split(enum, ",")
+				} else {
+					s["enum"] = strings.Split(enum, ",")
+				}
+			}
+			properties[name] = s
+
+			if !slices.Contains(strings.Split(options, ","), "omitempty") {
+				required = append(required, name)
+			}
+		}
+		s := Schema{"type": "object", "properties": properties, "required": required, "additionalProperties": false}
+
+		// The type referenced itself while it was being built
+		if name, ok := b.names[t]; ok {
+			b.defs[name] = s
+			return Schema{"$ref": "#/$defs/" + name}
+		}
+		return s
+	default:
+		return Schema{}
+	}
+}
+
+// name of the recursive type in defs, which is the type name, with a number if another type has the same name.
+func (b *schemaBuilder) name(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+
+	name := cmp.Or(t.Name(), "type")
+	for i := 2; slices.Contains(slices.Collect(maps.Values(b.names)), name); i++ {
+		name = fmt.Sprintf("%v%v", cmp.Or(t.Name(), "type"), i)
+	}
+	b.names[t] = name
+	return name
+}
+
+// structFields of t that are in its JSON representation, with the fields of embedded structs without a json tag inlined,
+// like encoding/json does. Fields of the outer struct win over embedded fields with the same name.
+// The inlining set has the embedded struct types being inlined, so embedding cycles stop.
+func structFields(t reflect.Type, inlining map[reflect.Type]bool) []reflect.StructField {
+	var fields, embedded []reflect.StructField
+	for i := range t.NumField() {
+		f := t.Field(i)
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		// Embedded structs are inlined even if their type is unexported, as long as their fields are exported
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if inlining[ft] {
+				continue
+			}
+			inlining = maps.Clone(inlining)
+			if inlining == nil {
+				inlining = map[reflect.Type]bool{}
+			}
+			inlining[ft] = true
+			embedded = append(embedded, structFields(ft, inlining)...)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+		fields = append(fields, f)
+	}
+
+	names := map[string]bool{}
+	for _, f := range fields {
+		names[jsonName(f)] = true
+	}
+	for _, f := range embedded {
+		if name := jsonName(f); !names[name] {
+			names[name] = true
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// jsonName of the struct field, from its json tag or else the field name.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return cmp.Or(name, f.Name)
+}
+
+// validate the decoded JSON value against the schema, for the subset of JSON schema that [SchemaFor] produces.
+// Properties that aren't in the schema are allowed, because they're ignored when unmarshalling.
+// References are looked up in the defs of the root schema.
+func validate(s, defs Schema, v any, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		s, _ = defs[strings.TrimPrefix(ref, "#/$defs/")].(Schema)
+	}
+
+	if enum, ok := s["enum"].([]string); ok {
+		if str, _ := v.(string); !slices.Contains(enum, str) {
+			return fmt.Errorf("%v must be one of %v", pathOrRoot(path), strings.Join(enum, ", "))
+		}
+	}
+
+	switch s["type"] {
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%v must be a boolean", pathOrRoot(path))
+		}
+
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%v must be a number", pathOrRoot(path))
+		}
+		if s["type"] == "integer" && n != float64(int64(n)) {
+			return fmt.Errorf("%v must be an integer", pathOrRoot(path))
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%v must be a string", pathOrRoot(path))
+		}
+		if s["contentEncoding"] == "base64" {
+			if _, err := base64.StdEncoding.DecodeString(str); err != nil {
+				return fmt.Errorf("%v must be base64-encoded", pathOrRoot(path))
+			}
+		}
+
+	case "array":
+		values, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%v must be an array", pathOrRoot(path))
+		}
+		items, _ := s["items"].(Schema)
+		for i, value := range values {
+			if err := validate(items, defs, value, fmt.Sprintf("%v[%v]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case "object":
+		object, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%v must be an object", pathOrRoot(path))
+		}
+
+		required, _ := s["required"].([]string)
+		for _, name := range required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%v is missing required property %v", pathOrRoot(path), name)
+			}
+		}
+
+		properties, _ := s["properties"].(Schema)
+		additional, _ := s["additionalProperties"].(Schema)
+		for name, value := range object {
+			propertySchema, ok := properties[name].(Schema)
+			if !ok {
+				propertySchema = additional
+			}
+			if propertySchema == nil {
+				continue
+			}
+			if err := validate(propertySchema, defs, value, strings.TrimPrefix(path+"."+name, ".")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "output"
+	}
+	return path
+}
+
+// String of the schema as JSON.
+func (s Schema) String() string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// Usage of tokens in calls to a model server for an operation, like chat completion or embedding.
+type Usage struct {
+	Operation        string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// UsageMeter adds up the [Usage] of all calls made with a context from [WithUsageMeter].
+type UsageMeter struct {
+	lock  sync.Mutex
+	usage map[[2]string]*Usage
+}
+
+type usageMeterContextKey struct{}
+
+// WithUsageMeter returns a context with a new [UsageMeter], which meters the token usage of calls made with the context.
+func WithUsageMeter(ctx context.Context) (context.Context, *UsageMeter) {
+	m := &UsageMeter{usage: map[[2]string]*Usage{}}
+	return context.WithValue(ctx, usageMeterContextKey{}, m), m
+}
+
+// Usage so far, one per operation and model, sorted by operation and model.
+func (m *UsageMeter) Usage() []Usage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	usage := make([]Usage, 0, len(m.usage))
+	for _, u := range m.usage {
+		usage = append(usage, *u)
+	}
+	slices.SortFunc(usage, func(a, b Usage) int {
+		return cmp.Or(cmp.Compare(a.Operation, b.Operation), cmp.Compare(a.Model, b.Model))
+	})
+	return usage
+}
+
+func (m *UsageMeter) add(operation, model string, promptTokens, completionTokens int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	u, ok := m.usage[[2]string{operation, model}]
+	if !ok {
+		u = &Usage{Operation: operation, Model: model}
+		m.usage[[2]string{operation, model}] = u
+	}
+	u.PromptTokens += promptTokens
+	u.CompletionTokens += completionTokens
+}
+
+// addTokens to the token metrics, and to the [UsageMeter] in the context if there is one.
+func addTokens(ctx context.Context, operation, model string, promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		tokenCount.WithLabelValues(operation, model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		tokenCount.WithLabelValues(operation, model, "completion").Add(float64(completionTokens))
+	}
+
+	if m, ok := ctx.Value(usageMeterContextKey{}).(*UsageMeter); ok {
+		m.add(operation, model, promptTokens, completionTokens)
+	}
+}
+
+// reportedUsage is the token usage in responses from OpenAI-compatible servers, like llama-server.
+type reportedUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
@@ -0,0 +1,66 @@
+package ai_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/ai"
+)
+
+func TestWithUsageMeter(t *testing.T) {
+	t.Run("meters token usage reported by the model server", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}], "usage": {"prompt_tokens": 7, "total_tokens": 7}}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL})
+
+		ctx, meter := ai.WithUsageMeter(t.Context())
+		_, err := c.Rerank(ctx, "fluffy animals", []string{"Sheep"})
+		is.NotError(t, err)
+		_, err = c.Rerank(ctx, "fluffy animals", []string{"Sheep"})
+		is.NotError(t, err)
+
+		usage := meter.Usage()
+		is.Equal(t, 1, len(usage))
+		is.Equal(t, "rerank", usage[0].Operation)
+		is.Equal(t, "bge-reranker-v2-m3", usage[0].Model)
+		is.Equal(t, 14, usage[0].PromptTokens)
+		is.Equal(t, 0, usage[0].CompletionTokens)
+	})
+
+	t.Run("estimates token usage if the model server does not report it", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}, {"index": 1, "relevance_score": 0.1}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL})
+
+		ctx, meter := ai.WithUsageMeter(t.Context())
+		_, err := c.Rerank(ctx, "fluffy animals", []string{"Sheep", "Big tractors"})
+		is.NotError(t, err)
+
+		usage := meter.Usage()
+		is.Equal(t, 1, len(usage))
+		is.Equal(t, 7, usage[0].PromptTokens)
+	})
+
+	t.Run("does not meter calls without a meter in the context", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}]}`))
+		}))
+		defer server.Close()
+
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL})
+
+		_, meter := ai.WithUsageMeter(t.Context())
+		_, err := c.Rerank(t.Context(), "fluffy animals", []string{"Sheep"})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(meter.Usage()))
+	})
+}
@@ -6,18 +6,25 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sync/errgroup"
 	"maragu.dev/env"
+	"maragu.dev/errors"
 
 	"app/ai"
 	"app/http"
+	"app/ingest"
+	"app/jobs"
+	"app/model"
 	"app/sql"
+	"app/tracing"
 )
 
 func main() {
-	// Set up a logger that is used throughout the app
-	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	// Set up a logger that is used throughout the app, with trace IDs when logging with a context
+	log := slog.New(tracing.NewLogHandler(slog.NewTextHandler(os.Stderr, nil)))
 
 	// Start the app, exit with a non-zero exit code on errors
 	if err := start(log); err != nil {
@@ -36,6 +43,20 @@ func start(log *slog.Logger) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
+	// Set up tracing, which is exported with OTLP or to stdout, depending on the configuration
+	tp, err := tracing.NewTracerProvider(ctx, tracing.NewTracerProviderOptions{
+		Exporter:    tracing.Exporter(env.GetStringOrDefault("TRACING_EXPORTER", "")),
+		ServiceName: env.GetStringOrDefault("TRACING_SERVICE_NAME", "app"),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tp.Shutdown(context.Background()); err != nil {
+			log.Info("Error shutting down tracer provider", "error", err)
+		}
+	}()
+
 	// Set up the database, which is injected as a dependency into the HTTP server
 	db := sql.NewDatabase(sql.NewDatabaseOptions{
 		Log:  log,
@@ -47,19 +68,127 @@ func start(log *slog.Logger) error {
 	if err := db.MigrateUp(ctx); err != nil {
 		return err
 	}
+	// Documents created before content hashes were stored need them for deduplication
+	if n, err := db.BackfillDocumentHashes(ctx); err != nil {
+		return err
+	} else if n > 0 {
+		log.Info("Backfilled document hashes", "count", n)
+	}
+	prometheus.MustRegister(db.Collector())
+
+	// Calls to the model servers, including retries, must fit in the write timeout of the HTTP server,
+	// so the default per-call timeouts are derived from it
+	writeTimeout := env.GetDurationOrDefault("SERVER_WRITE_TIMEOUT", time.Minute)
+	maxAttempts := env.GetIntOrDefault("AI_MAX_ATTEMPTS", 3)
 
 	// Set up the AI client for chat completion and embeddings
 	ai := ai.NewClient(ai.NewClientOptions{
 		Log:                  log,
+		BreakerCooldown:      env.GetDurationOrDefault("AI_BREAKER_COOLDOWN", 30*time.Second),
+		BreakerThreshold:     env.GetIntOrDefault("AI_BREAKER_THRESHOLD", 5),
 		ChatCompleterBaseURL: env.GetStringOrDefault("AI_CHAT_COMPLETER_BASE_URL", "http://localhost:8081/v1"),
+		ChatCompleteTimeout:  env.GetDurationOrDefault("AI_CHAT_COMPLETE_TIMEOUT", writeTimeout/2),
 		EmbedderBaseURL:      env.GetStringOrDefault("AI_EMBEDDER_BASE_URL", "http://localhost:8082/v1"),
+		EmbedTimeout:         env.GetDurationOrDefault("AI_EMBED_TIMEOUT", writeTimeout/time.Duration(2*max(maxAttempts, 1))),
+		MaxAttempts:          maxAttempts,
+		MaxConcurrency:       env.GetIntOrDefault("AI_MAX_CONCURRENCY", 4),
+		MaxWait:              env.GetDurationOrDefault("AI_MAX_WAIT", 10*time.Second),
+		RerankerBaseURL:      env.GetStringOrDefault("AI_RERANKER_BASE_URL", ""),
+		RerankTimeout:        env.GetDurationOrDefault("AI_RERANK_TIMEOUT", writeTimeout/time.Duration(2*max(maxAttempts, 1))),
+		RetryBaseDelay:       env.GetDurationOrDefault("AI_RETRY_BASE_DELAY", 250*time.Millisecond),
+		RetryMaxDelay:        env.GetDurationOrDefault("AI_RETRY_MAX_DELAY", 5*time.Second),
 	})
 
+	// Check that the model servers are reachable, but don't fail, because they may come up later
+	if err := ai.PingChatCompleter(ctx); err != nil {
+		log.Warn("Chat completion server is not reachable", "error", err)
+	}
+	if err := ai.PingEmbedder(ctx); err != nil {
+		log.Warn("Embedding server is not reachable", "error", err)
+	}
+
+	duplicatePolicy := model.DuplicatePolicy(env.GetStringOrDefault("DUPLICATE_POLICY", "allow"))
+	if !duplicatePolicy.Valid() {
+		return errors.New("DUPLICATE_POLICY must be allow, reject, or existing")
+	}
+
+	ungroundedPolicy := model.UngroundedPolicy(env.GetStringOrDefault("UNGROUNDED_POLICY", "allow"))
+	if !ungroundedPolicy.Valid() {
+		return errors.New("UNGROUNDED_POLICY must be allow, flag, or refuse")
+	}
+
 	// Set up the HTTP server, injecting the database, AI client, and logger
 	s := http.NewServer(http.NewServerOptions{
-		AI:  ai,
-		DB:  db,
-		Log: log,
+		AI:                ai,
+		DB:                db,
+		Log:               log,
+		AdminAPIKey:       env.GetStringOrDefault("ADMIN_API_KEY", ""),
+		Address:           env.GetStringOrDefault("SERVER_ADDRESS", ":8080"),
+		BasePath:          env.GetStringOrDefault("SERVER_BASE_PATH", ""),
+		BulkBatchSize:     env.GetIntOrDefault("BULK_BATCH_SIZE", 100),
+		BulkMaxBodyBytes:  int64(env.GetIntOrDefault("BULK_MAX_BODY_BYTES", 1024*1024*1024)),
+		DuplicatePolicy:   duplicatePolicy,
+		MaxBodyBytes:      int64(env.GetIntOrDefault("SERVER_MAX_BODY_BYTES", 10*1024*1024)),
+		ReadTimeout:       env.GetDurationOrDefault("SERVER_READ_TIMEOUT", 5*time.Second),
+		ReadHeaderTimeout: env.GetDurationOrDefault("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       env.GetDurationOrDefault("SERVER_IDLE_TIMEOUT", 5*time.Second),
+		ShutdownTimeout:   env.GetDurationOrDefault("SERVER_SHUTDOWN_TIMEOUT", time.Minute),
+		TLSCertFile:       env.GetStringOrDefault("SERVER_TLS_CERT_FILE", ""),
+		TLSKeyFile:        env.GetStringOrDefault("SERVER_TLS_KEY_FILE", ""),
+		TrustProxy:        env.GetBoolOrDefault("SERVER_TRUST_PROXY", false),
+		UngroundedPolicy:  ungroundedPolicy,
+		IngestRateLimit: http.RateLimitOptions{
+			PerMinute: env.GetIntOrDefault("RATE_LIMIT_INGEST_PER_MINUTE", 600),
+			Burst:     env.GetIntOrDefault("RATE_LIMIT_INGEST_BURST", 50),
+		},
+		SearchRateLimit: http.RateLimitOptions{
+			PerMinute: env.GetIntOrDefault("RATE_LIMIT_SEARCH_PER_MINUTE", 120),
+			Burst:     env.GetIntOrDefault("RATE_LIMIT_SEARCH_BURST", 20),
+		},
+		UsageQuota: http.QuotaOptions{
+			DefaultTokens: env.GetIntOrDefault("USAGE_QUOTA_TOKENS", 0),
+			Period:        env.GetDurationOrDefault("USAGE_QUOTA_PERIOD", 24*time.Hour),
+		},
+	})
+
+	// Set up the background job runner, which runs jobs like bulk ingestion from the queue in the database
+	runner := jobs.NewRunner(jobs.NewRunnerOptions{
+		DB:           db,
+		Log:          log,
+		PollInterval: env.GetDurationOrDefault("JOBS_POLL_INTERVAL", time.Second),
+	})
+	runner.Register(ingest.BulkJobName, ingest.BulkJob(db, ai, ingest.BulkOptions{
+		BatchSize:       env.GetIntOrDefault("BULK_BATCH_SIZE", 100),
+		DuplicatePolicy: duplicatePolicy,
+	}))
+
+	// Set up the trash purger, which permanently deletes documents after they've been in the trash for a while
+	purger := jobs.NewPurger(jobs.NewPurgerOptions{
+		DB:        db,
+		Log:       log,
+		Interval:  env.GetDurationOrDefault("TRASH_PURGE_INTERVAL", time.Hour),
+		Retention: env.GetDurationOrDefault("TRASH_RETENTION", 30*24*time.Hour),
+	})
+
+	// Set up the summarizer, which generates summaries of new and changed documents
+	summarizer := jobs.NewSummarizer(jobs.NewSummarizerOptions{
+		AI:          ai,
+		DB:          db,
+		Log:         log,
+		BatchSize:   env.GetIntOrDefault("SUMMARIZE_BATCH_SIZE", 10),
+		Interval:    env.GetDurationOrDefault("SUMMARIZE_INTERVAL", time.Minute),
+		MaxAttempts: env.GetIntOrDefault("SUMMARIZE_MAX_ATTEMPTS", 3),
+	})
+
+	// Set up the extractor, which extracts entities, key phrases, and topics from new chunks, if enabled
+	extractEnabled := env.GetBoolOrDefault("EXTRACT_ENABLED", false)
+	extractor := jobs.NewExtractor(jobs.NewExtractorOptions{
+		AI:        ai,
+		DB:        db,
+		Log:       log,
+		BatchSize: env.GetIntOrDefault("EXTRACT_BATCH_SIZE", 20),
+		Interval:  env.GetDurationOrDefault("EXTRACT_INTERVAL", time.Minute),
 	})
 
 	// Use an errgroup to wait for separate goroutines which can error
@@ -71,6 +200,32 @@ func start(log *slog.Logger) error {
 		return s.Start()
 	})
 
+	// Start the job runner, which stops when the context is cancelled
+	eg.Go(func() error {
+		runner.Start(ctx)
+		return nil
+	})
+
+	// Start the trash purger, which also stops when the context is cancelled
+	eg.Go(func() error {
+		purger.Start(ctx)
+		return nil
+	})
+
+	// Start the summarizer, which also stops when the context is cancelled
+	eg.Go(func() error {
+		summarizer.Start(ctx)
+		return nil
+	})
+
+	// Start the extractor if enabled, which also stops when the context is cancelled
+	if extractEnabled {
+		eg.Go(func() error {
+			extractor.Start(ctx)
+			return nil
+		})
+	}
+
 	// Wait for the context to be done, which happens when a signal is caught
 	<-ctx.Done()
 	log.Info("Stopping app")
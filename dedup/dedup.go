@@ -0,0 +1,163 @@
+// Package dedup finds exact and near-duplicate documents.
+// Exact duplicates have the same [ContentHash], and near-duplicates have a [SimHash] within a small Hamming distance.
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"slices"
+	"strings"
+	"unicode"
+
+	"app/model"
+)
+
+// ContentHash of the document content, as a hex-encoded SHA-256 hash.
+func ContentHash(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+// shingleSize is the number of words in each feature of a [SimHash].
+const shingleSize = 3
+
+// SimHash of the text, a 64-bit fingerprint where similar texts have fingerprints with a small Hamming distance.
+// Features are overlapping shingles of lowercased words, so word order matters but case and punctuation don't.
+// See https://en.wikipedia.org/wiki/SimHash
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := range weights {
+			if sum&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(words) < shingleSize {
+		add(strings.Join(words, " "))
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		add(strings.Join(words[i:i+shingleSize], " "))
+	}
+
+	var fingerprint uint64
+	for i, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << i
+		}
+	}
+	return fingerprint
+}
+
+// Distance between two [SimHash] fingerprints, as the number of differing bits.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Fingerprint of a document.
+type Fingerprint struct {
+	ID      model.ID
+	SimHash uint64
+}
+
+// MaxDistance for [Groups]. With larger distances, the bands are so narrow that most documents share one,
+// and comparing them approaches comparing all pairs.
+const MaxDistance = 7
+
+// Groups of near-duplicate documents, where each document in a group is within maxDistance of at least one other.
+// Fingerprints are split into maxDistance+1 bands, and only documents sharing a band are compared, since documents
+// within maxDistance must have at least one identical band. maxDistance must be between 0 and [MaxDistance].
+// Groups have at least two documents, and are sorted by their first ID, with IDs sorted within each group.
+func Groups(fingerprints []Fingerprint, maxDistance int) [][]model.ID {
+	if maxDistance < 0 || maxDistance > MaxDistance {
+		panic(fmt.Sprintf("max distance must be between 0 and %v", MaxDistance))
+	}
+
+	parents := make([]int, len(fingerprints))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+
+	// Identical fingerprints are grouped right away, so only distinct fingerprints are compared in the bands
+	var distinct []int
+	firsts := map[uint64]int{}
+	for i, f := range fingerprints {
+		if first, ok := firsts[f.SimHash]; ok {
+			parents[find(i)] = find(first)
+			continue
+		}
+		firsts[f.SimHash] = i
+		distinct = append(distinct, i)
+	}
+
+	bandCount := maxDistance + 1
+	bandWidth := 64 / bandCount
+	for band := range bandCount {
+		shift := band * bandWidth
+		width := bandWidth
+		// The last band takes the remaining bits
+		if band == bandCount-1 {
+			width = 64 - shift
+		}
+		mask := uint64(1)<<width - 1
+
+		buckets := map[uint64][]int{}
+		for _, i := range distinct {
+			key := (fingerprints[i].SimHash >> shift) & mask
+			buckets[key] = append(buckets[key], i)
+		}
+
+		for _, bucket := range buckets {
+			for i := 0; i < len(bucket); i++ {
+				for j := i + 1; j < len(bucket); j++ {
+					a, b := bucket[i], bucket[j]
+					if Distance(fingerprints[a].SimHash, fingerprints[b].SimHash) <= maxDistance {
+						parents[find(a)] = find(b)
+					}
+				}
+			}
+		}
+	}
+
+	byRoot := map[int][]model.ID{}
+	for i, f := range fingerprints {
+		root := find(i)
+		byRoot[root] = append(byRoot[root], f.ID)
+	}
+
+	var groups [][]model.ID
+	for _, ids := range byRoot {
+		if len(ids) < 2 {
+			continue
+		}
+		slices.Sort(ids)
+		groups = append(groups, ids)
+	}
+	slices.SortFunc(groups, func(a, b []model.ID) int {
+		return strings.Compare(string(a[0]), string(b[0]))
+	})
+	return groups
+}
@@ -0,0 +1,92 @@
+package dedup_test
+
+import (
+	"fmt"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/dedup"
+	"app/model"
+)
+
+func TestContentHash(t *testing.T) {
+	t.Run("is the hex-encoded SHA-256 of the content", func(t *testing.T) {
+		is.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", dedup.ContentHash(""))
+	})
+}
+
+func TestSimHash(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog, and then the fox runs back into the forest to sleep."
+
+	t.Run("ignores case and punctuation", func(t *testing.T) {
+		is.Equal(t, dedup.SimHash(text), dedup.SimHash("the QUICK brown fox jumps over the lazy dog and then the fox runs back into the forest to sleep"))
+	})
+
+	t.Run("is close for similar texts", func(t *testing.T) {
+		similar := "The quick brown fox jumps over the lazy dog, and then the fox runs back into the forest to rest."
+		is.True(t, dedup.Distance(dedup.SimHash(text), dedup.SimHash(similar)) <= 10)
+	})
+
+	t.Run("is far for different texts", func(t *testing.T) {
+		different := "Sheep are fluffy animals that live on farms and eat grass all day long, unlike goats."
+		is.True(t, dedup.Distance(dedup.SimHash(text), dedup.SimHash(different)) > 10)
+	})
+
+	t.Run("is zero for text without words", func(t *testing.T) {
+		is.Equal(t, uint64(0), dedup.SimHash(" ... "))
+	})
+}
+
+func TestGroups(t *testing.T) {
+	t.Run("groups fingerprints within the distance", func(t *testing.T) {
+		fingerprints := []dedup.Fingerprint{
+			{ID: "d_c", SimHash: 0b1111},
+			{ID: "d_a", SimHash: 0b1110},
+			{ID: "d_b", SimHash: 0xffff_0000_0000_0000},
+			{ID: "d_d", SimHash: 0xffff_0000_0000_0001},
+			{ID: "d_e", SimHash: 0x0f0f_0f0f_0f0f_0f0f},
+		}
+
+		groups := dedup.Groups(fingerprints, 1)
+		is.EqualSlice(t, []model.ID{"d_a", "d_c"}, groups[0])
+		is.EqualSlice(t, []model.ID{"d_b", "d_d"}, groups[1])
+		is.Equal(t, 2, len(groups))
+	})
+
+	t.Run("groups transitively", func(t *testing.T) {
+		fingerprints := []dedup.Fingerprint{
+			{ID: "d_a", SimHash: 0b000},
+			{ID: "d_b", SimHash: 0b001},
+			{ID: "d_c", SimHash: 0b011},
+		}
+
+		groups := dedup.Groups(fingerprints, 1)
+		is.Equal(t, 1, len(groups))
+		is.EqualSlice(t, []model.ID{"d_a", "d_b", "d_c"}, groups[0])
+	})
+
+	t.Run("only groups identical fingerprints with distance zero", func(t *testing.T) {
+		fingerprints := []dedup.Fingerprint{
+			{ID: "d_a", SimHash: 1},
+			{ID: "d_b", SimHash: 1},
+			{ID: "d_c", SimHash: 3},
+		}
+
+		groups := dedup.Groups(fingerprints, 0)
+		is.Equal(t, 1, len(groups))
+		is.EqualSlice(t, []model.ID{"d_a", "d_b"}, groups[0])
+	})
+
+	t.Run("groups many identical fingerprints", func(t *testing.T) {
+		var fingerprints []dedup.Fingerprint
+		for i := range 10_000 {
+			fingerprints = append(fingerprints, dedup.Fingerprint{ID: model.ID(fmt.Sprintf("d_%05d", i)), SimHash: 1})
+		}
+		fingerprints = append(fingerprints, dedup.Fingerprint{ID: "d_x", SimHash: 3})
+
+		groups := dedup.Groups(fingerprints, dedup.MaxDistance)
+		is.Equal(t, 1, len(groups))
+		is.Equal(t, 10_001, len(groups[0]))
+	})
+}
@@ -0,0 +1,261 @@
+// Package diff compares texts line by line and formats the differences as unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of change to a line.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Line in an edit script, which turns the old text into the new text.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// contextLines around changes in unified diff hunks.
+const contextLines = 3
+
+// maxDifferences before [Lines] gives up on finding the shortest edit script, since the memory it needs grows
+// with the square of the number of differences.
+const maxDifferences = 1000
+
+// Lines returns the shortest edit script from the lines of a to the lines of b, using the Myers algorithm,
+// which takes time proportional to the size of the texts times the number of differences.
+// Lines common to the start and end of both texts are kept as they are. If the rest of the texts have more
+// than maxDifferences differences, the edit script deletes all of it from a and inserts all of it from b instead.
+// See http://www.xmailserver.org/diff2.pdf
+func Lines(a, b string) []Line {
+	as, bs := splitLines(a), splitLines(b)
+
+	var prefix int
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix && as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+
+	var lines []Line
+	for _, l := range as[:prefix] {
+		lines = append(lines, Line{Op: Equal, Text: l})
+	}
+	lines = append(lines, myers(as[prefix:len(as)-suffix], bs[prefix:len(bs)-suffix])...)
+	for _, l := range as[len(as)-suffix:] {
+		lines = append(lines, Line{Op: Equal, Text: l})
+	}
+	return lines
+}
+
+// myers returns the shortest edit script from as to bs, or one that replaces all of as with bs
+// if there are more than maxDifferences differences.
+func myers(as, bs []string) []Line {
+	n, m := len(as), len(bs)
+	total := n + m
+	offset := total + 1
+
+	// v has the furthest x on each diagonal k = x - y, and trace has a copy of the diagonals -d-1 to d+1 of v
+	// for each number of differences d, which are the ones backtracking needs
+	v := make([]int, 2*total+3)
+	var trace [][]int
+
+	for d := 0; d <= min(total, maxDifferences); d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && as[x] == bs[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, as, bs)
+			}
+		}
+	}
+
+	lines := make([]Line, 0, total)
+	for _, l := range as {
+		lines = append(lines, Line{Op: Delete, Text: l})
+	}
+	for _, l := range bs {
+		lines = append(lines, Line{Op: Insert, Text: l})
+	}
+	return lines
+}
+
+// backtrack through the trace from the end of both texts to build the edit script.
+func backtrack(trace [][]int, as, bs []string) []Line {
+	var lines []Line
+	x, y := len(as), len(bs)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// The trace for d starts at diagonal -d-1
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, Line{Op: Equal, Text: as[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				lines = append(lines, Line{Op: Insert, Text: bs[y]})
+			} else {
+				x--
+				lines = append(lines, Line{Op: Delete, Text: as[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// Unified diff from a to b, with file names for the headers. Returns the empty string if the texts have the same lines.
+func Unified(aName, bName, a, b string) string {
+	lines := Lines(a, b)
+
+	var sb strings.Builder
+	for _, h := range hunks(lines) {
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %v\n+++ %v\n", aName, bName)
+		}
+		fmt.Fprintf(&sb, "@@ -%v +%v @@\n", hunkRange(h.aStart, h.aCount), hunkRange(h.bStart, h.bCount))
+		for _, l := range lines[h.start:h.end] {
+			switch l.Op {
+			case Equal:
+				sb.WriteString(" ")
+			case Delete:
+				sb.WriteString("-")
+			case Insert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(l.Text)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// hunk of an edit script, from start to end, with the line ranges in both texts.
+type hunk struct {
+	start, end     int
+	aStart, aCount int
+	bStart, bCount int
+}
+
+// hunks of changes with context lines around them. Changes with at most twice the context between them are merged.
+func hunks(lines []Line) []hunk {
+	var hs []hunk
+	var aLine, bLine int
+	var h *hunk
+	lastChange := -1
+
+	for i, l := range lines {
+		if l.Op != Equal {
+			if h == nil || i-lastChange-1 > 2*contextLines {
+				if h != nil {
+					hs = append(hs, *h)
+				}
+
+				// Start a new hunk with up to contextLines of context before the change
+				start := max(i-contextLines, lastChange+1, 0)
+				h = &hunk{start: start, aStart: aLine - (i - start) + 1, bStart: bLine - (i - start) + 1}
+			}
+			lastChange = i
+		}
+
+		switch l.Op {
+		case Equal:
+			aLine++
+			bLine++
+		case Delete:
+			aLine++
+		case Insert:
+			bLine++
+		}
+	}
+
+	if h != nil {
+		hs = append(hs, *h)
+	}
+
+	// Set the end of each hunk to include context after its last change, and count the lines in each text
+	for i := range hs {
+		end := len(lines)
+		if i+1 < len(hs) {
+			end = hs[i+1].start
+		}
+		last := hs[i].start
+		for j := hs[i].start; j < end; j++ {
+			if lines[j].Op != Equal {
+				last = j
+			}
+		}
+		hs[i].end = min(last+contextLines+1, end)
+
+		for _, l := range lines[hs[i].start:hs[i].end] {
+			if l.Op != Insert {
+				hs[i].aCount++
+			}
+			if l.Op != Delete {
+				hs[i].bCount++
+			}
+		}
+	}
+
+	return hs
+}
+
+// hunkRange in the unified format, where the start is the line before an empty range.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%v,0", start-1)
+	case 1:
+		return fmt.Sprintf("%v", start)
+	default:
+		return fmt.Sprintf("%v,%v", start, count)
+	}
+}
+
+// splitLines of the text, without a trailing empty line if the text ends with a newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
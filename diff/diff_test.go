@@ -0,0 +1,103 @@
+package diff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/diff"
+)
+
+func TestLines(t *testing.T) {
+	t.Run("returns the shortest edit script", func(t *testing.T) {
+		lines := diff.Lines("a\nb\nc\n", "a\nc\nd\n")
+		is.EqualSlice(t, []diff.Line{
+			{Op: diff.Equal, Text: "a"},
+			{Op: diff.Delete, Text: "b"},
+			{Op: diff.Equal, Text: "c"},
+			{Op: diff.Insert, Text: "d"},
+		}, lines)
+	})
+
+	t.Run("handles empty texts", func(t *testing.T) {
+		is.Equal(t, 0, len(diff.Lines("", "")))
+		is.EqualSlice(t, []diff.Line{{Op: diff.Insert, Text: "a"}}, diff.Lines("", "a"))
+		is.EqualSlice(t, []diff.Line{{Op: diff.Delete, Text: "a"}}, diff.Lines("a", ""))
+	})
+
+	t.Run("replaces the changed lines when there are too many differences", func(t *testing.T) {
+		var a, b strings.Builder
+		a.WriteString("start\n")
+		b.WriteString("start\n")
+		for i := range 600 {
+			fmt.Fprintf(&a, "a%v\n", i)
+			fmt.Fprintf(&b, "b%v\n", i)
+		}
+		a.WriteString("end\n")
+		b.WriteString("end\n")
+
+		lines := diff.Lines(a.String(), b.String())
+		is.Equal(t, 1202, len(lines))
+		is.Equal(t, diff.Line{Op: diff.Equal, Text: "start"}, lines[0])
+		is.Equal(t, diff.Line{Op: diff.Delete, Text: "a0"}, lines[1])
+		is.Equal(t, diff.Line{Op: diff.Delete, Text: "a599"}, lines[600])
+		is.Equal(t, diff.Line{Op: diff.Insert, Text: "b0"}, lines[601])
+		is.Equal(t, diff.Line{Op: diff.Equal, Text: "end"}, lines[1201])
+	})
+}
+
+func TestUnified(t *testing.T) {
+	t.Run("formats changes with context", func(t *testing.T) {
+		a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+		b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\nsixteen\n"
+
+		expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -13,3 +13,4 @@
+ 13
+ 14
+ 15
++sixteen
+`
+		is.Equal(t, expected, diff.Unified("a", "b", a, b))
+	})
+
+	t.Run("merges changes close to each other into one hunk", func(t *testing.T) {
+		a := "1\n2\n3\n4\n5\n6\n7\n8\n"
+		b := "one\n2\n3\n4\n5\n6\n7\neight\n"
+
+		expected := `--- a
++++ b
+@@ -1,8 +1,8 @@
+-1
++one
+ 2
+ 3
+ 4
+ 5
+ 6
+ 7
+-8
++eight
+`
+		is.Equal(t, expected, diff.Unified("a", "b", a, b))
+	})
+
+	t.Run("formats additions to an empty text", func(t *testing.T) {
+		is.Equal(t, "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n", diff.Unified("a", "b", "", "new"))
+	})
+
+	t.Run("returns nothing for equal texts", func(t *testing.T) {
+		is.Equal(t, "", diff.Unified("a", "b", "same\n", "same"))
+	})
+}
@@ -0,0 +1,119 @@
+package enrich
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+
+	"app/ai"
+	"app/model"
+	"app/prompt"
+	"app/tracing"
+)
+
+type extractionSaver interface {
+	SaveChunkExtraction(ctx context.Context, c model.Chunk, e model.Extraction) error
+}
+
+const (
+	// extractMaxValues is the maximum number of each of entities, key phrases, and topics kept per chunk.
+	extractMaxValues = 20
+
+	// extractMaxLength is the maximum length in bytes of an entity name, key phrase, or topic.
+	extractMaxLength = 100
+)
+
+// ExtractChunk with [Extract], and save the extraction with the chunk.
+func ExtractChunk(ctx context.Context, db extractionSaver, cc chatCompleter, c model.Chunk) (_ model.Extraction, err error) {
+	ctx, span := tracer.Start(ctx, "enrich.ExtractChunk", trace.WithAttributes(attribute.String("chunk.id", string(c.ID))))
+	defer func() { tracing.End(span, err) }()
+
+	e, err := Extract(ctx, cc, c.Content)
+	if err != nil {
+		return e, errors.Wrap(err, "error extracting from chunk")
+	}
+
+	if err := db.SaveChunkExtraction(ctx, c, e); err != nil {
+		return e, err
+	}
+
+	return e, nil
+}
+
+// Extract named entities, key phrases, and topics from content with the chat completer, using [ai.CompleteJSON].
+// Entities with unknown types are left out, key phrases and topics are lowercased, and duplicates are removed.
+// If the model doesn't give valid output, the error matches [ai.ErrInvalidJSON].
+func Extract(ctx context.Context, cc chatCompleter, content string) (_ model.Extraction, err error) {
+	ctx, span := tracer.Start(ctx, "enrich.Extract", trace.WithAttributes(attribute.Int("content.length", len(content))))
+	defer func() { tracing.End(span, err) }()
+
+	var e model.Extraction
+	if strings.TrimSpace(content) == "" {
+		return e, nil
+	}
+
+	p, t, err := prompt.Render(ctx, "extract", map[string]any{"Content": content})
+	if err != nil {
+		return e, err
+	}
+
+	e, err = ai.CompleteJSON[model.Extraction](ctx, cc, p, ai.CompleteJSONOptions{})
+	if err != nil {
+		return e, errors.Wrap(err, "error completing extraction")
+	}
+
+	e = normalizeExtraction(e)
+	e.Prompt = t.ID()
+	span.SetAttributes(
+		attribute.Int("entities", len(e.Entities)),
+		attribute.Int("keyPhrases", len(e.KeyPhrases)),
+		attribute.Int("topics", len(e.Topics)),
+	)
+
+	return e, nil
+}
+
+func normalizeExtraction(e model.Extraction) model.Extraction {
+	var normalized model.Extraction
+
+	seen := map[model.Entity]bool{}
+	for _, entity := range e.Entities {
+		entity.Name = strings.Join(strings.Fields(entity.Name), " ")
+		entity.Type = model.EntityType(strings.ToLower(string(entity.Type)))
+		if entity.Name == "" || len(entity.Name) > extractMaxLength || !entity.Type.Valid() {
+			continue
+		}
+
+		key := model.Entity{Name: strings.ToLower(entity.Name), Type: entity.Type}
+		if seen[key] || len(normalized.Entities) == extractMaxValues {
+			continue
+		}
+		seen[key] = true
+		normalized.Entities = append(normalized.Entities, entity)
+	}
+
+	normalized.KeyPhrases = normalizeValues(e.KeyPhrases)
+	normalized.Topics = normalizeValues(e.Topics)
+
+	return normalized
+}
+
+// normalizeValues by collapsing whitespace and lowercasing, leaving out empty, too long, and duplicate values.
+func normalizeValues(values []string) []string {
+	var normalized []string
+	for _, v := range values {
+		v = strings.ToLower(strings.Join(strings.Fields(v), " "))
+		if v == "" || len(v) > extractMaxLength || slices.Contains(normalized, v) {
+			continue
+		}
+		if len(normalized) == extractMaxValues {
+			break
+		}
+		normalized = append(normalized, v)
+	}
+	return normalized
+}
@@ -0,0 +1,55 @@
+package enrich_test
+
+import (
+	"context"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	"app/ai"
+	"app/enrich"
+	"app/model"
+)
+
+type chatCompleterStub struct {
+	output string
+}
+
+func (c chatCompleterStub) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	return gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		yield(gai.TextMessagePart(c.output), nil)
+	}), nil
+}
+
+func TestExtract(t *testing.T) {
+	t.Run("extracts and normalizes entities, key phrases, and topics", func(t *testing.T) {
+		cc := chatCompleterStub{output: "```json\n" + `{
+			"entities": [
+				{"name": "Ada  Lovelace", "type": "Person"},
+				{"name": "ada lovelace", "type": "person"},
+				{"name": "London", "type": "place"},
+				{"name": "Analytical Engine", "type": "machine"}
+			],
+			"keyPhrases": ["Analytical Engine", "analytical engine", " first program "],
+			"topics": ["Computing", "", "history"]
+		}` + "\n```"}
+
+		e, err := enrich.Extract(t.Context(), cc, "Ada Lovelace wrote the first program for the Analytical Engine in London.")
+		is.NotError(t, err)
+		is.EqualSlice(t, []model.Entity{
+			{Name: "Ada Lovelace", Type: model.EntityTypePerson},
+			{Name: "London", Type: model.EntityTypePlace},
+		}, e.Entities)
+		is.EqualSlice(t, []string{"analytical engine", "first program"}, e.KeyPhrases)
+		is.EqualSlice(t, []string{"computing", "history"}, e.Topics)
+		is.Equal(t, "extract@v2", e.Prompt)
+	})
+
+	t.Run("errors on invalid output", func(t *testing.T) {
+		cc := chatCompleterStub{output: "I can't do that."}
+
+		_, err := enrich.Extract(t.Context(), cc, "Ada Lovelace")
+		is.Error(t, ai.ErrInvalidJSON, err)
+	})
+}
@@ -0,0 +1,144 @@
+// Package enrich has enrichment of documents with a language model,
+// like summaries and extraction of entities, key phrases, and topics.
+package enrich
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+
+	"app/dedup"
+	"app/model"
+	"app/prompt"
+	"app/tracing"
+)
+
+var tracer = otel.Tracer("app/enrich")
+
+type chatCompleter interface {
+	ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error)
+}
+
+type chatCompleteEmbedder interface {
+	chatCompleter
+	EmbedString(ctx context.Context, s string) ([]byte, error)
+}
+
+type summarySaver interface {
+	SaveDocumentSummary(ctx context.Context, s model.DocumentSummary, embedding []byte) (model.DocumentSummary, error)
+}
+
+const (
+	// summaryPartSize is the number of words in each part of content that is too long to summarize at once.
+	summaryPartSize = 2048
+
+	// summaryMaxDepth is the maximum number of times part summaries are summarized again.
+	summaryMaxDepth = 3
+)
+
+// SummarizeDocument with [Summarize], and save the summary with an embedding of it.
+func SummarizeDocument(ctx context.Context, db summarySaver, ai chatCompleteEmbedder, doc model.Document) (_ model.DocumentSummary, err error) {
+	ctx, span := tracer.Start(ctx, "enrich.SummarizeDocument", trace.WithAttributes(attribute.String("document.id", string(doc.ID))))
+	defer func() { tracing.End(span, err) }()
+
+	summary, prompts, err := Summarize(ctx, ai, doc.Content)
+	if err != nil {
+		return model.DocumentSummary{}, errors.Wrap(err, "error summarizing document")
+	}
+
+	var embedding []byte
+	if summary != "" {
+		embedding, err = ai.EmbedString(ctx, summary)
+		if err != nil {
+			return model.DocumentSummary{}, errors.Wrap(err, "error embedding summary")
+		}
+	}
+
+	return db.SaveDocumentSummary(ctx, model.DocumentSummary{
+		DocumentID:  doc.ID,
+		Content:     summary,
+		ContentHash: dedup.ContentHash(doc.Content),
+		Prompt:      strings.Join(prompts, ","),
+	}, embedding)
+}
+
+// Summarize content with the chat completer.
+// Content that is too long to summarize at once is split into parts, which are summarized separately,
+// and then the part summaries are combined into one summary.
+// Empty content has an empty summary.
+// The IDs of the prompt templates used are returned as well, see [prompt.Template.ID].
+func Summarize(ctx context.Context, cc chatCompleter, content string) (_ string, prompts []string, err error) {
+	ctx, span := tracer.Start(ctx, "enrich.Summarize", trace.WithAttributes(attribute.Int("content.length", len(content))))
+	defer func() { tracing.End(span, err) }()
+
+	if strings.TrimSpace(content) == "" {
+		return "", nil, nil
+	}
+
+	summary, err := summarize(ctx, cc, content, "summarize-document", 0, &prompts)
+	return summary, prompts, err
+}
+
+// summarize content with the named prompt template, or in parts if it's too long,
+// in which case the part summaries are summarized again until they fit, or the maximum depth is reached.
+// The IDs of the prompt templates used are added to prompts.
+func summarize(ctx context.Context, cc chatCompleter, content, name string, depth int, prompts *[]string) (string, error) {
+	chunker := gai.NewFixedSizeChunker(gai.NewFixedSizeChunkerOptions{
+		Tokenizer: &gai.NaiveWordTokenizer{},
+		Size:      summaryPartSize,
+	})
+
+	parts := chunker.Chunk(ctx, content)
+	if len(parts) <= 1 || depth >= summaryMaxDepth {
+		return complete(ctx, cc, name, map[string]any{"Content": content}, prompts)
+	}
+
+	summaries := make([]string, 0, len(parts))
+	for _, part := range parts {
+		summary, err := complete(ctx, cc, "summarize-part", map[string]any{"Content": part}, prompts)
+		if err != nil {
+			return "", err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summarize(ctx, cc, strings.Join(summaries, "\n\n"), "combine-summaries", depth+1, prompts)
+}
+
+// complete the named prompt template rendered with the data, see [prompt.Render],
+// and return the trimmed text of the response. The template ID is added to prompts if it's not there already.
+func complete(ctx context.Context, cc chatCompleter, name string, data any, prompts *[]string) (string, error) {
+	p, t, err := prompt.Render(ctx, name, data)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(*prompts, t.ID()) {
+		*prompts = append(*prompts, t.ID())
+	}
+
+	res, err := cc.ChatComplete(ctx, gai.ChatCompleteRequest{
+		Messages:    []gai.Message{gai.NewUserTextMessage(p)},
+		Temperature: gai.Ptr(gai.Temperature(0)),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "error chat completing")
+	}
+
+	var b strings.Builder
+	for part, err := range res.Parts() {
+		if err != nil {
+			return "", errors.Wrap(err, "error reading chat completion")
+		}
+		if part.Type == gai.MessagePartTypeText {
+			b.WriteString(part.Text())
+		}
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
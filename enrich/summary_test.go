@@ -0,0 +1,57 @@
+package enrich_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	"app/enrich"
+)
+
+type chatCompleterMock struct {
+	prompts []string
+}
+
+func (c *chatCompleterMock) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	c.prompts = append(c.prompts, req.Messages[0].Parts[0].Text())
+
+	return gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		yield(gai.TextMessagePart(" A summary. "), nil)
+	}), nil
+}
+
+func TestSummarize(t *testing.T) {
+	t.Run("summarizes short content at once", func(t *testing.T) {
+		cc := &chatCompleterMock{}
+
+		summary, prompts, err := enrich.Summarize(t.Context(), cc, "Sheep are fluffy.")
+		is.NotError(t, err)
+		is.Equal(t, "A summary.", summary)
+		is.EqualSlice(t, []string{"summarize-document@v1"}, prompts)
+		is.Equal(t, 1, len(cc.prompts))
+		is.True(t, strings.HasSuffix(cc.prompts[0], "Sheep are fluffy."))
+	})
+
+	t.Run("summarizes long content in parts and combines the part summaries", func(t *testing.T) {
+		cc := &chatCompleterMock{}
+
+		summary, prompts, err := enrich.Summarize(t.Context(), cc, strings.Repeat("sheep ", 5000))
+		is.NotError(t, err)
+		is.Equal(t, "A summary.", summary)
+		is.EqualSlice(t, []string{"summarize-part@v1", "combine-summaries@v1"}, prompts)
+		is.Equal(t, 4, len(cc.prompts))
+		is.True(t, strings.HasSuffix(cc.prompts[3], "A summary.\n\nA summary.\n\nA summary."))
+	})
+
+	t.Run("does not summarize empty content", func(t *testing.T) {
+		cc := &chatCompleterMock{}
+
+		summary, _, err := enrich.Summarize(t.Context(), cc, " \n")
+		is.NotError(t, err)
+		is.Equal(t, "", summary)
+		is.Equal(t, 0, len(cc.prompts))
+	})
+}
@@ -0,0 +1,53 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"maragu.dev/errors"
+)
+
+// csvToMarkdown with the first row as the header.
+// Each following row becomes a paragraph of "header: value" lines, so every row stands on its own
+// and chunking never separates a value from its column name.
+func csvToMarkdown(data []byte) (string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "error reading header")
+	}
+
+	var b strings.Builder
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "error reading row %v", row)
+		}
+
+		for i, value := range record {
+			if strings.TrimSpace(value) == "" {
+				continue
+			}
+			name := fmt.Sprintf("Column %v", i+1)
+			if i < len(header) && strings.TrimSpace(header[i]) != "" {
+				name = strings.TrimSpace(header[i])
+			}
+			fmt.Fprintf(&b, "%v: %v\n", name, strings.TrimSpace(value))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,167 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"maragu.dev/errors"
+)
+
+// maxDOCXDocumentSize is the maximum uncompressed size of the main document part of a Word document,
+// so small archives can't decompress to huge documents.
+const maxDOCXDocumentSize = 64 << 20
+
+// docxToMarkdown from the main document part of a Word document.
+// Headings, list items, and tables are kept, all other formatting is dropped.
+func docxToMarkdown(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", errors.Wrap(err, "error opening DOCX archive")
+	}
+
+	var document *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return "", errors.New("no word/document.xml in DOCX archive")
+	}
+
+	if document.UncompressedSize64 > maxDOCXDocumentSize {
+		return "", errors.Newf("word/document.xml is larger than %v bytes", maxDOCXDocumentSize)
+	}
+
+	rc, err := document.Open()
+	if err != nil {
+		return "", errors.Wrap(err, "error opening word/document.xml")
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	var b, para, cell strings.Builder
+	var style string
+	var list, afterList bool
+	var tableDepth, rowCount int
+	var row []string
+
+	// The size in the archive could be wrong, so don't trust it for reading
+	dec := xml.NewDecoder(io.LimitReader(rc, maxDOCXDocumentSize))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "error parsing word/document.xml")
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				style = ""
+				list = false
+			case "pStyle":
+				style = attr(t, "val")
+			case "numPr":
+				list = true
+			case "t":
+				var s string
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return "", errors.Wrap(err, "error decoding text")
+				}
+				para.WriteString(s)
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				para.WriteString("\n")
+			case "tbl":
+				tableDepth++
+				rowCount = 0
+				b.WriteString("\n")
+			case "tr":
+				row = nil
+			case "tc":
+				cell.Reset()
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				text := strings.TrimSpace(para.String())
+				if text == "" {
+					continue
+				}
+				if tableDepth > 0 {
+					if cell.Len() > 0 {
+						cell.WriteString(" ")
+					}
+					cell.WriteString(text)
+					continue
+				}
+				if afterList && !list {
+					b.WriteString("\n")
+				}
+				afterList = list
+				switch {
+				case headingLevel(style) > 0:
+					b.WriteString(strings.Repeat("#", headingLevel(style)) + " " + text + "\n\n")
+				case list:
+					b.WriteString("- " + text + "\n")
+				default:
+					b.WriteString(text + "\n\n")
+				}
+			case "tc":
+				row = append(row, strings.ReplaceAll(cell.String(), "|", `\|`))
+			case "tr":
+				if tableDepth > 1 {
+					continue
+				}
+				b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+				if rowCount == 0 {
+					b.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+				}
+				rowCount++
+			case "tbl":
+				tableDepth--
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	return collapseBlankLines(b.String()), nil
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// headingLevel from a Word paragraph style ID like "Heading2", or 0 if it's not a heading style.
+func headingLevel(style string) int {
+	if style == "Title" {
+		return 1
+	}
+	level, ok := strings.CutPrefix(style, "Heading")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(level)
+	if err != nil || n < 1 {
+		return 0
+	}
+	return min(n, 6)
+}
@@ -0,0 +1,136 @@
+// Package extract turns uploaded files in different formats into Markdown, ready for chunking.
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"maragu.dev/errors"
+)
+
+// Media types that can be extracted.
+const (
+	MediaTypeCSV      = "text/csv"
+	MediaTypeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MediaTypeHTML     = "text/html"
+	MediaTypeMarkdown = "text/markdown"
+	MediaTypePDF      = "application/pdf"
+	MediaTypeText     = "text/plain"
+)
+
+// ErrUnsupportedMediaType is returned by [Markdown] for media types that cannot be extracted.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// MediaType of the data, based on the given content type, the file name extension, or sniffing the data,
+// in that order. Generic content types like application/octet-stream are ignored.
+// Parameters like charset are removed.
+func MediaType(data []byte, contentType, filename string) string {
+	if mt := parseMediaType(contentType); mt != "" && mt != "application/octet-stream" {
+		return mt
+	}
+
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" {
+		switch ext {
+		case ".md", ".markdown":
+			return MediaTypeMarkdown
+		case ".docx":
+			return MediaTypeDOCX
+		}
+		if mt := parseMediaType(mime.TypeByExtension(ext)); mt != "" {
+			return mt
+		}
+	}
+
+	mt := parseMediaType(http.DetectContentType(data))
+	if mt == "application/zip" && isDOCX(data) {
+		return MediaTypeDOCX
+	}
+	return mt
+}
+
+func parseMediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mt
+}
+
+// isDOCX checks whether the zip archive in data has the main document part of a Word document.
+func isDOCX(data []byte) bool {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, f := range r.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// Markdown extracts the text from data of the given media type, as Markdown.
+// Data of other media types, like application/json or application/x-www-form-urlencoded, is passed through as text
+// if it is text. Returns [ErrUnsupportedMediaType] for other media types with binary data.
+func Markdown(data []byte, mediaType string) (string, error) {
+	var text string
+	var err error
+
+	switch mediaType {
+	case MediaTypeMarkdown, MediaTypeText, "":
+		text = string(data)
+	case MediaTypeHTML, "application/xhtml+xml":
+		text, err = htmlToMarkdown(data)
+	case MediaTypePDF:
+		text, err = pdfToMarkdown(data)
+	case MediaTypeDOCX:
+		text, err = docxToMarkdown(data)
+	case MediaTypeCSV:
+		text, err = csvToMarkdown(data)
+	default:
+		if !isText(data) {
+			return "", errors.Wrap(ErrUnsupportedMediaType, "%v", mediaType)
+		}
+		text = string(data)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "error extracting %v", mediaType)
+	}
+
+	return strings.TrimSpace(text), nil
+}
+
+// isText if data is non-empty, valid UTF-8 without NUL bytes, which binary formats are full of.
+func isText(data []byte) bool {
+	return len(data) > 0 && utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// collapseBlankLines so there's at most one blank line between blocks of text.
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	var b strings.Builder
+	var blank bool
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if !blank {
+				b.WriteString("\n")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
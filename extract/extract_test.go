@@ -0,0 +1,135 @@
+package extract_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/extract"
+)
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		filename    string
+		expected    string
+	}{
+		{name: "uses content type without parameters", contentType: "text/html; charset=utf-8", expected: "text/html"},
+		{name: "ignores octet stream and uses file extension", contentType: "application/octet-stream", filename: "data.csv", expected: "text/csv"},
+		{name: "detects markdown extension", filename: "README.md", expected: "text/markdown"},
+		{name: "sniffs PDF", data: []byte("%PDF-1.7\n"), expected: "application/pdf"},
+		{name: "sniffs HTML", data: []byte("<!DOCTYPE html><html></html>"), expected: "text/html"},
+		{name: "sniffs DOCX", data: newDOCX(t, ""), expected: extract.MediaTypeDOCX},
+		{name: "sniffs plain text", data: []byte("Hello"), expected: "text/plain"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is.Equal(t, test.expected, extract.MediaType(test.data, test.contentType, test.filename))
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Run("passes markdown through", func(t *testing.T) {
+		text, err := extract.Markdown([]byte("# Hi\n\nThere\n"), extract.MediaTypeMarkdown)
+		is.NotError(t, err)
+		is.Equal(t, "# Hi\n\nThere", text)
+	})
+
+	t.Run("converts HTML main content with headings, lists, and tables", func(t *testing.T) {
+		page := `<html><head><title>Ignored</title><script>var x;</script></head><body>
+			<nav><a href="/">Home</a></nav>
+			<main>
+				<h1>Sheep</h1>
+				<p>Sheep are <b>fluffy</b> animals.</p>
+				<ul><li>Wool</li><li>Milk</li></ul>
+				<table><tr><th>Name</th><th>Legs</th></tr><tr><td>Sheep</td><td>4</td></tr></table>
+			</main>
+			<footer>Copyright</footer>
+		</body></html>`
+
+		text, err := extract.Markdown([]byte(page), extract.MediaTypeHTML)
+		is.NotError(t, err)
+		is.Equal(t, "# Sheep\n\nSheep are fluffy animals.\n\n- Wool\n- Milk\n\n| Name | Legs |\n| --- | --- |\n| Sheep | 4 |", text)
+	})
+
+	t.Run("converts CSV rows to paragraphs", func(t *testing.T) {
+		text, err := extract.Markdown([]byte("name,legs\nsheep,4\nchicken,2\n"), extract.MediaTypeCSV)
+		is.NotError(t, err)
+		is.Equal(t, "name: sheep\nlegs: 4\n\nname: chicken\nlegs: 2", text)
+	})
+
+	t.Run("converts DOCX with headings and paragraphs", func(t *testing.T) {
+		body := `<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Sheep</w:t></w:r></w:p>` +
+			`<w:p><w:r><w:t xml:space="preserve">Sheep are </w:t></w:r><w:r><w:t>fluffy.</w:t></w:r></w:p>`
+
+		text, err := extract.Markdown(newDOCX(t, body), extract.MediaTypeDOCX)
+		is.NotError(t, err)
+		is.Equal(t, "# Sheep\n\nSheep are fluffy.", text)
+	})
+
+	t.Run("returns an error for DOCX with a too large main document part", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               "word/document.xml",
+			Method:             zip.Store,
+			CompressedSize64:   1,
+			UncompressedSize64: 1 << 40,
+		})
+		is.NotError(t, err)
+		_, err = w.Write([]byte("<"))
+		is.NotError(t, err)
+		is.NotError(t, zw.Close())
+
+		_, err = extract.Markdown(buf.Bytes(), extract.MediaTypeDOCX)
+		is.True(t, err != nil)
+		is.True(t, strings.Contains(err.Error(), "larger than"))
+	})
+
+	t.Run("returns an error for unsupported media types", func(t *testing.T) {
+		_, err := extract.Markdown([]byte{}, "image/png")
+		is.True(t, errors.Is(err, extract.ErrUnsupportedMediaType))
+	})
+
+	t.Run("passes text of other media types through", func(t *testing.T) {
+		text, err := extract.Markdown([]byte(`{"animal": "sheep"}`+"\n"), "application/json")
+		is.NotError(t, err)
+		is.Equal(t, `{"animal": "sheep"}`, text)
+
+		text, err = extract.Markdown([]byte("animal=sheep"), "application/x-www-form-urlencoded")
+		is.NotError(t, err)
+		is.Equal(t, "animal=sheep", text)
+	})
+
+	t.Run("returns an error for binary data of other media types", func(t *testing.T) {
+		_, err := extract.Markdown([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}, "image/png")
+		is.True(t, errors.Is(err, extract.ErrUnsupportedMediaType))
+
+		_, err = extract.Markdown([]byte{'a', 0, 'b'}, "application/octet-stream")
+		is.True(t, errors.Is(err, extract.ErrUnsupportedMediaType))
+	})
+}
+
+// newDOCX with the given body XML in the main document part.
+func newDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	is.NotError(t, err)
+	_, err = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body + `</w:body></w:document>`))
+	is.NotError(t, err)
+	is.NotError(t, zw.Close())
+	return buf.Bytes()
+}
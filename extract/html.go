@@ -0,0 +1,236 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"maragu.dev/errors"
+)
+
+// boilerplate elements that are skipped entirely, because they are navigation, scripts, or similar.
+var boilerplate = map[atom.Atom]bool{
+	atom.Aside:    true,
+	atom.Button:   true,
+	atom.Footer:   true,
+	atom.Form:     true,
+	atom.Head:     true,
+	atom.Header:   true,
+	atom.Iframe:   true,
+	atom.Nav:      true,
+	atom.Noscript: true,
+	atom.Script:   true,
+	atom.Select:   true,
+	atom.Style:    true,
+	atom.Svg:      true,
+	atom.Template: true,
+}
+
+// htmlToMarkdown keeps the main content of an HTML page as Markdown, with headings, paragraphs, lists,
+// code blocks, and tables. If the page has a main or article element, only that is used.
+func htmlToMarkdown(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing HTML")
+	}
+
+	root := doc
+	if n := findElement(doc, atom.Main); n != nil {
+		root = n
+	} else if n := findElement(doc, atom.Article); n != nil {
+		root = n
+	}
+
+	var b strings.Builder
+	w := &htmlWriter{b: &b}
+	w.walk(root)
+
+	return collapseBlankLines(b.String()), nil
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+type htmlWriter struct {
+	b         *strings.Builder
+	listDepth int
+	pre       bool
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre {
+			w.b.WriteString(n.Data)
+			return
+		}
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" {
+			return
+		}
+		// Keep a single space between inline text nodes
+		if strings.TrimLeft(n.Data, " \t\n\r") != n.Data {
+			w.space()
+		}
+		w.b.WriteString(text)
+		if strings.TrimRight(n.Data, " \t\n\r") != n.Data {
+			w.space()
+		}
+		return
+
+	case html.ElementNode:
+		if boilerplate[n.DataAtom] {
+			return
+		}
+
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			level := int(n.Data[1] - '0')
+			w.block()
+			w.b.WriteString(strings.Repeat("#", level) + " ")
+			w.children(n)
+			w.block()
+			return
+
+		case atom.P, atom.Div, atom.Section, atom.Blockquote, atom.Dl, atom.Figure:
+			w.block()
+			w.children(n)
+			w.block()
+			return
+
+		case atom.Br:
+			w.b.WriteString("\n")
+			return
+
+		case atom.Ul, atom.Ol:
+			if w.listDepth == 0 {
+				w.block()
+			}
+			w.listDepth++
+			w.children(n)
+			w.listDepth--
+			if w.listDepth == 0 {
+				w.block()
+			}
+			return
+
+		case atom.Li:
+			w.line()
+			w.b.WriteString(strings.Repeat("  ", max(w.listDepth-1, 0)) + "- ")
+			w.children(n)
+			w.line()
+			return
+
+		case atom.Pre:
+			w.block()
+			w.b.WriteString("```\n")
+			w.pre = true
+			w.children(n)
+			w.pre = false
+			w.line()
+			w.b.WriteString("```")
+			w.block()
+			return
+
+		case atom.Table:
+			w.block()
+			w.table(n)
+			w.block()
+			return
+		}
+	}
+
+	w.children(n)
+}
+
+func (w *htmlWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+}
+
+// table as a Markdown table, with the first row as the header.
+func (w *htmlWriter) table(n *html.Node) {
+	var rows [][]string
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Tr {
+			var row []string
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.ElementNode && (c.DataAtom == atom.Td || c.DataAtom == atom.Th) {
+					row = append(row, strings.ReplaceAll(textContent(c), "|", `\|`))
+				}
+			}
+			rows = append(rows, row)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	for i, row := range rows {
+		w.b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			w.b.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+		}
+	}
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data + " ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// block ends the current block with a blank line, unless there already is one.
+func (w *htmlWriter) block() {
+	s := w.b.String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(s, "\n") {
+		w.b.WriteString("\n")
+		return
+	}
+	w.b.WriteString("\n\n")
+}
+
+// line ends the current line, unless it's already ended.
+func (w *htmlWriter) line() {
+	if !w.atLineStart() {
+		w.b.WriteString("\n")
+	}
+}
+
+// space between inline text, unless at the start of a line or after another space.
+func (w *htmlWriter) space() {
+	if !w.atLineStart() && !strings.HasSuffix(w.b.String(), " ") {
+		w.b.WriteString(" ")
+	}
+}
+
+func (w *htmlWriter) atLineStart() bool {
+	s := w.b.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
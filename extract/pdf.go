@@ -0,0 +1,50 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+	"maragu.dev/errors"
+)
+
+// pdfToMarkdown extracts the text layer of each page, with pages separated by blank lines.
+// Scanned PDFs without a text layer result in no text.
+func pdfToMarkdown(data []byte) (text string, err error) {
+	// The PDF library panics on some malformed input
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Newf("error reading PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", errors.Wrap(err, "error reading PDF")
+	}
+
+	var b strings.Builder
+	fonts := map[string]*pdf.Font{}
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+
+		pageText, err := p.GetPlainText(fonts)
+		if err != nil {
+			return "", errors.Wrap(err, "error getting text of page %v", i)
+		}
+		fmt.Fprintf(&b, "%v\n\n", strings.TrimSpace(pageText))
+	}
+
+	return collapseBlankLines(b.String()), nil
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/model"
+)
+
+type apiKeyGetter interface {
+	GetAPIKey(ctx context.Context, key string) (model.APIKey, error)
+}
+
+type apiKeyAdministrator interface {
+	CreateAPIKey(ctx context.Context, name, key string) (model.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]model.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+type apiKeyIDContextKey struct{}
+
+// APIKeys is Middleware that checks the API key of requests that have one as a bearer token.
+// Requests with a key that isn't issued, or is revoked, get HTTP 401 Unauthorized.
+// Requests without a key are let through, and are anonymous to the rate limits and quotas.
+func APIKeys(db apiKeyGetter, log *slog.Logger) httph.Middleware {
+	return func(next http.Handler) http.Handler {
+		return httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+			key := apiKey(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return nil
+			}
+
+			k, err := db.GetAPIKey(r.Context(), key)
+			if err != nil {
+				if errors.Is(err, model.ErrorAPIKeyNotFound) {
+					return httph.HTTPError{Code: http.StatusUnauthorized, Err: errors.New("invalid API key")}
+				}
+
+				log.InfoContext(r.Context(), "Error getting API key", "error", err)
+				return errors.Wrap(err, "error getting API key")
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyIDContextKey{}, k.ID)))
+			return nil
+		})
+	}
+}
+
+// apiKeyID of the API key of the request, as checked by [APIKeys], or the empty string if there is none.
+func apiKeyID(r *http.Request) string {
+	id, _ := r.Context().Value(apiKeyIDContextKey{}).(string)
+	return id
+}
+
+// CreateAPIKeyRequest to issue a new API key.
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateAPIKeyResponse has the new API key, which is only ever shown here.
+type CreateAPIKeyResponse struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+func (r CreateAPIKeyResponse) StatusCode() int {
+	return http.StatusCreated
+}
+
+// APIKeysResponse has all issued API keys, without the keys themselves.
+type APIKeysResponse struct {
+	Keys []model.APIKey `json:"keys"`
+}
+
+// APIKeysAdmin registers admin endpoints for API keys, which should be protected with the [Admin] middleware:
+//   - POST /admin/keys issues a new API key with a name.
+//   - GET /admin/keys lists the issued API keys.
+//   - DELETE /admin/keys/{id} revokes an API key.
+func APIKeysAdmin(mux chi.Router, db apiKeyAdministrator, log *slog.Logger) {
+	mux.Post("/admin/keys", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, req CreateAPIKeyRequest) (CreateAPIKeyResponse, error) {
+		key := model.NewAPIKey()
+		k, err := db.CreateAPIKey(r.Context(), req.Name, key)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error creating API key", "error", err)
+			return CreateAPIKeyResponse{}, errors.Wrap(err, "error creating API key")
+		}
+
+		return CreateAPIKeyResponse{APIKey: k, Key: key}, nil
+	}))
+
+	mux.Get("/admin/keys", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, _ any) (APIKeysResponse, error) {
+		keys, err := db.ListAPIKeys(r.Context())
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing API keys", "error", err)
+			return APIKeysResponse{}, errors.Wrap(err, "error listing API keys")
+		}
+
+		if keys == nil {
+			keys = []model.APIKey{}
+		}
+
+		return APIKeysResponse{Keys: keys}, nil
+	}))
+
+	mux.Delete("/admin/keys/{id:[a-f0-9]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		if err := db.RevokeAPIKey(r.Context(), chi.URLParam(r, "id")); err != nil {
+			if errors.Is(err, model.ErrorAPIKeyNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("API key not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error revoking API key", "error", err)
+			return errors.Wrap(err, "error revoking API key")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+}
@@ -0,0 +1,96 @@
+package http_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+	"app/sqltest"
+)
+
+func TestAPIKeys(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(http.APIKeys(&apiKeyGetterMock{keys: []string{"abc"}}, slog.New(slog.DiscardHandler)))
+	mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+
+	for _, test := range []struct {
+		name string
+		key  string
+		code int
+	}{
+		{"lets requests with an issued key through", "abc", stdhttp.StatusOK},
+		{"lets requests without a key through", "", stdhttp.StatusOK},
+		{"rejects requests with a key that is not issued", "def", stdhttp.StatusUnauthorized},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if test.key != "" {
+				req.Header.Set("Authorization", "Bearer "+test.key)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			is.Equal(t, test.code, w.Code)
+		})
+	}
+}
+
+func TestAPIKeysAdmin(t *testing.T) {
+	t.Run("issues, lists, and revokes API keys, which are then rejected", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		log := slog.New(slog.DiscardHandler)
+
+		mux := chi.NewRouter()
+		http.APIKeysAdmin(mux, db, log)
+		mux.Group(func(r chi.Router) {
+			r.Use(http.APIKeys(db, log))
+			r.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+		})
+
+		req := httptest.NewRequest("POST", "/admin/keys", strings.NewReader(`{"name": "Sheep"}`))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusCreated, w.Code)
+
+		var created http.CreateAPIKeyResponse
+		err := json.Unmarshal(w.Body.Bytes(), &created)
+		is.NotError(t, err)
+		is.Equal(t, "Sheep", created.Name)
+		is.True(t, strings.HasPrefix(created.Key, "sk_"))
+
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+created.Key)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		req = httptest.NewRequest("GET", "/admin/keys", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var keys http.APIKeysResponse
+		err = json.Unmarshal(w.Body.Bytes(), &keys)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(keys.Keys))
+		is.Equal(t, created.ID, keys.Keys[0].ID)
+		is.True(t, !strings.Contains(w.Body.String(), created.Key))
+
+		req = httptest.NewRequest("DELETE", "/admin/keys/"+created.ID, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+created.Key)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusUnauthorized, w.Code)
+	})
+}
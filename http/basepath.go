@@ -0,0 +1,28 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"maragu.dev/httph"
+)
+
+type contextKey string
+
+const basePathContextKey = contextKey("basePath")
+
+// BasePath is Middleware that stores the base path all routes are served under, so [link] can build links.
+func BasePath(basePath string) httph.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), basePathContextKey, basePath)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// link to the given path, prefixed with the base path from [BasePath], if any.
+func link(r *http.Request, path string) string {
+	basePath, _ := r.Context().Value(basePathContextKey).(string)
+	return basePath + path
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"io"
+	"net/http"
+
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+)
+
+// MaxBodySize is Middleware that limits request bodies to maxBytes.
+// Reading more than that results in an [http.MaxBytesError], see [readBody].
+func MaxBodySize(maxBytes int64) httph.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readBody reads the whole request body.
+// It returns an [httph.HTTPError] with HTTP 413 Request Entity Too Large if the body is over the max size,
+// and HTTP 400 Bad Request for other read errors.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, bodyError(err)
+	}
+	return body, nil
+}
+
+// bodyError maps an error from reading the request body to an [httph.HTTPError].
+func bodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return httph.HTTPError{Code: http.StatusRequestEntityTooLarge, Err: errors.Newf("request body larger than %v bytes", maxBytesErr.Limit)}
+	}
+	return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(err, "error reading request body")}
+}
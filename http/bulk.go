@@ -0,0 +1,143 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/ingest"
+	"app/model"
+)
+
+type bulkDocumentCreator interface {
+	CreateDocuments(ctx context.Context, docs []model.Document, chunks [][]model.Chunk) ([]model.Document, error)
+	CreateDocumentsIfNew(ctx context.Context, docs []model.Document, chunks [][]model.Chunk) ([]model.Document, []bool, error)
+	GetDocumentByContentHash(ctx context.Context, hash string) (model.Document, error)
+	CreateJob(ctx context.Context, name, payload, apiKey string) (model.Job, error)
+	GetJob(ctx context.Context, id model.ID) (model.Job, error)
+}
+
+// BulkJobResponse is the status of a background bulk ingestion job.
+// Results are included once the job is done.
+type BulkJobResponse struct {
+	ID      model.ID            `json:"id"`
+	Status  model.JobStatus     `json:"status"`
+	Error   string              `json:"error,omitempty"`
+	Results []ingest.BulkResult `json:"results,omitempty"`
+}
+
+func (r BulkJobResponse) StatusCode() int {
+	if r.Status == model.JobStatusPending || r.Status == model.JobStatusRunning {
+		return http.StatusAccepted
+	}
+	return http.StatusOK
+}
+
+// BulkDocuments registers a bulk ingestion endpoint at /documents/bulk, which takes NDJSON with one
+// [ingest.BulkDocument] per line.
+// By default, documents are created while the request is processed, and a [ingest.BulkResult] per line is streamed
+// back as NDJSON. With the query parameter async=true, the input is queued as a background job instead,
+// and the job status and results can be fetched from /documents/bulk/{id}.
+// The duplicates query parameter overrides [ingest.BulkOptions.DuplicatePolicy] for synchronous requests only,
+// since background jobs use the policy they were registered with.
+// Synchronous requests read the input while they process it, so their body can be up to maxBodyBytes,
+// and they run for as long as the input keeps flowing, see [Timeout].
+func BulkDocuments(mux chi.Router, db bulkDocumentCreator, ai embedder, opts ingest.BulkOptions, maxBodyBytes int64, log *slog.Logger) {
+	mux.With(MaxBodySize(maxBodyBytes)).Post("/documents/bulk", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/x-ndjson" {
+			return httph.HTTPError{Code: http.StatusUnsupportedMediaType, Err: errors.New("content type must be application/x-ndjson")}
+		}
+
+		async := r.URL.Query().Get("async") == "true"
+
+		if async && r.URL.Query().Has("duplicates") {
+			return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("duplicates can't be combined with async")}
+		}
+
+		if async {
+			data, err := readBody(r)
+			if err != nil {
+				return err
+			}
+
+			job, err := db.CreateJob(r.Context(), ingest.BulkJobName, string(data), apiKeyID(r))
+			if err != nil {
+				log.InfoContext(r.Context(), "Error creating bulk job", "error", err)
+				return errors.Wrap(err, "error creating bulk job")
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", link(r, "/documents/bulk/"+string(job.ID)))
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(BulkJobResponse{ID: job.ID, Status: job.Status})
+			return nil
+		}
+
+		opts := opts
+		var err error
+		opts.DuplicatePolicy, err = duplicatePolicyFromRequest(r, opts.DuplicatePolicy)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		err = ingest.Bulk(r.Context(), db, ai, streamBody(w, r), opts, func(res ingest.BulkResult) error {
+			extendWriteDeadline(w, r)
+			if err := enc.Encode(res); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			// The status code has already been sent, so report the error as a final result without a line number
+			log.InfoContext(r.Context(), "Error in bulk ingestion", "error", err)
+			extendWriteDeadline(w, r)
+			_ = enc.Encode(ingest.BulkResult{Error: err.Error()})
+		}
+
+		return nil
+	}))
+
+	mux.Get("/documents/bulk/{id:[a-z0-9_]+}", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, _ any) (BulkJobResponse, error) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id := model.ID(chi.URLParam(r, "id"))
+
+		job, err := db.GetJob(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorJobNotFound) {
+				return BulkJobResponse{}, httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("bulk job not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error getting bulk job", "error", err)
+			return BulkJobResponse{}, errors.Wrap(err, "error getting bulk job")
+		}
+
+		if job.Name != ingest.BulkJobName {
+			return BulkJobResponse{}, httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("bulk job not found")}
+		}
+
+		res := BulkJobResponse{ID: job.ID, Status: job.Status, Error: job.Error}
+		if job.Status == model.JobStatusDone {
+			if err := json.Unmarshal([]byte(job.Result), &res.Results); err != nil {
+				return BulkJobResponse{}, errors.Wrap(err, "error parsing bulk job results")
+			}
+		}
+
+		return res, nil
+	}))
+}
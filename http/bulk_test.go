@@ -0,0 +1,199 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/http"
+	"app/ingest"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestBulkDocuments(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("creates documents and streams results as NDJSON", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.BulkDocuments(mux, db, ai, ingest.BulkOptions{}, 1024*1024, log)
+
+		body := `{"content": "Sheep are fluffy."}` + "\n" + `{"content": ""}` + "\n"
+		req := httptest.NewRequest("POST", "/documents/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		is.Equal(t, 2, len(lines))
+
+		var res ingest.BulkResult
+		is.NotError(t, json.Unmarshal([]byte(lines[0]), &res))
+		is.Equal(t, 1, res.Line)
+
+		doc, err := db.GetDocument(t.Context(), res.ID)
+		is.NotError(t, err)
+		is.Equal(t, "Sheep are fluffy.", doc.Content)
+
+		is.NotError(t, json.Unmarshal([]byte(lines[1]), &res))
+		is.Equal(t, 2, res.Line)
+		is.Equal(t, "content is required", res.Error)
+	})
+
+	t.Run("streams results for longer than the server timeouts while the input is processed", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		mux.Use(http.Timeout(200*time.Millisecond, func(*stdhttp.Request) bool { return true }))
+		http.BulkDocuments(mux, db, &slowEmbedder{delay: 50 * time.Millisecond}, ingest.BulkOptions{BatchSize: 2}, 1024*1024, log)
+
+		server := httptest.NewUnstartedServer(mux)
+		server.Config.ReadTimeout = 200 * time.Millisecond
+		server.Config.WriteTimeout = 200 * time.Millisecond
+		server.Start()
+		defer server.Close()
+
+		var body strings.Builder
+		for i := range 20 {
+			_, _ = fmt.Fprintf(&body, `{"content": "Sheep number %v."}`+"\n", i)
+		}
+
+		res, err := stdhttp.Post(server.URL+"/documents/bulk", "application/x-ndjson", strings.NewReader(body.String()))
+		is.NotError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		is.Equal(t, stdhttp.StatusOK, res.StatusCode)
+
+		dec := json.NewDecoder(res.Body)
+		for i := range 20 {
+			var result ingest.BulkResult
+			is.NotError(t, dec.Decode(&result))
+			is.Equal(t, i+1, result.Line)
+			is.Equal(t, "", result.Error)
+		}
+	})
+
+	t.Run("queues a background job with async", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.BulkDocuments(mux, db, ai, ingest.BulkOptions{}, 1024*1024, log)
+
+		req := httptest.NewRequest("POST", "/documents/bulk?async=true", strings.NewReader(`{"content": "Sheep"}`))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusAccepted, w.Code)
+
+		var res http.BulkJobResponse
+		is.NotError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		is.Equal(t, model.JobStatusPending, res.Status)
+		is.Equal(t, "/documents/bulk/"+string(res.ID), w.Header().Get("Location"))
+
+		job, err := db.GetJob(t.Context(), res.ID)
+		is.NotError(t, err)
+		is.Equal(t, ingest.BulkJobName, job.Name)
+		is.Equal(t, `{"content": "Sheep"}`, job.Payload)
+
+		req = httptest.NewRequest("GET", "/documents/bulk/"+string(res.ID), nil)
+		w = httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusAccepted, w.Code)
+	})
+
+	t.Run("rejects a duplicates policy with async", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.BulkDocuments(mux, db, ai, ingest.BulkOptions{}, 1024*1024, log)
+
+		req := httptest.NewRequest("POST", "/documents/bulk?async=true&duplicates=reject", strings.NewReader(`{"content": "Sheep"}`))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+
+	t.Run("returns results of a done job", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.BulkDocuments(mux, db, ai, ingest.BulkOptions{}, 1024*1024, log)
+
+		job, err := db.CreateJob(t.Context(), ingest.BulkJobName, "", "")
+		is.NotError(t, err)
+		err = db.CompleteJob(t.Context(), job.ID, `[{"line":1,"id":"d_1"}]`)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/documents/bulk/"+string(job.ID), nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var res http.BulkJobResponse
+		is.NotError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		is.Equal(t, model.JobStatusDone, res.Status)
+		is.Equal(t, 1, len(res.Results))
+		is.Equal(t, model.ID("d_1"), res.Results[0].ID)
+	})
+
+	t.Run("rejects other content types", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.BulkDocuments(mux, db, ai, ingest.BulkOptions{}, 1024*1024, log)
+
+		req := httptest.NewRequest("POST", "/documents/bulk", strings.NewReader("Sheep"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("returns not found for unknown jobs", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.BulkDocuments(mux, db, ai, ingest.BulkOptions{}, 1024*1024, log)
+
+		req := httptest.NewRequest("GET", "/documents/bulk/j_unknown", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
+
+type slowEmbedder struct {
+	delay time.Duration
+}
+
+func (e *slowEmbedder) EmbedString(ctx context.Context, s string) ([]byte, error) {
+	time.Sleep(e.delay)
+	return make([]byte, 4*1024), nil
+}
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+	"maragu.dev/httph"
+
+	"app/model"
+	"app/rag"
+	"app/sql"
+)
+
+type conversationStore interface {
+	Search(ctx context.Context, query string, embedding []byte, opts sql.SearchOptions) ([]model.Chunk, error)
+	CreateConversation(ctx context.Context, title string) (model.Conversation, error)
+	GetConversation(ctx context.Context, id model.ID) (model.Conversation, error)
+	ListConversations(ctx context.Context) ([]model.Conversation, error)
+	DeleteConversation(ctx context.Context, id model.ID) error
+	ListMessages(ctx context.Context, conversationID model.ID) ([]model.Message, error)
+	AddMessages(ctx context.Context, ms []model.Message) ([]model.Message, error)
+	SaveConversationSummary(ctx context.Context, id model.ID, summary string, summarizedMessages int) error
+}
+
+type conversationAI interface {
+	retriever
+	ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error)
+	CondenseQuery(ctx context.Context, transcript, question string) (string, error)
+	SummarizeConversation(ctx context.Context, previousSummary, transcript string) (string, error)
+}
+
+// ConversationResponse is the JSON representation of a conversation, with its messages and their citations.
+type ConversationResponse struct {
+	ID       model.ID          `json:"id"`
+	Title    string            `json:"title,omitempty"`
+	Messages []MessageResponse `json:"messages"`
+}
+
+// MessageResponse is a message in a [ConversationResponse].
+// Assistant messages have the citations of the numbered markers in the content.
+type MessageResponse struct {
+	ID        model.ID          `json:"id"`
+	Role      model.MessageRole `json:"role"`
+	Content   string            `json:"content"`
+	Citations model.Citations   `json:"citations,omitempty"`
+}
+
+// Conversations registers endpoints for chatting with the documents:
+//   - POST /conversations creates a conversation, with an optional title query parameter.
+//   - GET /conversations lists conversations, most recently updated first.
+//   - GET /conversations/{id} gets the messages of a conversation, as a [ConversationResponse] if the Accept header
+//     asks for application/json.
+//   - DELETE /conversations/{id} deletes a conversation with all its messages.
+//   - POST /conversations/{id}/messages posts the request body as a user message, and streams the assistant reply.
+//     Replies run for as long as the reply keeps streaming, see [Timeout].
+//
+// Replies are generated with [rag.Reply], and cite their sources with numbered markers like [1],
+// which are listed with the document, chunk, and quote after the reply.
+// Clients that need the citations in a structured form get them from the JSON representation of the conversation.
+// Replies that don't cite any sources are handled by the ungrounded policy,
+// which can be overridden per request with the ungrounded query parameter.
+func Conversations(mux chi.Router, db conversationStore, ai conversationAI, ungrounded model.UngroundedPolicy, log *slog.Logger) {
+	mux.Post("/conversations", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		c, err := db.CreateConversation(r.Context(), strings.TrimSpace(r.URL.Query().Get("title")))
+		if err != nil {
+			log.InfoContext(r.Context(), "Error creating conversation", "error", err)
+			return errors.Wrap(err, "error creating conversation")
+		}
+
+		w.Header().Set("Location", link(r, "/conversations/"+string(c.ID)))
+		w.WriteHeader(http.StatusCreated)
+
+		return nil
+	}))
+
+	mux.Get("/conversations", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		cs, err := db.ListConversations(r.Context())
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing conversations", "error", err)
+			return errors.Wrap(err, "error listing conversations")
+		}
+
+		for _, c := range cs {
+			title := c.Title
+			if title == "" {
+				title = string(c.ID)
+			}
+			_, _ = w.Write([]byte(fmt.Sprintf("- [%v](%v) (%v)\n", title, link(r, "/conversations/"+string(c.ID)), c.Updated.T.Format("2006-01-02 15:04"))))
+		}
+
+		return nil
+	}))
+
+	mux.Get("/conversations/{id:[a-z0-9_]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		c, err := db.GetConversation(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorConversationNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("conversation not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error getting conversation", "error", err)
+			return errors.Wrap(err, "error getting conversation")
+		}
+
+		messages, err := db.ListMessages(r.Context(), id)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing messages", "error", err)
+			return errors.Wrap(err, "error listing messages")
+		}
+
+		if acceptsJSON(r) {
+			res := ConversationResponse{ID: c.ID, Title: c.Title, Messages: []MessageResponse{}}
+			for _, m := range messages {
+				res.Messages = append(res.Messages, MessageResponse{ID: m.ID, Role: m.Role, Content: m.Content, Citations: m.Citations})
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			return json.NewEncoder(w).Encode(res)
+		}
+
+		if c.Title != "" {
+			_, _ = w.Write([]byte("# " + c.Title + "\n\n"))
+		}
+
+		for _, m := range messages {
+			role := "User"
+			if m.Role == model.MessageRoleAssistant {
+				role = "Assistant"
+			}
+			_, _ = w.Write([]byte("## " + role + "\n\n" + m.Content + "\n\n"))
+			if len(m.Citations) > 0 {
+				_, _ = w.Write([]byte(citationList(r, m.Citations) + "\n"))
+			}
+		}
+
+		return nil
+	}))
+
+	mux.Delete("/conversations/{id:[a-z0-9_]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		if err := db.DeleteConversation(r.Context(), id); err != nil {
+			if errors.Is(err, model.ErrorConversationNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("conversation not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error deleting conversation", "error", err)
+			return errors.Wrap(err, "error deleting conversation")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+
+	mux.Post("/conversations/{id:[a-z0-9_]+}/messages", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		body, err := readBody(r)
+		if err != nil {
+			return err
+		}
+
+		content := strings.TrimSpace(string(body))
+		if content == "" {
+			return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("message is empty")}
+		}
+
+		policy, err := ungroundedPolicyFromRequest(r, ungrounded)
+		if err != nil {
+			return err
+		}
+
+		answer, err := rag.Reply(r.Context(), db, ai, id, content, rag.ReplyOptions{Ungrounded: policy})
+		extendWriteDeadline(w, r)
+		if err != nil {
+			if errors.Is(err, model.ErrorConversationNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("conversation not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error replying", "error", err)
+			return aiError(w, err, http.StatusBadGateway, "error replying")
+		}
+
+		// The status code is sent with the first part of the reply, so errors after that can only be logged.
+		// The reply is always ranged over, since that's what releases the chat completion, see [rag.Answer.Text].
+		flusher, _ := w.(http.Flusher)
+		for part, err := range answer.Text() {
+			if err != nil {
+				log.InfoContext(r.Context(), "Error streaming reply", "error", err)
+				return nil
+			}
+
+			extendWriteDeadline(w, r)
+			_, _ = w.Write([]byte(part))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if len(answer.Citations) > 0 {
+			extendWriteDeadline(w, r)
+			_, _ = w.Write([]byte("\n\n" + citationList(r, answer.Citations)))
+		}
+
+		return nil
+	}))
+}
+
+// citationList as a Markdown list with links to the cited documents, and the quotes on a single line each.
+func citationList(r *http.Request, citations model.Citations) string {
+	var b strings.Builder
+	for _, c := range citations {
+		quote := strings.Join(strings.Fields(c.Quote), " ")
+		b.WriteString(fmt.Sprintf("- [%v] [%v](%v), chunk %v: \"%v\"\n", c.Number, c.DocumentID, link(r, "/documents/"+string(c.DocumentID)), c.ChunkIndex, quote))
+	}
+	return b.String()
+}
+
+// acceptsJSON if the Accept header of the request asks for application/json.
+func acceptsJSON(r *http.Request) bool {
+	for _, mediaType := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		if strings.TrimSpace(mediaType) == "application/json" {
+			return true
+		}
+	}
+	return false
+}
+
+// ungroundedPolicyFromRequest from the ungrounded query parameter, or the given default if there is none.
+// An empty default means [model.UngroundedPolicyAllow].
+func ungroundedPolicyFromRequest(r *http.Request, defaultPolicy model.UngroundedPolicy) (model.UngroundedPolicy, error) {
+	policy := model.UngroundedPolicy(r.URL.Query().Get("ungrounded"))
+	if policy == "" {
+		policy = defaultPolicy
+	}
+	if policy == "" {
+		return model.UngroundedPolicyAllow, nil
+	}
+	if !policy.Valid() {
+		return "", httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("ungrounded must be allow, flag, or refuse")}
+	}
+	return policy, nil
+}
@@ -0,0 +1,134 @@
+package http_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestConversations(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("creates, lists, gets, and deletes conversations", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Conversations(mux, db, ai, model.UngroundedPolicyAllow, log)
+
+		req := httptest.NewRequest("POST", "/conversations?title=Sheep", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusCreated, w.Code)
+		location := w.Header().Get("Location")
+		is.True(t, strings.HasPrefix(location, "/conversations/cv_"))
+		id := model.ID(strings.TrimPrefix(location, "/conversations/"))
+
+		_, err := db.AddMessage(t.Context(), model.Message{ConversationID: id, Role: model.MessageRoleUser, Content: "What do sheep eat?"})
+		is.NotError(t, err)
+		_, err = db.AddMessage(t.Context(), model.Message{ConversationID: id, Role: model.MessageRoleAssistant, Content: "Grass [1].", Citations: model.Citations{
+			{Number: 1, DocumentID: "d_1", ChunkIndex: 2, Quote: "Sheep eat grass."},
+		}})
+		is.NotError(t, err)
+
+		req = httptest.NewRequest("GET", "/conversations", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.True(t, strings.HasPrefix(w.Body.String(), "- [Sheep]("+location+")"))
+
+		req = httptest.NewRequest("GET", location, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "# Sheep\n\n## User\n\nWhat do sheep eat?\n\n## Assistant\n\nGrass [1].\n\n"+
+			"- [1] [d_1](/documents/d_1), chunk 2: \"Sheep eat grass.\"\n\n", w.Body.String())
+
+		req = httptest.NewRequest("GET", location, nil)
+		req.Header.Set("Accept", "application/json")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var res http.ConversationResponse
+		err = json.Unmarshal(w.Body.Bytes(), &res)
+		is.NotError(t, err)
+		is.Equal(t, id, res.ID)
+		is.Equal(t, "Sheep", res.Title)
+		is.Equal(t, 2, len(res.Messages))
+		is.Equal(t, model.MessageRoleAssistant, res.Messages[1].Role)
+		is.Equal(t, "Grass [1].", res.Messages[1].Content)
+		is.Equal(t, 1, len(res.Messages[1].Citations))
+		is.Equal(t, model.Citation{Number: 1, DocumentID: "d_1", ChunkIndex: 2, Quote: "Sheep eat grass."}, res.Messages[1].Citations[0])
+
+		req = httptest.NewRequest("DELETE", location, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("GET", location, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+
+	t.Run("errors on posting a message to a conversation that does not exist", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Conversations(mux, db, ai, model.UngroundedPolicyAllow, log)
+
+		req := httptest.NewRequest("POST", "/conversations/cv_missing/messages", strings.NewReader("Hi"))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+
+	t.Run("errors on posting an empty message", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Conversations(mux, db, ai, model.UngroundedPolicyAllow, log)
+
+		c, err := db.CreateConversation(t.Context(), "")
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("POST", "/conversations/"+string(c.ID)+"/messages", strings.NewReader(" \n"))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+	t.Run("errors on invalid ungrounded policy", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Conversations(mux, db, ai, model.UngroundedPolicyAllow, log)
+
+		c, err := db.CreateConversation(t.Context(), "")
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("POST", "/conversations/"+string(c.ID)+"/messages?ungrounded=maybe", strings.NewReader("Hi"))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+}
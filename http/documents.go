@@ -2,24 +2,30 @@ package http
 
 import (
 	"context"
-	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"maragu.dev/errors"
 	"maragu.dev/httph"
 
+	"app/dedup"
 	"app/model"
 	"app/sql"
 )
 
 type documentCRUDer interface {
 	CreateDocument(ctx context.Context, d model.Document, chunks []model.Chunk) (model.Document, error)
+	CreateDocumentIfNew(ctx context.Context, d model.Document, chunks []model.Chunk) (model.Document, bool, error)
+	GetDocumentByContentHash(ctx context.Context, hash string) (model.Document, error)
 	ListDocuments(ctx context.Context, opts sql.ListDocumentsOptions) ([]model.Document, error)
 	GetDocument(ctx context.Context, id model.ID) (model.Document, error)
-	UpdateDocument(ctx context.Context, d model.Document, chunks []model.Chunk) (model.Document, error)
+	GetDocumentAt(ctx context.Context, id model.ID, t time.Time) (model.Document, error)
+	UpdateDocument(ctx context.Context, d model.Document, chunks []model.Chunk, change model.Change) (model.Document, error)
 	DeleteDocument(ctx context.Context, id model.ID) error
 }
 
@@ -27,28 +33,64 @@ type embedder interface {
 	EmbedString(ctx context.Context, s string) ([]byte, error)
 }
 
-func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger) {
+// Documents registers the document endpoints.
+// Getting a document with the at query parameter, an RFC 3339 time, returns the content the document had at that time.
+// Updating a document records the previous content as a version, with the optional author and comment query parameters.
+// Deleting a document moves it to the trash, see [Trash].
+// Listing documents with the tag query parameter only lists documents with that tag, see [Tags].
+// Listed documents include their summary if they have one, see [Summaries].
+// Creating a document that has the same content as an existing one is handled by the duplicates policy,
+// which can be overridden per request with the duplicates query parameter.
+// With [model.DuplicatePolicyReject], the response is HTTP 409 Conflict, and with [model.DuplicatePolicyExisting],
+// it's HTTP 200 OK. Both have the Location of the existing document.
+func Documents(mux chi.Router, db documentCRUDer, ai embedder, duplicates model.DuplicatePolicy, log *slog.Logger) {
 	mux.Post("/documents", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
-		body, err := io.ReadAll(r.Body)
+		doc, err := documentFromRequest(r)
 		if err != nil {
-			return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(err, "error reading request body")}
+			return err
 		}
 
-		doc := model.Document{
-			Content: string(body),
+		policy, err := duplicatePolicyFromRequest(r, duplicates)
+		if err != nil {
+			return err
+		}
+
+		// Check for duplicates before chunking, so duplicates aren't embedded
+		if policy != model.DuplicatePolicyAllow {
+			existing, err := db.GetDocumentByContentHash(r.Context(), dedup.ContentHash(doc.Content))
+			switch {
+			case err == nil:
+				return duplicateDocument(w, r, existing, policy)
+
+			case !errors.Is(err, model.ErrorDocumentNotFound):
+				log.InfoContext(r.Context(), "Error getting document by content hash", "error", err)
+				return errors.Wrap(err, "error getting document by content hash")
+			}
 		}
 
 		chunks, err := doc.Chunk(r.Context(), ai.EmbedString)
 		if err != nil {
-			log.Info("Error creating document chunks", "error", err)
-			return httph.HTTPError{Err: errors.Wrap(err, "error creating document chunks"), Code: http.StatusBadGateway}
+			log.InfoContext(r.Context(), "Error creating document chunks", "error", err)
+			return aiError(w, err, http.StatusBadGateway, "error creating document chunks")
 		}
 
-		if _, err := db.CreateDocument(r.Context(), doc, chunks); err != nil {
-			log.Info("Error creating document", "error", err)
+		// Check for duplicates again when creating the document, in case one was created concurrently
+		created := true
+		if policy == model.DuplicatePolicyAllow {
+			doc, err = db.CreateDocument(r.Context(), doc, chunks)
+		} else {
+			doc, created, err = db.CreateDocumentIfNew(r.Context(), doc, chunks)
+		}
+		if err != nil {
+			log.InfoContext(r.Context(), "Error creating document", "error", err)
 			return errors.Wrap(err, "error creating document")
 		}
 
+		if !created {
+			return duplicateDocument(w, r, doc, policy)
+		}
+
+		w.Header().Set("Location", link(r, "/documents/"+string(doc.ID)))
 		w.WriteHeader(http.StatusCreated)
 
 		return nil
@@ -58,6 +100,15 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 		limitStr := r.URL.Query().Get("limit")
 		cursor := model.ID(r.URL.Query().Get("cursor"))
 
+		var tag string
+		if r.URL.Query().Has("tag") {
+			var ok bool
+			tag, ok = model.NormalizeTag(r.URL.Query().Get("tag"))
+			if !ok {
+				return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("invalid tag")}
+			}
+		}
+
 		var limit int
 		if limitStr != "" {
 			n, err := strconv.Atoi(limitStr)
@@ -70,21 +121,26 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 		docs, err := db.ListDocuments(r.Context(), sql.ListDocumentsOptions{
 			Limit:  limit,
 			Cursor: cursor,
+			Tag:    tag,
 		})
 		if err != nil {
-			log.Info("Error listing documents", "error", err)
+			log.InfoContext(r.Context(), "Error listing documents", "error", err)
 			return errors.Wrap(err, "error listing documents")
 		}
 
 		// Write the document list as markdown links
 		for _, doc := range docs {
-			_, _ = w.Write([]byte("- [" + string(doc.ID) + "](/documents/" + string(doc.ID) + ")\n"))
+			_, _ = w.Write([]byte(documentListItem(r, doc)))
 		}
 
 		// If we have documents and there might be more, include pagination hint
 		if len(docs) > 0 && len(docs) == limit {
 			lastID := docs[len(docs)-1].ID
-			_, _ = w.Write([]byte("\n[Next Page](/documents?cursor=" + string(lastID) + "&limit=" + limitStr + ")\n"))
+			next := "/documents?cursor=" + string(lastID) + "&limit=" + limitStr
+			if tag != "" {
+				next += "&tag=" + url.QueryEscape(tag)
+			}
+			_, _ = w.Write([]byte("\n[Next Page](" + link(r, next) + ")\n"))
 		}
 
 		return nil
@@ -93,7 +149,17 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 	mux.Get("/documents/{id:[a-z0-9_]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
 		id := model.ID(chi.URLParam(r, "id"))
 
-		doc, err := db.GetDocument(r.Context(), id)
+		var doc model.Document
+		var err error
+		if at := r.URL.Query().Get("at"); at != "" {
+			t, parseErr := time.Parse(time.RFC3339Nano, at)
+			if parseErr != nil {
+				return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(parseErr, "invalid at time")}
+			}
+			doc, err = db.GetDocumentAt(r.Context(), id, t)
+		} else {
+			doc, err = db.GetDocument(r.Context(), id)
+		}
 		if err != nil {
 			if errors.Is(err, model.ErrorDocumentNotFound) {
 				return httph.HTTPError{
@@ -102,7 +168,7 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 				}
 			}
 
-			log.Info("Error getting document", "error", err)
+			log.InfoContext(r.Context(), "Error getting document", "error", err)
 			return errors.Wrap(err, "error getting document")
 		}
 
@@ -114,23 +180,19 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 	mux.Put("/documents/{id:[a-z0-9_]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
 		id := model.ID(chi.URLParam(r, "id"))
 
-		body, err := io.ReadAll(r.Body)
+		doc, err := documentFromRequest(r)
 		if err != nil {
-			return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(err, "error reading request body")}
-		}
-
-		doc := model.Document{
-			ID:      id,
-			Content: string(body),
+			return err
 		}
+		doc.ID = id
 
 		chunks, err := doc.Chunk(r.Context(), ai.EmbedString)
 		if err != nil {
-			log.Info("Error creating document chunks", "error", err)
-			return httph.HTTPError{Err: errors.Wrap(err, "error creating document chunks"), Code: http.StatusBadGateway}
+			log.InfoContext(r.Context(), "Error creating document chunks", "error", err)
+			return aiError(w, err, http.StatusBadGateway, "error creating document chunks")
 		}
 
-		if _, err = db.UpdateDocument(r.Context(), doc, chunks); err != nil {
+		if _, err = db.UpdateDocument(r.Context(), doc, chunks, changeFromRequest(r)); err != nil {
 			if errors.Is(err, model.ErrorDocumentNotFound) {
 				return httph.HTTPError{
 					Code: http.StatusNotFound,
@@ -138,11 +200,11 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 				}
 			}
 
-			log.Info("Error updating document", "error", err)
+			log.InfoContext(r.Context(), "Error updating document", "error", err)
 			return errors.Wrap(err, "error updating document")
 		}
 
-		_, _ = w.Write(body)
+		_, _ = w.Write([]byte(doc.Content))
 
 		return nil
 	}))
@@ -158,7 +220,7 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 				}
 			}
 
-			log.Info("Error deleting document", "error", err)
+			log.InfoContext(r.Context(), "Error deleting document", "error", err)
 			return errors.Wrap(err, "error deleting document")
 		}
 
@@ -166,3 +228,46 @@ func Documents(mux chi.Router, db documentCRUDer, ai embedder, log *slog.Logger)
 		return nil
 	}))
 }
+
+// duplicateDocument responds for a document with the same content as the existing one, according to the policy.
+func duplicateDocument(w http.ResponseWriter, r *http.Request, existing model.Document, policy model.DuplicatePolicy) error {
+	w.Header().Set("Location", link(r, "/documents/"+string(existing.ID)))
+	if policy == model.DuplicatePolicyReject {
+		return httph.HTTPError{Code: http.StatusConflict, Err: errors.New("document with the same content already exists")}
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// duplicatePolicyFromRequest from the duplicates query parameter, or the given default if there is none.
+// An empty default means [model.DuplicatePolicyAllow].
+func duplicatePolicyFromRequest(r *http.Request, defaultPolicy model.DuplicatePolicy) (model.DuplicatePolicy, error) {
+	policy := model.DuplicatePolicy(r.URL.Query().Get("duplicates"))
+	if policy == "" {
+		policy = defaultPolicy
+	}
+	if policy == "" {
+		return model.DuplicatePolicyAllow, nil
+	}
+	if !policy.Valid() {
+		return "", httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("duplicates must be allow, reject, or existing")}
+	}
+	return policy, nil
+}
+
+// changeFromRequest with the author and comment query parameters.
+func changeFromRequest(r *http.Request) model.Change {
+	return model.Change{
+		Author:  r.URL.Query().Get("author"),
+		Comment: r.URL.Query().Get("comment"),
+	}
+}
+
+// documentListItem is a Markdown list item with a link to the document, and its summary on one line if it has one.
+func documentListItem(r *http.Request, doc model.Document) string {
+	item := "- [" + string(doc.ID) + "](" + link(r, "/documents/"+string(doc.ID)) + ")"
+	if doc.Summary != "" {
+		item += ": " + strings.Join(strings.Fields(doc.Summary), " ")
+	}
+	return item + "\n"
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"log/slog"
+	"mime/multipart"
 	stdhttp "net/http"
 	"net/http/httptest"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"app/aitest"
 	"app/http"
 	"app/model"
+	"app/sql"
 	"app/sqltest"
 )
 
@@ -25,7 +27,7 @@ func TestDocuments(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		content := "Test document"
 		reqBodyBytes := []byte(content)
@@ -38,13 +40,75 @@ func TestDocuments(t *testing.T) {
 
 		is.Equal(t, stdhttp.StatusCreated, w.Code)
 		is.Equal(t, 0, w.Body.Len()) // No response body expected
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+		is.Equal(t, "/documents/"+string(docs[0].ID), w.Header().Get("Location"))
+	})
+
+	t.Run("create duplicate document with reject policy", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyReject, log)
+
+		existing, err := db.CreateDocument(t.Context(), model.Document{Content: "Test document"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("POST", "/documents", strings.NewReader("Test document"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusConflict, w.Code)
+		is.Equal(t, "/documents/"+string(existing.ID), w.Header().Get("Location"))
+	})
+
+	t.Run("create duplicate document with existing policy from the query", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		existing, err := db.CreateDocument(t.Context(), model.Document{Content: "Test document"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("POST", "/documents?duplicates=existing", strings.NewReader("Test document"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "/documents/"+string(existing.ID), w.Header().Get("Location"))
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+	})
+
+	t.Run("create document with invalid duplicates policy", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		req := httptest.NewRequest("POST", "/documents?duplicates=maybe", strings.NewReader("Test document"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
 	})
 
 	t.Run("list documents", func(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		// First create a document
 		doc := model.Document{Content: "Test document"}
@@ -64,11 +128,128 @@ func TestDocuments(t *testing.T) {
 		is.Equal(t, expectedLink, w.Body.String())
 	})
 
+	t.Run("list documents by tag", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		var ids []model.ID
+		for _, content := range []string{"Sheep", "Goat", "Tractor"} {
+			doc, err := db.CreateDocument(t.Context(), model.Document{Content: content}, nil)
+			is.NotError(t, err)
+			ids = append(ids, doc.ID)
+		}
+		is.NotError(t, db.AddDocumentTags(t.Context(), ids[0], []string{"animals"}))
+		is.NotError(t, db.AddDocumentTags(t.Context(), ids[1], []string{"animals"}))
+
+		req := httptest.NewRequest("GET", "/documents?tag=Animals&limit=1", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		expected := "- [" + string(ids[0]) + "](/documents/" + string(ids[0]) + ")\n" +
+			"\n[Next Page](/documents?cursor=" + string(ids[0]) + "&limit=1&tag=animals)\n"
+		is.Equal(t, expected, w.Body.String())
+
+		req = httptest.NewRequest("GET", "/documents?tag=-", nil)
+		w = httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+
+	t.Run("list documents with base path", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		mux.Route("/api", func(r chi.Router) {
+			r.Use(http.BasePath("/api"))
+			http.Documents(r, db, ai, model.DuplicatePolicyAllow, log)
+		})
+
+		createdDoc, err := db.CreateDocument(t.Context(), model.Document{Content: "Test document"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/api/documents", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		expectedLink := "- [" + string(createdDoc.ID) + "](/api/documents/" + string(createdDoc.ID) + ")\n"
+		is.Equal(t, expectedLink, w.Body.String())
+	})
+
+	t.Run("create document with too large body", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		mux.Use(http.MaxBodySize(8))
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		req := httptest.NewRequest("POST", "/documents", strings.NewReader("This is more than eight bytes"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("create document from multipart HTML upload", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("file", "sheep.html")
+		is.NotError(t, err)
+		_, err = fw.Write([]byte("<html><body><nav>Menu</nav><h1>Sheep</h1><p>Sheep are fluffy.</p></body></html>"))
+		is.NotError(t, err)
+		is.NotError(t, mw.Close())
+
+		req := httptest.NewRequest("POST", "/documents", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusCreated, w.Code)
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+		is.Equal(t, "# Sheep\n\nSheep are fluffy.", docs[0].Content)
+		is.Equal(t, "sheep.html", docs[0].Metadata["filename"])
+		is.Equal(t, "text/html", docs[0].Metadata["mediaType"])
+	})
+
+	t.Run("create document with unsupported media type", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		req := httptest.NewRequest("POST", "/documents", bytes.NewReader([]byte{0x89, 'P', 'N', 'G'}))
+		req.Header.Set("Content-Type", "image/png")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusUnsupportedMediaType, w.Code)
+	})
+
 	t.Run("get document", func(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		// First create a document
 		doc := model.Document{Content: "Test document"}
@@ -87,11 +268,35 @@ func TestDocuments(t *testing.T) {
 		is.Equal(t, createdDoc.Content, w.Body.String())
 	})
 
+	t.Run("get document at a time before it existed", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		createdDoc, err := db.CreateDocument(t.Context(), model.Document{Content: "Test document"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/documents/"+string(createdDoc.ID)+"?at=2000-01-01T00:00:00Z", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+
+		req = httptest.NewRequest("GET", "/documents/"+string(createdDoc.ID)+"?at=yesterday", nil)
+		w = httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+
 	t.Run("update document", func(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		// First create a document
 		doc := model.Document{Content: "Test document"}
@@ -118,7 +323,7 @@ func TestDocuments(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		// First create a document
 		doc := model.Document{Content: "Test document"}
@@ -145,7 +350,7 @@ func TestDocuments(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		// Use an ID with invalid characters (uppercase and hyphen not allowed)
 		req := httptest.NewRequest("GET", "/documents/INVALID-ID", nil)
@@ -160,7 +365,7 @@ func TestDocuments(t *testing.T) {
 		db := sqltest.NewDatabase(t)
 		ai := aitest.NewClient(t)
 		mux := chi.NewRouter()
-		http.Documents(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
 
 		// Create a document with content that should be chunked
 		content := "This is paragraph one.\n\nThis is paragraph two.\n\nThis is paragraph three."
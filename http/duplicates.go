@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/dedup"
+	"app/model"
+)
+
+type duplicateMerger interface {
+	ListFingerprints(ctx context.Context) ([]dedup.Fingerprint, error)
+	MergeDocuments(ctx context.Context, keep model.ID, duplicates []model.ID) (model.Document, error)
+}
+
+// DuplicatesResponse has groups of near-duplicate document IDs.
+type DuplicatesResponse struct {
+	Groups [][]model.ID `json:"groups"`
+}
+
+// MergeDuplicatesRequest to keep one document and move its duplicates to the trash.
+type MergeDuplicatesRequest struct {
+	Keep       model.ID   `json:"keep"`
+	Duplicates []model.ID `json:"duplicates"`
+}
+
+func (r MergeDuplicatesRequest) Validate() error {
+	if r.Keep == "" {
+		return errors.New("keep is required")
+	}
+	if len(r.Duplicates) == 0 {
+		return errors.New("duplicates are required")
+	}
+	return nil
+}
+
+// MergeDuplicatesResponse has the ID of the kept document.
+type MergeDuplicatesResponse struct {
+	ID model.ID `json:"id"`
+}
+
+// Duplicates registers a near-duplicate report at /duplicates, with groups of documents whose [dedup.SimHash]
+// fingerprints are within the distance query parameter of each other (default 3, maximum [dedup.MaxDistance]),
+// and an endpoint at /duplicates/merge to merge a group into one document.
+func Duplicates(mux chi.Router, db duplicateMerger, log *slog.Logger) {
+	mux.Get("/duplicates", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, _ any) (DuplicatesResponse, error) {
+		distance := 3
+		if v := r.URL.Query().Get("distance"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > dedup.MaxDistance {
+				return DuplicatesResponse{}, httph.HTTPError{Code: http.StatusBadRequest,
+					Err: errors.Newf("distance must be between 0 and %v", dedup.MaxDistance)}
+			}
+			distance = n
+		}
+
+		fingerprints, err := db.ListFingerprints(r.Context())
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing fingerprints", "error", err)
+			return DuplicatesResponse{}, errors.Wrap(err, "error listing fingerprints")
+		}
+
+		groups := dedup.Groups(fingerprints, distance)
+		if groups == nil {
+			groups = [][]model.ID{}
+		}
+
+		return DuplicatesResponse{Groups: groups}, nil
+	}))
+
+	mux.Post("/duplicates/merge", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, req MergeDuplicatesRequest) (MergeDuplicatesResponse, error) {
+		doc, err := db.MergeDocuments(r.Context(), req.Keep, req.Duplicates)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return MergeDuplicatesResponse{}, httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error merging documents", "error", err)
+			return MergeDuplicatesResponse{}, errors.Wrap(err, "error merging documents")
+		}
+
+		return MergeDuplicatesResponse{ID: doc.ID}, nil
+	}))
+}
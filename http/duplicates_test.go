@@ -0,0 +1,98 @@
+package http_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDuplicates(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("reports groups of near-duplicates", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Duplicates(mux, db, log)
+
+		a, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep are fluffy and goats are not."}, nil)
+		is.NotError(t, err)
+		b, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep are fluffy, and goats are not!"}, nil)
+		is.NotError(t, err)
+		_, err = db.CreateDocument(t.Context(), model.Document{Content: "Something completely different about cows."}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/duplicates?distance=0", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var res http.DuplicatesResponse
+		is.NotError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		is.Equal(t, 1, len(res.Groups))
+		is.EqualSlice(t, []model.ID{a.ID, b.ID}, res.Groups[0])
+	})
+
+	t.Run("rejects invalid distances", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Duplicates(mux, db, log)
+
+		req := httptest.NewRequest("GET", "/duplicates?distance=8", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+
+	t.Run("merges duplicates", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Duplicates(mux, db, log)
+
+		keep, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		duplicate, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		body := `{"keep": "` + string(keep.ID) + `", "duplicates": ["` + string(duplicate.ID) + `"]}`
+		req := httptest.NewRequest("POST", "/duplicates/merge", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var res http.MergeDuplicatesResponse
+		is.NotError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		is.Equal(t, keep.ID, res.ID)
+
+		_, err = db.GetDocument(t.Context(), duplicate.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+
+	t.Run("returns not found when merging unknown documents", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Duplicates(mux, db, log)
+
+		req := httptest.NewRequest("POST", "/duplicates/merge", strings.NewReader(`{"keep": "d_unknown", "duplicates": ["d_other"]}`))
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/model"
+)
+
+type entityBrowser interface {
+	ListEntities(ctx context.Context, limit int) ([]model.EntityCount, error)
+	ListEntityDocuments(ctx context.Context, name string) ([]model.Document, error)
+}
+
+// Entities registers endpoints for browsing named entities extracted from documents, see [jobs.Extractor]:
+//   - GET /entities lists the most mentioned entities with their type and number of documents, as Markdown links.
+//   - GET /entities/{name} lists the documents that mention an entity, regardless of case, most mentions first.
+func Entities(mux chi.Router, db entityBrowser, log *slog.Logger) {
+	mux.Get("/entities", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		entities, err := db.ListEntities(r.Context(), 100)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing entities", "error", err)
+			return errors.Wrap(err, "error listing entities")
+		}
+
+		for _, e := range entities {
+			_, _ = w.Write([]byte(fmt.Sprintf("- [%v](%v) (%v, %v)\n", e.Name, link(r, "/entities/"+url.PathEscape(e.Name)), e.Type, e.Count)))
+		}
+
+		return nil
+	}))
+
+	mux.Get("/entities/{name}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		name := chi.URLParam(r, "name")
+
+		// chi routes on the raw path if it's set, which is when the path has escaped characters like %20
+		if r.URL.RawPath != "" {
+			var err error
+			name, err = url.PathUnescape(name)
+			if err != nil {
+				return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(err, "invalid entity name")}
+			}
+		}
+
+		docs, err := db.ListEntityDocuments(r.Context(), name)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing entity documents", "error", err)
+			return errors.Wrap(err, "error listing entity documents")
+		}
+
+		if len(docs) == 0 {
+			return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("entity not found")}
+		}
+
+		for _, doc := range docs {
+			_, _ = w.Write([]byte(documentListItem(r, doc)))
+		}
+
+		return nil
+	}))
+}
@@ -0,0 +1,60 @@
+package http_test
+
+import (
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestEntities(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("lists entities and the documents that mention them", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Entities(mux, db, log)
+
+		doc := model.Document{Content: "Ada Lovelace lived in London."}
+		chunks, err := doc.Chunk(t.Context(), ai.EmbedString)
+		is.NotError(t, err)
+		doc, err = db.CreateDocument(t.Context(), doc, chunks)
+		is.NotError(t, err)
+
+		chunks, err = db.ListUnextractedChunks(t.Context(), 10)
+		is.NotError(t, err)
+		err = db.SaveChunkExtraction(t.Context(), chunks[0], model.Extraction{
+			Entities: []model.Entity{{Name: "Ada Lovelace", Type: model.EntityTypePerson}},
+		})
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/entities", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "- [Ada Lovelace](/entities/Ada%20Lovelace) (person, 1)\n", w.Body.String())
+
+		req = httptest.NewRequest("GET", "/entities/ada%20lovelace", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "- ["+string(doc.ID)+"](/documents/"+string(doc.ID)+")\n", w.Body.String())
+
+		req = httptest.NewRequest("GET", "/entities/Charles%20Babbage", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/model"
+)
+
+type externalDocumentUpserter interface {
+	UpsertDocumentByExternalID(ctx context.Context, doc model.Document, chunks []model.Chunk, change model.Change) (model.Document, bool, error)
+	GetDocumentByExternalID(ctx context.Context, externalID string) (model.Document, error)
+	DeleteDocumentByExternalID(ctx context.Context, externalID string) error
+}
+
+// ExternalDocuments registers endpoints at /documents/by-external-id/{externalID}, for clients that sync documents
+// from another system and address them by their own IDs.
+// External IDs can contain slashes, like file paths, and other special characters must be URL-encoded.
+// PUT creates the document if there's none with the external ID, with HTTP 201 Created and the Location of the document,
+// and otherwise updates it like PUT /documents/{id}.
+func ExternalDocuments(mux chi.Router, db externalDocumentUpserter, ai embedder, log *slog.Logger) {
+	mux.Put("/documents/by-external-id/*", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		externalID, err := externalIDFromRequest(r)
+		if err != nil {
+			return err
+		}
+
+		doc, err := documentFromRequest(r)
+		if err != nil {
+			return err
+		}
+		doc.ExternalID = externalID
+
+		chunks, err := doc.Chunk(r.Context(), ai.EmbedString)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error creating document chunks", "error", err)
+			return aiError(w, err, http.StatusBadGateway, "error creating document chunks")
+		}
+
+		doc, created, err := db.UpsertDocumentByExternalID(r.Context(), doc, chunks, changeFromRequest(r))
+		if err != nil {
+			log.InfoContext(r.Context(), "Error upserting document", "error", err)
+			return errors.Wrap(err, "error upserting document")
+		}
+
+		if created {
+			w.Header().Set("Location", link(r, "/documents/"+string(doc.ID)))
+			w.WriteHeader(http.StatusCreated)
+			return nil
+		}
+
+		_, _ = w.Write([]byte(doc.Content))
+
+		return nil
+	}))
+
+	mux.Get("/documents/by-external-id/*", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		externalID, err := externalIDFromRequest(r)
+		if err != nil {
+			return err
+		}
+
+		doc, err := db.GetDocumentByExternalID(r.Context(), externalID)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{
+					Code: http.StatusNotFound,
+					Err:  errors.New("document not found"),
+				}
+			}
+
+			log.InfoContext(r.Context(), "Error getting document", "error", err)
+			return errors.Wrap(err, "error getting document")
+		}
+
+		w.Header().Set("Content-Location", link(r, "/documents/"+string(doc.ID)))
+		_, _ = w.Write([]byte(doc.Content))
+
+		return nil
+	}))
+
+	mux.Delete("/documents/by-external-id/*", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		externalID, err := externalIDFromRequest(r)
+		if err != nil {
+			return err
+		}
+
+		if err := db.DeleteDocumentByExternalID(r.Context(), externalID); err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{
+					Code: http.StatusNotFound,
+					Err:  errors.New("document not found"),
+				}
+			}
+
+			log.InfoContext(r.Context(), "Error deleting document", "error", err)
+			return errors.Wrap(err, "error deleting document")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+}
+
+// externalIDFromRequest is the rest of the path after /documents/by-external-id/, URL-decoded.
+func externalIDFromRequest(r *http.Request) (string, error) {
+	externalID := chi.URLParam(r, "*")
+
+	// chi routes on the raw path if it's set, which is when the path has escaped characters like %2F
+	if r.URL.RawPath != "" {
+		var err error
+		externalID, err = url.PathUnescape(externalID)
+		if err != nil {
+			return "", httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(err, "invalid external ID")}
+		}
+	}
+
+	if externalID == "" {
+		return "", httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("external ID is required")}
+	}
+
+	return externalID, nil
+}
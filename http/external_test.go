@@ -0,0 +1,112 @@
+package http_test
+
+import (
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestExternalDocuments(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("creates and then updates a document by external ID", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.ExternalDocuments(mux, db, ai, log)
+
+		req := httptest.NewRequest("PUT", "/documents/by-external-id/wikipedia/123", strings.NewReader("Sheep"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusCreated, w.Code)
+
+		doc, err := db.GetDocumentByExternalID(t.Context(), "wikipedia/123")
+		is.NotError(t, err)
+		is.Equal(t, "/documents/"+string(doc.ID), w.Header().Get("Location"))
+		is.Equal(t, "Sheep", doc.Content)
+
+		req = httptest.NewRequest("PUT", "/documents/by-external-id/wikipedia/123", strings.NewReader("Goats"))
+		req.Header.Set("Content-Type", "text/markdown")
+		w = httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "Goats", w.Body.String())
+
+		updated, err := db.GetDocumentByExternalID(t.Context(), "wikipedia/123")
+		is.NotError(t, err)
+		is.Equal(t, doc.ID, updated.ID)
+		is.Equal(t, "Goats", updated.Content)
+	})
+
+	t.Run("gets a document by URL-encoded external ID", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.ExternalDocuments(mux, db, ai, log)
+
+		doc, _, err := db.UpsertDocumentByExternalID(t.Context(), model.Document{Content: "Sheep", ExternalID: "a/b c"}, nil, model.Change{})
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/documents/by-external-id/a%2Fb%20c", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "Sheep", w.Body.String())
+		is.Equal(t, "/documents/"+string(doc.ID), w.Header().Get("Content-Location"))
+	})
+
+	t.Run("deletes a document by external ID", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.ExternalDocuments(mux, db, ai, log)
+
+		_, _, err := db.UpsertDocumentByExternalID(t.Context(), model.Document{Content: "Sheep", ExternalID: "123"}, nil, model.Change{})
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("DELETE", "/documents/by-external-id/123", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("DELETE", "/documents/by-external-id/123", nil)
+		w = httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+
+	t.Run("returns not found for unknown external IDs", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.ExternalDocuments(mux, db, ai, log)
+
+		req := httptest.NewRequest("GET", "/documents/by-external-id/unknown", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
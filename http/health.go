@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/httph"
+
+	"app/ai"
+)
+
+type databaseChecker interface {
+	Ping(ctx context.Context) error
+	VecVersion(ctx context.Context) (string, error)
+	CheckMigrations(ctx context.Context) error
+}
+
+type aiChecker interface {
+	PingChatCompleter(ctx context.Context) error
+	PingEmbedder(ctx context.Context) error
+	CircuitStates() map[string]ai.CircuitState
+}
+
+// readyCheckTimeout is the maximum time each readiness check can take.
+const readyCheckTimeout = 5 * time.Second
+
+// ComponentStatus is the status of a single dependency in [ReadyResponse].
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latencyMs"`
+	Version   string  `json:"version,omitempty"`
+	Error     string  `json:"error,omitempty"`
+
+	// Circuit is the state of the circuit breaker for calls to a model server, see [ai.CircuitState].
+	Circuit string `json:"circuit,omitempty"`
+}
+
+// ReadyResponse is returned from the readiness endpoint.
+type ReadyResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+func (r ReadyResponse) StatusCode() int {
+	if r.Status != "ok" {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// Health registers a liveness endpoint at /health, which always responds if the app is running,
+// and a readiness endpoint at /ready, which checks the database and the model servers.
+// A model server with an open circuit is not ready, even if it responds, because calls to it fail fast.
+func Health(mux chi.Router, db databaseChecker, ai aiChecker, log *slog.Logger) {
+	mux.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("OK\n"))
+	})
+
+	mux.Get("/ready", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, _ any) (ReadyResponse, error) {
+		checks := map[string]func(ctx context.Context) (string, error){
+			"database": func(ctx context.Context) (string, error) {
+				return "", db.Ping(ctx)
+			},
+			"vec": db.VecVersion,
+			"migrations": func(ctx context.Context) (string, error) {
+				return "", db.CheckMigrations(ctx)
+			},
+			"chatCompleter": func(ctx context.Context) (string, error) {
+				return "", ai.PingChatCompleter(ctx)
+			},
+			"embedder": func(ctx context.Context) (string, error) {
+				return "", ai.PingEmbedder(ctx)
+			},
+		}
+
+		circuits := ai.CircuitStates()
+		for name := range circuits {
+			if _, ok := checks[name]; !ok {
+				// Model servers without a ping check, like the reranker, only have their circuit checked
+				checks[name] = func(ctx context.Context) (string, error) {
+					return "", nil
+				}
+			}
+		}
+
+		res := ReadyResponse{
+			Status:     "ok",
+			Components: map[string]ComponentStatus{},
+		}
+
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		for name, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+				defer cancel()
+
+				start := time.Now()
+				version, err := check(ctx)
+				status := ComponentStatus{
+					Status:    "ok",
+					LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+					Version:   version,
+				}
+				if circuit, ok := circuits[name]; ok {
+					status.Circuit = string(circuit)
+					if err == nil {
+						err = circuitError(circuit)
+					}
+				}
+				if err != nil {
+					log.InfoContext(ctx, "Readiness check failed", "component", name, "error", err)
+					status.Status = "error"
+					status.Error = err.Error()
+				}
+
+				lock.Lock()
+				defer lock.Unlock()
+				res.Components[name] = status
+				if err != nil {
+					res.Status = "error"
+				}
+			}()
+		}
+		wg.Wait()
+
+		return res, nil
+	}))
+}
+
+// circuitError returns [ai.ErrCircuitOpen] if the circuit is open, and nil otherwise.
+func circuitError(state ai.CircuitState) error {
+	if state == ai.CircuitStateOpen {
+		return ai.ErrCircuitOpen
+	}
+	return nil
+}
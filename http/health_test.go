@@ -0,0 +1,118 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/ai"
+	"app/http"
+	"app/sqltest"
+)
+
+type aiCheckerMock struct {
+	chatCompleterErr error
+	embedderErr      error
+	circuits         map[string]ai.CircuitState
+}
+
+func (a *aiCheckerMock) PingChatCompleter(ctx context.Context) error {
+	return a.chatCompleterErr
+}
+
+func (a *aiCheckerMock) PingEmbedder(ctx context.Context) error {
+	return a.embedderErr
+}
+
+func (a *aiCheckerMock) CircuitStates() map[string]ai.CircuitState {
+	return a.circuits
+}
+
+func TestHealth(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("health is always ok", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Health(mux, db, &aiCheckerMock{}, log)
+
+		req := httptest.NewRequest("GET", "/health", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+	})
+
+	t.Run("ready is ok when all components are ok", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Health(mux, db, &aiCheckerMock{}, log)
+
+		req := httptest.NewRequest("GET", "/ready", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var res http.ReadyResponse
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+		is.NotError(t, err)
+		is.Equal(t, "ok", res.Status)
+		is.Equal(t, 5, len(res.Components))
+		is.True(t, res.Components["vec"].Version != "")
+	})
+
+	t.Run("ready is unavailable when a component is down", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Health(mux, db, &aiCheckerMock{embedderErr: errors.New("connection refused")}, log)
+
+		req := httptest.NewRequest("GET", "/ready", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusServiceUnavailable, w.Code)
+
+		var res http.ReadyResponse
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+		is.NotError(t, err)
+		is.Equal(t, "error", res.Status)
+		is.Equal(t, "ok", res.Components["chatCompleter"].Status)
+		is.Equal(t, "error", res.Components["embedder"].Status)
+		is.Equal(t, "connection refused", res.Components["embedder"].Error)
+	})
+
+	t.Run("ready is unavailable when a model server circuit is open", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Health(mux, db, &aiCheckerMock{circuits: map[string]ai.CircuitState{
+			"chatCompleter": ai.CircuitStateOpen,
+			"embedder":      ai.CircuitStateClosed,
+			"reranker":      ai.CircuitStateHalfOpen,
+		}}, log)
+
+		req := httptest.NewRequest("GET", "/ready", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusServiceUnavailable, w.Code)
+
+		var res http.ReadyResponse
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+		is.NotError(t, err)
+		is.Equal(t, "error", res.Status)
+		is.Equal(t, "error", res.Components["chatCompleter"].Status)
+		is.Equal(t, "open", res.Components["chatCompleter"].Circuit)
+		is.Equal(t, "ok", res.Components["embedder"].Status)
+		is.Equal(t, "closed", res.Components["embedder"].Circuit)
+		is.Equal(t, "ok", res.Components["reranker"].Status)
+		is.Equal(t, "half-open", res.Components["reranker"].Circuit)
+	})
+}
@@ -0,0 +1,37 @@
+package http_test
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"maragu.dev/is"
+
+	"app/http"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("records request duration by route pattern", func(t *testing.T) {
+		mux := chi.NewRouter()
+		mux.Use(http.RequestMetrics)
+		mux.Get("/things/{id}", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			w.WriteHeader(stdhttp.StatusTeapot)
+		})
+		http.Metrics(mux, prometheus.DefaultGatherer)
+
+		req := httptest.NewRequest("GET", "/things/123", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusTeapot, w.Code)
+
+		req = httptest.NewRequest("GET", "/metrics", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		is.True(t, strings.Contains(w.Body.String(), `app_http_request_duration_seconds_count{code="418",method="GET",route="/things/{id}"} 1`))
+	})
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/prompt"
+)
+
+// Prompts registers endpoints for the prompt templates, see [prompt]:
+//   - GET /prompts lists all template versions by ID, as Markdown links.
+//   - GET /prompts/{name}/v{version} gets the text of a template version.
+func Prompts(mux chi.Router) {
+	mux.Get("/prompts", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		for _, t := range prompt.List() {
+			_, _ = w.Write([]byte("- [" + t.ID() + "](" + link(r, "/prompts/"+t.Name+"/v"+strconv.Itoa(t.Version)) + ")\n"))
+		}
+		return nil
+	}))
+
+	mux.Get("/prompts/{name}/v{version:[0-9]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		version, err := strconv.Atoi(chi.URLParam(r, "version"))
+		if err != nil || version < 1 {
+			return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("prompt template not found")}
+		}
+
+		t, err := prompt.Get(chi.URLParam(r, "name"), version)
+		if err != nil {
+			return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("prompt template not found")}
+		}
+
+		_, _ = w.Write([]byte(t.Text))
+		return nil
+	}))
+}
+
+// PromptVersions is Middleware that selects prompt template versions for the request with the prompt query parameter,
+// which can be repeated, like prompt=reply@v2&prompt=rerank@v1. See [prompt.WithVersion].
+// Unknown template versions get HTTP 400 Bad Request.
+func PromptVersions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		for _, id := range r.URL.Query()["prompt"] {
+			name, version, ok := prompt.ParseID(id)
+			if !ok {
+				http.Error(w, "invalid prompt template ID "+id, http.StatusBadRequest)
+				return
+			}
+
+			if _, err := prompt.Get(name, version); err != nil {
+				http.Error(w, "unknown prompt template "+id, http.StatusBadRequest)
+				return
+			}
+
+			ctx = prompt.WithVersion(ctx, name, version)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
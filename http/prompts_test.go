@@ -0,0 +1,73 @@
+package http_test
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+	"app/prompt"
+)
+
+func TestPrompts(t *testing.T) {
+	t.Run("lists prompt templates and gets a template version", func(t *testing.T) {
+		mux := chi.NewRouter()
+		http.Prompts(mux)
+
+		req := httptest.NewRequest("GET", "/prompts", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.True(t, strings.Contains(w.Body.String(), "- [reply@v1](/prompts/reply/v1)\n"))
+
+		req = httptest.NewRequest("GET", "/prompts/reply/v1", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		tmpl, err := prompt.Get("reply", 1)
+		is.NotError(t, err)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, tmpl.Text, w.Body.String())
+
+		req = httptest.NewRequest("GET", "/prompts/reply/v1000", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
+
+func TestPromptVersions(t *testing.T) {
+	t.Run("selects prompt template versions for the request", func(t *testing.T) {
+		var id string
+		h := http.PromptVersions(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			_, tmpl, err := prompt.Render(r.Context(), "hypothetical-document", map[string]any{"Query": "Sheep?"})
+			is.NotError(t, err)
+			id = tmpl.ID()
+		}))
+
+		req := httptest.NewRequest("GET", "/?prompt=hypothetical-document@v1", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "hypothetical-document@v1", id)
+	})
+
+	t.Run("errors on unknown prompt template versions", func(t *testing.T) {
+		h := http.PromptVersions(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {}))
+
+		for _, id := range []string{"reply@v1000", "reply", "unknown@v1"} {
+			req := httptest.NewRequest("GET", "/?prompt="+id, nil)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/ai"
+)
+
+// RateLimitOptions for the [RateLimit] middleware.
+type RateLimitOptions struct {
+	// PerMinute is the number of requests per minute each client is allowed on average.
+	// Zero means no limit.
+	PerMinute int
+
+	// Burst is the number of requests each client can make in a burst.
+	// Defaults to 1 if not specified.
+	Burst int
+}
+
+// RateLimit is Middleware that limits requests with a token bucket per client.
+// Clients are identified by their API key if it's been checked with [APIKeys] before this,
+// otherwise by their IP address. Use [middleware.RealIP] before this only behind a trusted proxy,
+// because it trusts the forwarding headers from anyone.
+// Clients over the limit get HTTP 429 Too Many Requests with a Retry-After header.
+func RateLimit(opts RateLimitOptions) httph.Middleware {
+	if opts.PerMinute < 0 {
+		panic("per minute cannot be negative")
+	}
+
+	if opts.PerMinute == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+
+	l := &rateLimiter{
+		burst:   opts.Burst,
+		clients: map[string]*rateLimitClient{},
+		limit:   rate.Limit(float64(opts.PerMinute) / 60),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if delay, ok := l.allow(clientKey(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitIdleTimeout is how long a client can be idle before its token bucket is forgotten.
+const rateLimitIdleTimeout = 10 * time.Minute
+
+// rateLimitMaxClients is the maximum number of clients with their own token bucket.
+// New clients share a single overflow bucket while there are this many active clients.
+const rateLimitMaxClients = 10_000
+
+// rateLimitOverflowKey is the key of the token bucket shared by clients beyond [rateLimitMaxClients].
+const rateLimitOverflowKey = "overflow"
+
+type rateLimiter struct {
+	burst     int
+	clients   map[string]*rateLimitClient
+	lastSweep time.Time
+	limit     rate.Limit
+	lock      sync.Mutex
+}
+
+type rateLimitClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// allow a request from the client with the given key, or return how long to wait until the next request is allowed.
+func (l *rateLimiter) allow(key string) (time.Duration, bool) {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitIdleTimeout {
+		l.sweep(now)
+	}
+
+	c, ok := l.clients[key]
+	if !ok && len(l.clients) >= rateLimitMaxClients {
+		l.sweep(now)
+		if len(l.clients) >= rateLimitMaxClients {
+			key = rateLimitOverflowKey
+			c, ok = l.clients[key]
+		}
+	}
+	if !ok {
+		c = &rateLimitClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	res := c.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return delay, false
+	}
+
+	return 0, true
+}
+
+// sweep forgets the token buckets of idle clients.
+func (l *rateLimiter) sweep(now time.Time) {
+	for k, c := range l.clients {
+		if now.Sub(c.lastSeen) > rateLimitIdleTimeout {
+			delete(l.clients, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// clientKey identifies the client making the request, by the ID of its checked API key, or its IP address.
+func clientKey(r *http.Request) string {
+	if id := apiKeyID(r); id != "" {
+		return "key:" + id
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		// middleware.RealIP sets the remote address without a port
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// apiKey from the Authorization header as a bearer token, or the empty string if there is none.
+func apiKey(r *http.Request) string {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// unlessSafeMethod applies the middleware only to requests that are not GET, HEAD, or OPTIONS.
+func unlessSafeMethod(m httph.Middleware) httph.Middleware {
+	return func(next http.Handler) http.Handler {
+		limited := m(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+			default:
+				limited.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// overloadedRetryAfter is the Retry-After value in seconds when the model servers are overloaded.
+const overloadedRetryAfter = "1"
+
+// unavailableRetryAfter is the Retry-After value in seconds when a model server is unavailable.
+const unavailableRetryAfter = "30"
+
+// aiError wraps err from the AI client in an [httph.HTTPError] with the given code,
+// or HTTP 429 Too Many Requests with a Retry-After header if the model servers are overloaded,
+// HTTP 503 Service Unavailable with a Retry-After header if a model server is unavailable,
+// or HTTP 504 Gateway Timeout if a model server call timed out, or the request ran out of time, see [Timeout].
+func aiError(w http.ResponseWriter, err error, code int, message string) error {
+	switch {
+	case errors.Is(err, ai.ErrOverloaded):
+		w.Header().Set("Retry-After", overloadedRetryAfter)
+		return httph.HTTPError{Code: http.StatusTooManyRequests, Err: errors.Wrap(err, "%v", message)}
+	case errors.Is(err, ai.ErrCircuitOpen):
+		w.Header().Set("Retry-After", unavailableRetryAfter)
+		return httph.HTTPError{Code: http.StatusServiceUnavailable, Err: errors.Wrap(err, "%v", message)}
+	case errors.Is(err, ai.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return httph.HTTPError{Code: http.StatusGatewayTimeout, Err: errors.Wrap(err, "%v", message)}
+	}
+	return httph.HTTPError{Code: code, Err: errors.Wrap(err, "%v", message)}
+}
@@ -0,0 +1,113 @@
+package http_test
+
+import (
+	"fmt"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+)
+
+func TestRateLimit(t *testing.T) {
+	newMux := func(opts http.RateLimitOptions) chi.Router {
+		mux := chi.NewRouter()
+		mux.Use(http.RateLimit(opts))
+		mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+		return mux
+	}
+
+	t.Run("allows requests up to the burst and then returns 429 with Retry-After", func(t *testing.T) {
+		mux := newMux(http.RateLimitOptions{PerMinute: 1, Burst: 2})
+
+		for i := range 3 {
+			req := httptest.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if i < 2 {
+				is.Equal(t, stdhttp.StatusOK, w.Code)
+				continue
+			}
+
+			is.Equal(t, stdhttp.StatusTooManyRequests, w.Code)
+			is.Equal(t, "60", w.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("limits per checked API key separately from IP address", func(t *testing.T) {
+		mux := chi.NewRouter()
+		mux.Use(http.APIKeys(&apiKeyGetterMock{keys: []string{"abc"}}, slog.New(slog.DiscardHandler)))
+		mux.Use(http.RateLimit(http.RateLimitOptions{PerMinute: 1}))
+		mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer abc")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer abc")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("limits by IP address if the API key is not checked, ignoring forwarding headers", func(t *testing.T) {
+		mux := newMux(http.RateLimitOptions{PerMinute: 1})
+
+		for i, key := range []string{"made-up-1", "made-up-2"} {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			req.Header.Set("X-Forwarded-For", fmt.Sprintf("10.0.0.%v", i))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if i == 0 {
+				is.Equal(t, stdhttp.StatusOK, w.Code)
+				continue
+			}
+			is.Equal(t, stdhttp.StatusTooManyRequests, w.Code)
+		}
+	})
+
+	t.Run("new clients share a bucket when there are too many clients", func(t *testing.T) {
+		mux := newMux(http.RateLimitOptions{PerMinute: 1})
+
+		serve := func(i int) int {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = fmt.Sprintf("10.%v.%v.%v:1234", i>>16&255, i>>8&255, i&255)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		for i := range 10_000 {
+			is.Equal(t, stdhttp.StatusOK, serve(i))
+		}
+
+		is.Equal(t, stdhttp.StatusOK, serve(10_000))
+		is.Equal(t, stdhttp.StatusTooManyRequests, serve(10_001))
+	})
+
+	t.Run("does not limit if per minute is zero", func(t *testing.T) {
+		mux := newMux(http.RateLimitOptions{})
+
+		for range 10 {
+			req := httptest.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			is.Equal(t, stdhttp.StatusOK, w.Code)
+		}
+	})
+}
@@ -1,21 +1,116 @@
 package http
 
 import (
+	"net/http"
+	"regexp"
+	"strings"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"maragu.dev/httph"
+
+	"app/ingest"
 )
 
 // setupRoutes for the server.
+// Health, metrics, and admin endpoints are always served from the root, everything else is served under the base path.
 func (s *Server) setupRoutes() {
+	s.mux.Use(Trace, RequestMetrics, unless(isBulkStream, MaxBodySize(s.maxBodyBytes)))
+
 	s.mux.Group(func(r chi.Router) {
+		r.Use(middleware.SetHeader("Content-Type", "application/json"))
+
+		Health(r, s.db, s.ai, s.log)
+	})
+
+	Metrics(s.mux, prometheus.DefaultGatherer)
+
+	if s.adminAPIKey != "" {
+		s.mux.Group(func(r chi.Router) {
+			r.Use(middleware.SetHeader("Content-Type", "application/json"))
+			r.Use(Admin(s.adminAPIKey))
+
+			APIKeysAdmin(r, s.db, s.log)
+			UsageAdmin(r, s.db, s.log)
+		})
+	}
+
+	s.mux.Route(valueOrDefault(s.basePath, "/"), func(r chi.Router) {
 		r.Use(middleware.Compress(5))
-		r.Use(middleware.RealIP)
+		if s.trustProxy {
+			r.Use(middleware.RealIP)
+		}
+		r.Use(BasePath(s.basePath))
+		r.Use(Timeout(s.server.WriteTimeout, isStreaming))
+		r.Use(PromptVersions)
+		r.Use(APIKeys(s.db, s.log))
+		r.Use(Usage(s.db, s.log))
 
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.SetHeader("Content-Type", "text/markdown"))
 
-			Documents(r, s.db, s.ai, s.log)
-			Search(r, s.db, s.ai)
+			r.Group(func(r chi.Router) {
+				r.Use(unlessSafeMethod(RateLimit(s.ingestRateLimit)))
+				r.Use(unlessSafeMethod(Quota(s.db, s.usageQuota, s.log)))
+
+				Documents(r, s.db, s.ai, s.duplicatePolicy, s.log)
+				ExternalDocuments(r, s.db, s.ai, s.log)
+				Versions(r, s.db, s.ai, s.log)
+				Trash(r, s.db, s.log)
+				Tags(r, s.db, s.log)
+				Summaries(r, s.db, s.ai, s.log)
+				BulkDocuments(r, s.db, s.ai, ingest.BulkOptions{
+					BatchSize:       s.bulkBatchSize,
+					DuplicatePolicy: s.duplicatePolicy,
+				}, s.bulkMaxBodyBytes, s.log)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(RateLimit(s.searchRateLimit))
+				r.Use(Quota(s.db, s.usageQuota, s.log))
+
+				Search(r, s.db, s.ai)
+				Entities(r, s.db, s.log)
+				Conversations(r, s.db, s.ai, s.ungroundedPolicy, s.log)
+			})
+
+			Prompts(r)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.SetHeader("Content-Type", "application/json"))
+			r.Use(RateLimit(s.searchRateLimit))
+
+			Duplicates(r, s.db, s.log)
 		})
 	})
 }
+
+// isStreaming is whether the request streams its response for as long as it takes, so it has no request timeout,
+// see [Timeout]. That's synchronous bulk ingestion, and replies to conversation messages.
+func isStreaming(r *http.Request) bool {
+	return isBulkStream(r) || (r.Method == http.MethodPost && messagesPathPattern.MatchString(r.URL.Path))
+}
+
+var messagesPathPattern = regexp.MustCompile(`/conversations/[a-z0-9_]+/messages$`)
+
+// isBulkStream is whether the request is a synchronous bulk ingestion, which reads its body while it streams results,
+// for as long as that takes. It has its own body size limit, and no request timeout, see [BulkDocuments] and [Timeout].
+func isBulkStream(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/documents/bulk") && r.URL.Query().Get("async") != "true"
+}
+
+// unless the predicate is true for the request, apply the [httph.Middleware].
+func unless(predicate func(*http.Request) bool, m httph.Middleware) httph.Middleware {
+	return func(next http.Handler) http.Handler {
+		applied := m(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if predicate(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			applied.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,8 +2,12 @@ package http
 
 import (
 	"app/model"
+	"app/rag"
+	"app/sql"
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"maragu.dev/errors"
@@ -11,26 +15,108 @@ import (
 )
 
 type searcher interface {
-	Search(ctx context.Context, query string, embedding []byte) ([]model.Chunk, error)
+	Search(ctx context.Context, query string, embedding []byte, opts sql.SearchOptions) ([]model.Chunk, error)
+	GetFacets(ctx context.Context, ids []model.ID, limit int) (model.Facets, error)
 }
 
-func Search(mux chi.Router, db searcher, ai embedder) {
+type retriever interface {
+	embedder
+	ExpandQuery(ctx context.Context, query string, n int) ([]string, error)
+	HypotheticalDocument(ctx context.Context, query string) (string, error)
+	MaxRerankDocuments() int
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+	RewriteQuery(ctx context.Context, query string, n int) ([]string, error)
+}
+
+// Search registers the search endpoint.
+// The tag, entity, and topic query parameters can be repeated, to only search documents with all of them.
+// With the rerank query parameter set to true, the top results are reranked by relevance,
+// where rerank_top is the number of top results to rerank. Values above [ai.Client.MaxRerankDocuments] are lowered to it.
+// Query pre-processing is turned on with the rewrite, expand, and hyde query parameters set to true, see [rag.RetrieveOptions].
+// With the facets query parameter set to true, the most common entities and topics of the result documents
+// are listed after the results, as links to the same search filtered by them.
+func Search(mux chi.Router, db searcher, ai retriever) {
 	mux.Get("/search", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
 		q := r.URL.Query().Get("q")
-		embedding, err := ai.EmbedString(r.Context(), q)
-		if err != nil {
-			return errors.Wrap(err, "error embedding")
+
+		var tags []string
+		for _, tag := range r.URL.Query()["tag"] {
+			tag, ok := model.NormalizeTag(tag)
+			if !ok {
+				return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("invalid tag")}
+			}
+			tags = append(tags, tag)
+		}
+
+		var rerankTop int
+		if v := r.URL.Query().Get("rerank_top"); v != "" {
+			var err error
+			rerankTop, err = strconv.Atoi(v)
+			if err != nil || rerankTop < 1 {
+				return httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("rerank_top must be a positive number")}
+			}
+			rerankTop = min(rerankTop, ai.MaxRerankDocuments())
 		}
 
-		chunks, err := db.Search(r.Context(), q, embedding)
+		chunks, err := rag.Retrieve(r.Context(), db, ai, q, rag.RetrieveOptions{
+			Search: sql.SearchOptions{
+				Tags:     tags,
+				Entities: r.URL.Query()["entity"],
+				Topics:   r.URL.Query()["topic"],
+			},
+			NoRerank:  r.URL.Query().Get("rerank") != "true",
+			RerankTop: rerankTop,
+			Rewrite:   r.URL.Query().Get("rewrite") == "true",
+			Expand:    r.URL.Query().Get("expand") == "true",
+			HyDE:      r.URL.Query().Get("hyde") == "true",
+		})
 		if err != nil {
-			return errors.Wrap(err, "error searching")
+			return aiError(w, err, http.StatusInternalServerError, "error searching")
+		}
+
+		for _, chunk := range chunks {
+			_, _ = w.Write([]byte("- [" + chunk.Content + "](" + link(r, "/documents/"+string(chunk.ID)) + ")\n"))
+		}
+
+		if r.URL.Query().Get("facets") != "true" {
+			return nil
 		}
 
+		var ids []model.ID
+		seen := map[model.ID]bool{}
 		for _, chunk := range chunks {
-			_, _ = w.Write([]byte("- [" + chunk.Content + "](/documents/" + string(chunk.ID) + ")\n"))
+			if !seen[chunk.DocumentID] {
+				seen[chunk.DocumentID] = true
+				ids = append(ids, chunk.DocumentID)
+			}
+		}
+
+		facets, err := db.GetFacets(r.Context(), ids, 10)
+		if err != nil {
+			return errors.Wrap(err, "error getting facets")
+		}
+
+		if len(facets.Entities) > 0 {
+			_, _ = w.Write([]byte("\n## Entities\n\n"))
+			for _, e := range facets.Entities {
+				_, _ = w.Write([]byte(fmt.Sprintf("- [%v](%v) (%v, %v)\n", e.Name, searchLink(r, "entity", e.Name), e.Type, e.Count)))
+			}
+		}
+
+		if len(facets.Topics) > 0 {
+			_, _ = w.Write([]byte("\n## Topics\n\n"))
+			for _, t := range facets.Topics {
+				_, _ = w.Write([]byte(fmt.Sprintf("- [%v](%v) (%v)\n", t.Topic, searchLink(r, "topic", t.Topic), t.Count)))
+			}
 		}
 
 		return nil
 	}))
 }
+
+// searchLink to the current search with an added filter.
+func searchLink(r *http.Request, key, value string) string {
+	query := r.URL.Query()
+	query.Add(key, value)
+	return link(r, "/search?"+query.Encode())
+}
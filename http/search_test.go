@@ -46,4 +46,32 @@ func TestSearch(t *testing.T) {
 		is.True(t, strings.Contains(responseBody, "- ["))
 		is.True(t, strings.Contains(responseBody, "](/documents/"))
 	})
-}
\ No newline at end of file
+
+	t.Run("errors on invalid rerank top", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Search(mux, db, ai)
+
+		req := httptest.NewRequest("GET", "/search?q=searchable&rerank=true&rerank_top=0", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+
+	t.Run("lowers a rerank top above the max for reranking with the chat model", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Search(mux, db, ai)
+
+		req := httptest.NewRequest("GET", "/search?q=searchable&rerank=true&rerank_top=50", nil)
+		w := httptest.NewRecorder()
+
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+	})
+}
@@ -7,27 +7,96 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"app/ai"
+	"app/model"
 	"app/sql"
 )
 
 // Server holds dependencies for the HTTP server as well as the HTTP server itself.
 type Server struct {
-	ai     *ai.Client
-	db     *sql.Database
-	log    *slog.Logger
-	mux    chi.Router
-	server *http.Server
+	adminAPIKey      string
+	ai               *ai.Client
+	basePath         string
+	bulkBatchSize    int
+	bulkMaxBodyBytes int64
+	db               *sql.Database
+	duplicatePolicy  model.DuplicatePolicy
+	ingestRateLimit  RateLimitOptions
+	log              *slog.Logger
+	maxBodyBytes     int64
+	mux              chi.Router
+	searchRateLimit  RateLimitOptions
+	server           *http.Server
+	shutdownTimeout  time.Duration
+	tlsCertFile      string
+	tlsKeyFile       string
+	trustProxy       bool
+	ungroundedPolicy model.UngroundedPolicy
+	usageQuota       QuotaOptions
 }
 
 type NewServerOptions struct {
 	AI  *ai.Client
 	DB  *sql.Database
 	Log *slog.Logger
+
+	// AdminAPIKey is the bearer token for the admin endpoints, like the token usage report.
+	// If empty, the admin endpoints are not served.
+	AdminAPIKey string
+
+	// Address to listen on. Defaults to ":8080".
+	Address string
+
+	// BasePath to serve all routes under, like "/api". Defaults to serving from the root.
+	BasePath string
+
+	// BulkBatchSize is the number of documents created per database transaction in bulk ingestion. Defaults to 100.
+	BulkBatchSize int
+
+	// BulkMaxBodyBytes is the maximum size of request bodies for synchronous bulk ingestion, instead of MaxBodyBytes,
+	// since the body is processed while it's read. Defaults to 1 GiB.
+	BulkMaxBodyBytes int64
+
+	// DuplicatePolicy for creating documents with the same content as an existing document,
+	// unless overridden per request. Defaults to [model.DuplicatePolicyAllow].
+	DuplicatePolicy model.DuplicatePolicy
+
+	// IngestRateLimit applies to requests that create or change documents, which embed all document chunks.
+	IngestRateLimit RateLimitOptions
+
+	// MaxBodyBytes is the maximum size of request bodies. Larger bodies get HTTP 413 Request Entity Too Large.
+	// Defaults to 10 MiB.
+	MaxBodyBytes int64
+
+	// SearchRateLimit applies to search requests, which embed the query.
+	SearchRateLimit RateLimitOptions
+
+	// Timeouts for the [http.Server]. Each defaults to the value noted if not specified.
+	ReadTimeout       time.Duration // 5 seconds
+	ReadHeaderTimeout time.Duration // 5 seconds
+	WriteTimeout      time.Duration // 1 minute
+	IdleTimeout       time.Duration // 5 seconds
+	ShutdownTimeout   time.Duration // 1 minute
+
+	// TLSCertFile and TLSKeyFile are paths to a TLS certificate and key. If both are given, the server uses TLS.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// TrustProxy headers like X-Forwarded-For for the client IP address, which is used for rate limiting.
+	// Only set this behind a proxy that sets the headers, because clients can set them too.
+	TrustProxy bool
+
+	// UngroundedPolicy for answers that don't cite any sources, unless overridden per request.
+	// Defaults to [model.UngroundedPolicyAllow].
+	UngroundedPolicy model.UngroundedPolicy
+
+	// UsageQuota applies to requests that call the model servers, per API key.
+	UsageQuota QuotaOptions
 }
 
 func NewServer(opts NewServerOptions) *Server {
@@ -35,31 +104,95 @@ func NewServer(opts NewServerOptions) *Server {
 		opts.Log = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
 
+	if opts.Address == "" {
+		opts.Address = ":8080"
+	}
+
+	if opts.BasePath != "" && (!strings.HasPrefix(opts.BasePath, "/") || strings.HasSuffix(opts.BasePath, "/")) {
+		panic("base path must start and not end with a slash")
+	}
+
+	if opts.DuplicatePolicy == "" {
+		opts.DuplicatePolicy = model.DuplicatePolicyAllow
+	}
+
+	if !opts.DuplicatePolicy.Valid() {
+		panic("invalid duplicate policy")
+	}
+
+	if opts.UngroundedPolicy == "" {
+		opts.UngroundedPolicy = model.UngroundedPolicyAllow
+	}
+
+	if !opts.UngroundedPolicy.Valid() {
+		panic("invalid ungrounded policy")
+	}
+
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = 10 * 1024 * 1024
+	}
+
+	if opts.BulkMaxBodyBytes == 0 {
+		opts.BulkMaxBodyBytes = 1024 * 1024 * 1024
+	}
+
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		panic("both TLS cert and key file must be given")
+	}
+
 	mux := chi.NewMux()
 
 	return &Server{
-		ai:  opts.AI,
-		db:  opts.DB,
-		log: opts.Log,
-		mux: mux,
+		adminAPIKey:      opts.AdminAPIKey,
+		ai:               opts.AI,
+		basePath:         opts.BasePath,
+		bulkBatchSize:    opts.BulkBatchSize,
+		bulkMaxBodyBytes: opts.BulkMaxBodyBytes,
+		db:               opts.DB,
+		duplicatePolicy:  opts.DuplicatePolicy,
+		ingestRateLimit:  opts.IngestRateLimit,
+		log:              opts.Log,
+		maxBodyBytes:     opts.MaxBodyBytes,
+		mux:              mux,
+		searchRateLimit:  opts.SearchRateLimit,
 		server: &http.Server{
-			Addr:              ":8080",
+			Addr:              opts.Address,
 			Handler:           mux,
-			ReadTimeout:       5 * time.Second,
-			ReadHeaderTimeout: 5 * time.Second,
-			WriteTimeout:      time.Minute,
-			IdleTimeout:       5 * time.Second,
+			ReadTimeout:       valueOrDefault(opts.ReadTimeout, 5*time.Second),
+			ReadHeaderTimeout: valueOrDefault(opts.ReadHeaderTimeout, 5*time.Second),
+			WriteTimeout:      valueOrDefault(opts.WriteTimeout, time.Minute),
+			IdleTimeout:       valueOrDefault(opts.IdleTimeout, 5*time.Second),
 		},
+		shutdownTimeout:  valueOrDefault(opts.ShutdownTimeout, time.Minute),
+		tlsCertFile:      opts.TLSCertFile,
+		tlsKeyFile:       opts.TLSKeyFile,
+		trustProxy:       opts.TrustProxy,
+		ungroundedPolicy: opts.UngroundedPolicy,
+		usageQuota:       opts.UsageQuota,
 	}
 }
 
 // Start the server and set up routes.
 func (s *Server) Start() error {
-	s.log.Info("Starting http server", "address", "http://localhost:8080")
+	scheme := "http"
+	if s.tlsCertFile != "" {
+		scheme = "https"
+	}
+	address := s.server.Addr
+	if strings.HasPrefix(address, ":") {
+		address = "localhost" + address
+	}
+	s.log.Info("Starting http server", "address", scheme+"://"+address+s.basePath)
 
 	s.setupRoutes()
 
-	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	var err error
+	if s.tlsCertFile != "" {
+		err = s.server.ListenAndServeTLS(s.tlsCertFile, s.tlsKeyFile)
+	} else {
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 	return nil
@@ -69,7 +202,7 @@ func (s *Server) Start() error {
 func (s *Server) Stop() error {
 	s.log.Info("Stopping http server")
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 
 	if err := s.server.Shutdown(ctx); err != nil {
@@ -78,3 +211,11 @@ func (s *Server) Stop() error {
 	s.log.Info("Stopped http server")
 	return nil
 }
+
+func valueOrDefault[T comparable](v, defaultV T) T {
+	var zero T
+	if v == zero {
+		return defaultV
+	}
+	return v
+}
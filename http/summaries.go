@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+	"maragu.dev/httph"
+
+	"app/enrich"
+	"app/model"
+)
+
+type documentSummarizer interface {
+	GetDocument(ctx context.Context, id model.ID) (model.Document, error)
+	GetDocumentSummary(ctx context.Context, id model.ID) (model.DocumentSummary, error)
+	SaveDocumentSummary(ctx context.Context, s model.DocumentSummary, embedding []byte) (model.DocumentSummary, error)
+}
+
+type chatCompleteEmbedder interface {
+	embedder
+	ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error)
+}
+
+// Summaries registers endpoints for generated document summaries:
+//   - GET /documents/{id}/summary gets the summary of a document.
+//   - POST /documents/{id}/summary generates the summary of a document again, and returns it.
+//
+// Summaries are generated in the background as well, see [jobs.Summarizer].
+func Summaries(mux chi.Router, db documentSummarizer, ai chatCompleteEmbedder, log *slog.Logger) {
+	mux.Get("/documents/{id:[a-z0-9_]+}/summary", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		s, err := db.GetDocumentSummary(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorSummaryNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("summary not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error getting document summary", "error", err)
+			return errors.Wrap(err, "error getting document summary")
+		}
+
+		_, _ = w.Write([]byte(s.Content))
+
+		return nil
+	}))
+
+	mux.Post("/documents/{id:[a-z0-9_]+}/summary", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		doc, err := db.GetDocument(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error getting document", "error", err)
+			return errors.Wrap(err, "error getting document")
+		}
+
+		s, err := enrich.SummarizeDocument(r.Context(), db, ai, doc)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error summarizing document", "error", err)
+			return aiError(w, err, http.StatusBadGateway, "error summarizing document")
+		}
+
+		_, _ = w.Write([]byte(s.Content))
+
+		return nil
+	}))
+}
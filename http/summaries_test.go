@@ -0,0 +1,69 @@
+package http_test
+
+import (
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestSummaries(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("gets the summary of a document, which is also in the document list", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Summaries(mux, db, ai, log)
+		http.Documents(mux, db, ai, model.DuplicatePolicyAllow, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/summary", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+
+		embedding, err := ai.EmbedString(t.Context(), "A document about sheep.")
+		is.NotError(t, err)
+		_, err = db.SaveDocumentSummary(t.Context(), model.DocumentSummary{DocumentID: doc.ID, Content: "A document\nabout sheep."}, embedding)
+		is.NotError(t, err)
+
+		req = httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/summary", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "A document\nabout sheep.", w.Body.String())
+
+		req = httptest.NewRequest("GET", "/documents", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "- ["+string(doc.ID)+"](/documents/"+string(doc.ID)+"): A document about sheep.\n", w.Body.String())
+	})
+
+	t.Run("errors when generating the summary of a document that doesn't exist", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Summaries(mux, db, ai, log)
+
+		req := httptest.NewRequest("POST", "/documents/doc_doesnotexist/summary", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
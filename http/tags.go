@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/model"
+)
+
+type tagger interface {
+	AddDocumentTags(ctx context.Context, id model.ID, tags []string) error
+	RemoveDocumentTag(ctx context.Context, id model.ID, tag string) error
+	GetDocumentTags(ctx context.Context, id model.ID) ([]string, error)
+	ListTags(ctx context.Context) ([]model.TagCount, error)
+}
+
+// Tags registers endpoints for document tags:
+//   - GET /tags lists all tags with the number of documents that have them, as Markdown links to the documents.
+//   - GET /documents/{id}/tags lists the tags of a document.
+//   - PUT /documents/{id}/tags/{tag} adds a tag to a document.
+//   - DELETE /documents/{id}/tags/{tag} removes a tag from a document.
+//
+// Tags are normalized with [model.NormalizeTag].
+func Tags(mux chi.Router, db tagger, log *slog.Logger) {
+	mux.Get("/tags", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		tags, err := db.ListTags(r.Context())
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing tags", "error", err)
+			return errors.Wrap(err, "error listing tags")
+		}
+
+		for _, t := range tags {
+			_, _ = w.Write([]byte(fmt.Sprintf("- [%v](%v) (%v)\n", t.Tag, link(r, "/documents?tag="+url.QueryEscape(t.Tag)), t.Count)))
+		}
+
+		return nil
+	}))
+
+	mux.Get("/documents/{id:[a-z0-9_]+}/tags", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		tags, err := db.GetDocumentTags(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error getting document tags", "error", err)
+			return errors.Wrap(err, "error getting document tags")
+		}
+
+		for _, tag := range tags {
+			_, _ = w.Write([]byte("- [" + tag + "](" + link(r, "/documents?tag="+url.QueryEscape(tag)) + ")\n"))
+		}
+
+		return nil
+	}))
+
+	mux.Put("/documents/{id:[a-z0-9_]+}/tags/{tag}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		tag, err := tagFromRequest(r)
+		if err != nil {
+			return err
+		}
+
+		if err := db.AddDocumentTags(r.Context(), id, []string{tag}); err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error adding document tag", "error", err)
+			return errors.Wrap(err, "error adding document tag")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+
+	mux.Delete("/documents/{id:[a-z0-9_]+}/tags/{tag}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		tag, err := tagFromRequest(r)
+		if err != nil {
+			return err
+		}
+
+		if err := db.RemoveDocumentTag(r.Context(), id, tag); err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error removing document tag", "error", err)
+			return errors.Wrap(err, "error removing document tag")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+}
+
+// tagFromRequest gets the normalized tag from the tag URL parameter.
+func tagFromRequest(r *http.Request) (string, error) {
+	tag := chi.URLParam(r, "tag")
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(tag); err == nil {
+			tag = unescaped
+		}
+	}
+
+	tag, ok := model.NormalizeTag(tag)
+	if !ok {
+		return "", httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New("invalid tag")}
+	}
+	return tag, nil
+}
@@ -0,0 +1,83 @@
+package http_test
+
+import (
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestTags(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("adds, lists, and removes document tags", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Tags(mux, db, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		for _, tag := range []string{"Farm", "animals"} {
+			req := httptest.NewRequest("PUT", "/documents/"+string(doc.ID)+"/tags/"+tag, nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			is.Equal(t, stdhttp.StatusNoContent, w.Code)
+		}
+
+		req := httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/tags", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "- [animals](/documents?tag=animals)\n- [farm](/documents?tag=farm)\n", w.Body.String())
+
+		req = httptest.NewRequest("DELETE", "/documents/"+string(doc.ID)+"/tags/farm", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("GET", "/tags", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "- [animals](/documents?tag=animals) (1)\n", w.Body.String())
+	})
+
+	t.Run("errors on invalid tag", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Tags(mux, db, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("PUT", "/documents/"+string(doc.ID)+"/tags/-farm", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+
+	t.Run("errors on document not found", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Tags(mux, db, log)
+
+		req := httptest.NewRequest("PUT", "/documents/doc_doesnotexist/tags/farm", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"maragu.dev/httph"
+)
+
+type streamTimeoutContextKey struct{}
+
+// Timeout is Middleware that gives requests a context deadline a bit before the write timeout of the server,
+// so calls to the model servers, including their retries, give up while there's still time to respond with an error.
+// Zero means no deadline.
+//
+// Streaming requests, for which isStreaming returns true, get no deadline, since they take as long as their input
+// and output do. Instead, their handlers extend the connection deadlines by the write timeout while the request
+// and response are flowing, see [streamBody] and [extendWriteDeadline], so they only time out if they stall.
+func Timeout(writeTimeout time.Duration, isStreaming func(*http.Request) bool) httph.Middleware {
+	// Leave a tenth of the write timeout for writing the response
+	timeout := writeTimeout - writeTimeout/10
+
+	return func(next http.Handler) http.Handler {
+		if writeTimeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isStreaming != nil && isStreaming(r) {
+				r = r.WithContext(context.WithValue(r.Context(), streamTimeoutContextKey{}, writeTimeout))
+				extendWriteDeadline(w, r)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// extendWriteDeadline of the connection by the write timeout of the server, for streaming requests, see [Timeout].
+// Call it before each write of a streamed response. For other requests, it does nothing.
+func extendWriteDeadline(w http.ResponseWriter, r *http.Request) {
+	timeout, ok := r.Context().Value(streamTimeoutContextKey{}).(time.Duration)
+	if !ok {
+		return
+	}
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+}
+
+// streamBody of the request, which extends the read deadline of the connection by the write timeout of the server
+// before each read, for streaming requests that read their body while they process it, see [Timeout].
+// For other requests, it returns the body as it is.
+func streamBody(w http.ResponseWriter, r *http.Request) io.ReadCloser {
+	timeout, ok := r.Context().Value(streamTimeoutContextKey{}).(time.Duration)
+	if !ok {
+		return r.Body
+	}
+	return &deadlineBody{ReadCloser: r.Body, rc: http.NewResponseController(w), timeout: timeout}
+}
+
+type deadlineBody struct {
+	io.ReadCloser
+	rc      *http.ResponseController
+	timeout time.Duration
+	err     error
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	// Once the body has been read, the server reads the connection in the background to notice if the client goes away,
+	// and a read deadline passing then would cancel the request, so the deadline is left alone after the end of the body
+	if b.err != nil {
+		return 0, b.err
+	}
+	_ = b.rc.SetReadDeadline(time.Now().Add(b.timeout))
+
+	var n int
+	n, b.err = b.ReadCloser.Read(p)
+	return n, b.err
+}
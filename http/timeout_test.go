@@ -0,0 +1,57 @@
+package http_test
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Run("gives the request context a deadline before the write timeout", func(t *testing.T) {
+		mux := chi.NewRouter()
+		mux.Use(http.Timeout(time.Minute, nil))
+
+		var deadline time.Time
+		var ok bool
+		mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			deadline, ok = r.Context().Deadline()
+		})
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		is.True(t, ok)
+		is.True(t, time.Until(deadline) <= 54*time.Second)
+		is.True(t, time.Until(deadline) > 50*time.Second)
+	})
+
+	t.Run("does not give the request context a deadline if the write timeout is zero", func(t *testing.T) {
+		mux := chi.NewRouter()
+		mux.Use(http.Timeout(0, nil))
+
+		var ok bool
+		mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			_, ok = r.Context().Deadline()
+		})
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		is.True(t, !ok)
+	})
+
+	t.Run("does not give streaming requests a deadline", func(t *testing.T) {
+		mux := chi.NewRouter()
+		mux.Use(http.Timeout(time.Minute, func(r *stdhttp.Request) bool { return r.URL.Path == "/stream" }))
+
+		var ok bool
+		mux.Post("/stream", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			_, ok = r.Context().Deadline()
+		})
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/stream", nil))
+		is.True(t, !ok)
+	})
+}
@@ -0,0 +1,34 @@
+package http_test
+
+import (
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"maragu.dev/is"
+
+	"app/http"
+)
+
+func TestTrace(t *testing.T) {
+	t.Run("creates a span named after the route pattern", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+		mux := chi.NewRouter()
+		mux.Use(http.Trace)
+		mux.Get("/things/{id}", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+
+		req := httptest.NewRequest("GET", "/things/123", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		spans := recorder.Ended()
+		is.Equal(t, 1, len(spans))
+		is.Equal(t, "GET /things/{id}", spans[0].Name())
+	})
+}
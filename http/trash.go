@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/model"
+)
+
+type trashEmptier interface {
+	ListDeletedDocuments(ctx context.Context) ([]model.Document, error)
+	RestoreDocument(ctx context.Context, id model.ID) error
+	PurgeDocument(ctx context.Context, id model.ID) error
+}
+
+// Trash registers endpoints for deleted documents:
+//   - GET /trash lists documents in the trash as Markdown links, most recently deleted first.
+//   - POST /trash/{id}/restore restores a document, with the Location of the restored document.
+//   - DELETE /trash/{id} permanently deletes a document right away, instead of waiting for the purger.
+func Trash(mux chi.Router, db trashEmptier, log *slog.Logger) {
+	mux.Get("/trash", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		docs, err := db.ListDeletedDocuments(r.Context())
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing deleted documents", "error", err)
+			return errors.Wrap(err, "error listing deleted documents")
+		}
+
+		for _, doc := range docs {
+			_, _ = w.Write([]byte("- " + string(doc.ID) + " deleted " + doc.Deleted.String() + "\n"))
+		}
+
+		return nil
+	}))
+
+	mux.Post("/trash/{id:[a-z0-9_]+}/restore", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		if err := db.RestoreDocument(r.Context(), id); err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found in trash")}
+			}
+
+			log.InfoContext(r.Context(), "Error restoring document", "error", err)
+			return errors.Wrap(err, "error restoring document")
+		}
+
+		w.Header().Set("Location", link(r, "/documents/"+string(id)))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+
+	mux.Delete("/trash/{id:[a-z0-9_]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		if err := db.PurgeDocument(r.Context(), id); err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found in trash")}
+			}
+
+			log.InfoContext(r.Context(), "Error purging document", "error", err)
+			return errors.Wrap(err, "error purging document")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+}
@@ -0,0 +1,71 @@
+package http_test
+
+import (
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestTrash(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("lists and restores deleted documents", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Trash(mux, db, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/trash", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.True(t, strings.HasPrefix(w.Body.String(), "- "+string(doc.ID)+" deleted "))
+
+		req = httptest.NewRequest("POST", "/trash/"+string(doc.ID)+"/restore", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+		is.Equal(t, "/documents/"+string(doc.ID), w.Header().Get("Location"))
+
+		_, err = db.GetDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+	})
+
+	t.Run("purges a deleted document", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.Trash(mux, db, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("DELETE", "/trash/"+string(doc.ID), nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("POST", "/trash/"+string(doc.ID)+"/restore", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/extract"
+	"app/model"
+)
+
+// uploadFormField is the multipart form field that holds the uploaded file.
+const uploadFormField = "file"
+
+// documentFromRequest reads the uploaded file from the request and converts it to Markdown.
+// The file is either the request body, or the "file" field of a multipart/form-data request.
+// The format is detected from the Content-Type (of the request or the multipart part), the file name, or by sniffing,
+// and stored in the document metadata together with the file name, if any.
+func documentFromRequest(r *http.Request) (model.Document, error) {
+	var data []byte
+	var contentType, filename string
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return model.Document{}, httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Newf("missing %v field in form", uploadFormField)}
+			}
+			if err != nil {
+				return model.Document{}, bodyError(err)
+			}
+
+			if part.FormName() != uploadFormField {
+				continue
+			}
+
+			filename = part.FileName()
+			contentType = part.Header.Get("Content-Type")
+			data, err = io.ReadAll(part)
+			if err != nil {
+				return model.Document{}, bodyError(err)
+			}
+			break
+		}
+	} else {
+		var err error
+		data, err = readBody(r)
+		if err != nil {
+			return model.Document{}, err
+		}
+		contentType = r.Header.Get("Content-Type")
+	}
+
+	mediaType = extract.MediaType(data, contentType, filename)
+	content, err := extract.Markdown(data, mediaType)
+	if err != nil {
+		if errors.Is(err, extract.ErrUnsupportedMediaType) {
+			return model.Document{}, httph.HTTPError{Code: http.StatusUnsupportedMediaType, Err: err}
+		}
+		return model.Document{}, httph.HTTPError{Code: http.StatusUnprocessableEntity, Err: err}
+	}
+
+	metadata := model.Metadata{"mediaType": mediaType}
+	if filename != "" {
+		metadata["filename"] = filename
+	}
+
+	return model.Document{
+		Content:  content,
+		Metadata: metadata,
+	}, nil
+}
@@ -0,0 +1,276 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/ai"
+	"app/model"
+	"app/sql"
+)
+
+type usageSaver interface {
+	SaveUsage(ctx context.Context, usage []model.Usage) error
+}
+
+type quotaChecker interface {
+	GetUsageQuota(ctx context.Context, apiKey string) (model.UsageQuota, error)
+	GetUsedTokens(ctx context.Context, apiKey string, since time.Time) (int, error)
+}
+
+type usageAdministrator interface {
+	GetUsageReport(ctx context.Context, opts sql.GetUsageReportOptions) ([]model.UsageTotal, error)
+	ListUsageQuotas(ctx context.Context) ([]model.UsageQuota, error)
+	SetUsageQuota(ctx context.Context, apiKey string, tokens int) (model.UsageQuota, error)
+	DeleteUsageQuota(ctx context.Context, apiKey string) error
+}
+
+// Usage is Middleware that meters the tokens used in calls to the model servers during each request,
+// and saves them tagged with the ID of the API key of the request, and the route pattern.
+// Requests without calls to the model servers are not recorded. Use [APIKeys] before this.
+func Usage(db usageSaver, log *slog.Logger) httph.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, meter := ai.WithUsageMeter(r.Context())
+			r = r.WithContext(ctx)
+
+			next.ServeHTTP(w, r)
+
+			usage := meter.Usage()
+			if len(usage) == 0 {
+				return
+			}
+
+			var route string
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+
+			records := make([]model.Usage, 0, len(usage))
+			for _, u := range usage {
+				records = append(records, model.Usage{
+					APIKey:           apiKeyID(r),
+					Route:            route,
+					Operation:        u.Operation,
+					Model:            u.Model,
+					PromptTokens:     u.PromptTokens,
+					CompletionTokens: u.CompletionTokens,
+				})
+			}
+
+			// The response has been written, so the usage is saved even if the client has gone away
+			ctx = context.WithoutCancel(r.Context())
+			if err := db.SaveUsage(ctx, records); err != nil {
+				log.InfoContext(ctx, "Error saving token usage", "error", err)
+			}
+		})
+	}
+}
+
+// QuotaOptions for the [Quota] middleware.
+type QuotaOptions struct {
+	// DefaultTokens is the quota for API keys without their own quota. Zero means no quota.
+	DefaultTokens int
+
+	// Period is the rolling time window that quotas apply to. Defaults to 24 hours.
+	Period time.Duration
+}
+
+// Quota is Middleware that rejects requests with HTTP 429 Too Many Requests once the API key of the request
+// has used its token quota in the quota period. Requests are checked before they are served,
+// so the request that exhausts a quota is let through.
+// API keys have their own quota if set with the admin endpoints, see [UsageAdmin], or else the default quota.
+// Requests without an API key share the default quota. Use [APIKeys] before this.
+func Quota(db quotaChecker, opts QuotaOptions, log *slog.Logger) httph.Middleware {
+	if opts.DefaultTokens < 0 {
+		panic("default tokens cannot be negative")
+	}
+
+	if opts.Period == 0 {
+		opts.Period = 24 * time.Hour
+	}
+
+	return func(next http.Handler) http.Handler {
+		return httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+			key := apiKeyID(r)
+
+			// A quota of zero tokens for the key itself blocks it, but a zero default quota means no quota
+			tokens := opts.DefaultTokens
+			q, err := db.GetUsageQuota(r.Context(), key)
+			switch {
+			case err == nil:
+				tokens = q.Tokens
+			case !errors.Is(err, model.ErrorUsageQuotaNotFound):
+				log.InfoContext(r.Context(), "Error getting usage quota", "error", err)
+				return errors.Wrap(err, "error getting usage quota")
+			case tokens == 0:
+				next.ServeHTTP(w, r)
+				return nil
+			}
+
+			used, err := db.GetUsedTokens(r.Context(), key, time.Now().Add(-opts.Period))
+			if err != nil {
+				log.InfoContext(r.Context(), "Error getting used tokens", "error", err)
+				return errors.Wrap(err, "error getting used tokens")
+			}
+
+			if used >= tokens {
+				return httph.HTTPError{Code: http.StatusTooManyRequests, Err: errors.New("token quota exhausted")}
+			}
+
+			next.ServeHTTP(w, r)
+			return nil
+		})
+	}
+}
+
+// Admin is Middleware that only lets requests through with the admin API key as a bearer token,
+// and rejects other requests with HTTP 401 Unauthorized.
+func Admin(key string) httph.Middleware {
+	if key == "" {
+		panic("admin key cannot be empty")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(apiKey(r)), []byte(key)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UsageReportResponse has token usage totals, see [model.UsageTotal].
+type UsageReportResponse struct {
+	Totals []model.UsageTotal `json:"totals"`
+}
+
+// UsageQuotasResponse has the quotas of API keys with their own quota.
+type UsageQuotasResponse struct {
+	Quotas []model.UsageQuota `json:"quotas"`
+}
+
+// SetUsageQuotaRequest to set the token quota of an API key.
+type SetUsageQuotaRequest struct {
+	Tokens int `json:"tokens"`
+}
+
+func (r SetUsageQuotaRequest) Validate() error {
+	if r.Tokens < 0 {
+		return errors.New("tokens cannot be negative")
+	}
+	return nil
+}
+
+// UsageAdmin registers admin endpoints for token usage, which should be protected with the [Admin] middleware:
+//   - GET /admin/usage reports token usage totals, between the optional from and to query parameters (RFC 3339),
+//     grouped by the comma-separated groupBy query parameter with apiKey, route, operation, and model.
+//   - GET /admin/quotas lists API keys with their own quota.
+//   - PUT /admin/quotas/{apiKey} sets the quota of an issued API key.
+//   - DELETE /admin/quotas/{apiKey} removes the quota of an API key, so the default quota applies.
+//
+// API keys are identified by their [model.APIKey.ID], as in the usage report.
+func UsageAdmin(mux chi.Router, db usageAdministrator, log *slog.Logger) {
+	mux.Get("/admin/usage", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, _ any) (UsageReportResponse, error) {
+		opts, err := usageReportOptionsFromRequest(r)
+		if err != nil {
+			return UsageReportResponse{}, err
+		}
+
+		totals, err := db.GetUsageReport(r.Context(), opts)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error getting usage report", "error", err)
+			return UsageReportResponse{}, errors.Wrap(err, "error getting usage report")
+		}
+
+		if totals == nil {
+			totals = []model.UsageTotal{}
+		}
+
+		return UsageReportResponse{Totals: totals}, nil
+	}))
+
+	mux.Get("/admin/quotas", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, _ any) (UsageQuotasResponse, error) {
+		quotas, err := db.ListUsageQuotas(r.Context())
+		if err != nil {
+			log.InfoContext(r.Context(), "Error listing usage quotas", "error", err)
+			return UsageQuotasResponse{}, errors.Wrap(err, "error listing usage quotas")
+		}
+
+		if quotas == nil {
+			quotas = []model.UsageQuota{}
+		}
+
+		return UsageQuotasResponse{Quotas: quotas}, nil
+	}))
+
+	mux.Put("/admin/quotas/{apiKey:[a-f0-9]+}", httph.JSONHandler(func(w http.ResponseWriter, r *http.Request, req SetUsageQuotaRequest) (model.UsageQuota, error) {
+		q, err := db.SetUsageQuota(r.Context(), chi.URLParam(r, "apiKey"), req.Tokens)
+		if err != nil {
+			if errors.Is(err, model.ErrorAPIKeyNotFound) {
+				return model.UsageQuota{}, httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("API key not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error setting usage quota", "error", err)
+			return model.UsageQuota{}, errors.Wrap(err, "error setting usage quota")
+		}
+
+		return q, nil
+	}))
+
+	mux.Delete("/admin/quotas/{apiKey:[a-f0-9]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		if err := db.DeleteUsageQuota(r.Context(), chi.URLParam(r, "apiKey")); err != nil {
+			if errors.Is(err, model.ErrorUsageQuotaNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("usage quota not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error deleting usage quota", "error", err)
+			return errors.Wrap(err, "error deleting usage quota")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}))
+}
+
+// usageReportOptionsFromRequest from the from, to, and groupBy query parameters.
+func usageReportOptionsFromRequest(r *http.Request) (sql.GetUsageReportOptions, error) {
+	var opts sql.GetUsageReportOptions
+
+	for name, t := range map[string]*time.Time{"from": &opts.From, "to": &opts.To} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Newf("%v must be an RFC 3339 time", name)}
+		}
+		*t = parsed
+	}
+
+	if v := r.URL.Query().Get("groupBy"); v != "" {
+		for _, g := range strings.Split(v, ",") {
+			groupBy := model.UsageGroupBy(strings.TrimSpace(g))
+			if !groupBy.Valid() {
+				return opts, httph.HTTPError{Code: http.StatusBadRequest,
+					Err: errors.New("groupBy must be a comma-separated list of apiKey, route, operation, and model")}
+			}
+			opts.GroupBy = append(opts.GroupBy, groupBy)
+		}
+	}
+
+	return opts, nil
+}
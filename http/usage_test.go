@@ -0,0 +1,264 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/ai"
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+type usageSaverMock struct {
+	usage []model.Usage
+}
+
+func (m *usageSaverMock) SaveUsage(ctx context.Context, usage []model.Usage) error {
+	m.usage = append(m.usage, usage...)
+	return nil
+}
+
+type apiKeyGetterMock struct {
+	keys []string
+}
+
+func (m *apiKeyGetterMock) GetAPIKey(ctx context.Context, key string) (model.APIKey, error) {
+	if !slices.Contains(m.keys, key) {
+		return model.APIKey{}, model.ErrorAPIKeyNotFound
+	}
+	return model.APIKey{ID: model.APIKeyID(key)}, nil
+}
+
+type quotaCheckerMock struct {
+	quotas map[string]int
+	used   int
+}
+
+func (m *quotaCheckerMock) GetUsageQuota(ctx context.Context, apiKey string) (model.UsageQuota, error) {
+	tokens, ok := m.quotas[apiKey]
+	if !ok {
+		return model.UsageQuota{}, model.ErrorUsageQuotaNotFound
+	}
+	return model.UsageQuota{APIKey: apiKey, Tokens: tokens}, nil
+}
+
+func (m *quotaCheckerMock) GetUsedTokens(ctx context.Context, apiKey string, since time.Time) (int, error) {
+	return m.used, nil
+}
+
+func TestUsage(t *testing.T) {
+	t.Run("saves the token usage of the request with the API key ID and route", func(t *testing.T) {
+		server := httptest.NewServer(stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}], "usage": {"prompt_tokens": 7}}`))
+		}))
+		defer server.Close()
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL})
+
+		db := &usageSaverMock{}
+		mux := chi.NewRouter()
+		mux.Use(http.APIKeys(&apiKeyGetterMock{keys: []string{"abc"}}, slog.New(slog.DiscardHandler)))
+		mux.Use(http.Usage(db, slog.New(slog.DiscardHandler)))
+		mux.Get("/rerank/{id}", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			_, err := c.Rerank(r.Context(), "fluffy animals", []string{"Sheep"})
+			is.NotError(t, err)
+		})
+		mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+
+		req := httptest.NewRequest("GET", "/rerank/1", nil)
+		req.Header.Set("Authorization", "Bearer abc")
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest("GET", "/", nil)
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		is.Equal(t, 1, len(db.usage))
+		u := db.usage[0]
+		is.Equal(t, model.APIKeyID("abc"), u.APIKey)
+		is.True(t, u.APIKey != "abc")
+		is.Equal(t, "/rerank/{id}", u.Route)
+		is.Equal(t, "rerank", u.Operation)
+		is.Equal(t, 7, u.PromptTokens)
+	})
+}
+
+func TestQuota(t *testing.T) {
+	newMux := func(db *quotaCheckerMock, opts http.QuotaOptions) chi.Router {
+		mux := chi.NewRouter()
+		mux.Use(http.APIKeys(&apiKeyGetterMock{keys: []string{"abc", "def"}}, slog.New(slog.DiscardHandler)))
+		mux.Use(http.Quota(db, opts, slog.New(slog.DiscardHandler)))
+		mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+		return mux
+	}
+
+	serve := func(mux chi.Router, key string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("rejects requests once the API key has used its own quota", func(t *testing.T) {
+		db := &quotaCheckerMock{quotas: map[string]int{model.APIKeyID("abc"): 100}, used: 99}
+		mux := newMux(db, http.QuotaOptions{})
+
+		is.Equal(t, stdhttp.StatusOK, serve(mux, "abc"))
+
+		db.used = 100
+		is.Equal(t, stdhttp.StatusTooManyRequests, serve(mux, "abc"))
+		is.Equal(t, stdhttp.StatusOK, serve(mux, "def"))
+		is.Equal(t, stdhttp.StatusOK, serve(mux, ""))
+	})
+
+	t.Run("applies the default quota to API keys without their own, and to requests without a key", func(t *testing.T) {
+		db := &quotaCheckerMock{quotas: map[string]int{model.APIKeyID("abc"): 1000}, used: 500}
+		mux := newMux(db, http.QuotaOptions{DefaultTokens: 500})
+
+		is.Equal(t, stdhttp.StatusOK, serve(mux, "abc"))
+		is.Equal(t, stdhttp.StatusTooManyRequests, serve(mux, "def"))
+		is.Equal(t, stdhttp.StatusTooManyRequests, serve(mux, ""))
+	})
+
+	t.Run("rejects requests with keys that are not issued before checking quotas", func(t *testing.T) {
+		db := &quotaCheckerMock{used: 0}
+		mux := newMux(db, http.QuotaOptions{DefaultTokens: 500})
+
+		is.Equal(t, stdhttp.StatusUnauthorized, serve(mux, "made-up"))
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(http.Admin("secret"))
+	mux.Get("/", func(w stdhttp.ResponseWriter, r *stdhttp.Request) {})
+
+	for _, test := range []struct {
+		key  string
+		code int
+	}{
+		{"secret", stdhttp.StatusOK},
+		{"wrong", stdhttp.StatusUnauthorized},
+		{"", stdhttp.StatusUnauthorized},
+	} {
+		t.Run("key "+test.key, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if test.key != "" {
+				req.Header.Set("Authorization", "Bearer "+test.key)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			is.Equal(t, test.code, w.Code)
+		})
+	}
+}
+
+func TestUsageAdmin(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("reports token usage grouped by the groupBy query parameter", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.UsageAdmin(mux, db, log)
+
+		err := db.SaveUsage(t.Context(), []model.Usage{
+			{APIKey: "a", Route: "/search", Operation: "embed", Model: "embedder", PromptTokens: 10},
+			{APIKey: "b", Route: "/search", Operation: "embed", Model: "embedder", PromptTokens: 5},
+		})
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/admin/usage?groupBy=apiKey,model", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var res http.UsageReportResponse
+		err = json.Unmarshal(w.Body.Bytes(), &res)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(res.Totals))
+		is.Equal(t, "a", res.Totals[0].APIKey)
+		is.Equal(t, "embedder", res.Totals[0].Model)
+		is.Equal(t, 10, res.Totals[0].TotalTokens)
+	})
+
+	t.Run("returns bad request for invalid query parameters", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.UsageAdmin(mux, db, log)
+
+		for _, query := range []string{"groupBy=nope", "from=yesterday"} {
+			req := httptest.NewRequest("GET", "/admin/usage?"+query, nil)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("sets, lists, and deletes quotas", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.UsageAdmin(mux, db, log)
+
+		k, err := db.CreateAPIKey(t.Context(), "Sheep", model.NewAPIKey())
+		is.NotError(t, err)
+		key := k.ID
+
+		req := httptest.NewRequest("PUT", "/admin/quotas/"+model.APIKeyID("not issued"), strings.NewReader(`{"tokens": 1000}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+
+		req = httptest.NewRequest("PUT", "/admin/quotas/"+key, strings.NewReader(`{"tokens": 1000}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		req = httptest.NewRequest("GET", "/admin/quotas", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		var res http.UsageQuotasResponse
+		err = json.Unmarshal(w.Body.Bytes(), &res)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(res.Quotas))
+		is.Equal(t, key, res.Quotas[0].APIKey)
+		is.Equal(t, 1000, res.Quotas[0].Tokens)
+
+		req = httptest.NewRequest("DELETE", "/admin/quotas/"+key, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusNoContent, w.Code)
+
+		req = httptest.NewRequest("DELETE", "/admin/quotas/"+key, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+
+	t.Run("rejects negative quotas", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		mux := chi.NewRouter()
+		http.UsageAdmin(mux, db, log)
+
+		req := httptest.NewRequest("PUT", "/admin/quotas/"+model.APIKeyID("abc"), strings.NewReader(`{"tokens": -1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		is.Equal(t, stdhttp.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/errors"
+	"maragu.dev/httph"
+
+	"app/diff"
+	"app/model"
+)
+
+type documentVersioner interface {
+	GetDocument(ctx context.Context, id model.ID) (model.Document, error)
+	ListDocumentVersions(ctx context.Context, id model.ID) ([]model.DocumentVersion, error)
+	GetDocumentVersion(ctx context.Context, id model.ID, version int) (model.DocumentVersion, error)
+	UpdateDocument(ctx context.Context, d model.Document, chunks []model.Chunk, change model.Change) (model.Document, error)
+}
+
+// Versions registers endpoints for the version history of documents:
+//   - GET /documents/{id}/versions lists previous versions as Markdown links.
+//   - GET /documents/{id}/versions/{version} gets the content of a version.
+//   - GET /documents/{id}/diff?from=1&to=2 gets a unified diff between two versions, where "current" is the current
+//     content. By default, the diff is from the latest version to the current content.
+//   - POST /documents/{id}/versions/{version}/restore updates the document with the content and metadata of the version,
+//     which records the current content as a new version.
+func Versions(mux chi.Router, db documentVersioner, ai embedder, log *slog.Logger) {
+	mux.Get("/documents/{id:[a-z0-9_]+}/versions", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		versions, err := db.ListDocumentVersions(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error listing document versions", "error", err)
+			return errors.Wrap(err, "error listing document versions")
+		}
+
+		// Write the version list as markdown links, with who replaced each version and why, if known
+		for _, v := range versions {
+			line := fmt.Sprintf("- [Version %v](%v) from %v, replaced %v", v.Version,
+				link(r, fmt.Sprintf("/documents/%v/versions/%v", id, v.Version)), v.Created.String(), v.Replaced.String())
+			if v.Author != "" {
+				line += " by " + v.Author
+			}
+			if v.Comment != "" {
+				line += ": " + v.Comment
+			}
+			_, _ = w.Write([]byte(line + "\n"))
+		}
+
+		return nil
+	}))
+
+	mux.Get("/documents/{id:[a-z0-9_]+}/versions/{version:[0-9]+}", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		v, err := getVersion(r, db, log)
+		if err != nil {
+			return err
+		}
+
+		_, _ = w.Write([]byte(v.Content))
+
+		return nil
+	}))
+
+	mux.Get("/documents/{id:[a-z0-9_]+}/diff", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := model.ID(chi.URLParam(r, "id"))
+
+		doc, err := db.GetDocument(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error getting document", "error", err)
+			return errors.Wrap(err, "error getting document")
+		}
+
+		from := r.URL.Query().Get("from")
+		if from == "" {
+			versions, err := db.ListDocumentVersions(r.Context(), id)
+			if err != nil {
+				log.InfoContext(r.Context(), "Error listing document versions", "error", err)
+				return errors.Wrap(err, "error listing document versions")
+			}
+
+			from = "current"
+			if len(versions) > 0 {
+				from = strconv.Itoa(versions[len(versions)-1].Version)
+			}
+		}
+
+		to := r.URL.Query().Get("to")
+		if to == "" {
+			to = "current"
+		}
+
+		content := func(version string) (string, error) {
+			if version == "current" {
+				return doc.Content, nil
+			}
+
+			n, err := strconv.Atoi(version)
+			if err != nil {
+				return "", httph.HTTPError{Code: http.StatusBadRequest, Err: errors.New(`version must be a number or "current"`)}
+			}
+
+			v, err := db.GetDocumentVersion(r.Context(), id, n)
+			if err != nil {
+				if errors.Is(err, model.ErrorVersionNotFound) {
+					return "", httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("version not found")}
+				}
+
+				log.InfoContext(r.Context(), "Error getting document version", "error", err)
+				return "", errors.Wrap(err, "error getting document version")
+			}
+			return v.Content, nil
+		}
+
+		fromContent, err := content(from)
+		if err != nil {
+			return err
+		}
+		toContent, err := content(to)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "text/x-diff")
+		_, _ = w.Write([]byte(diff.Unified(string(id)+"@"+from, string(id)+"@"+to, fromContent, toContent)))
+
+		return nil
+	}))
+
+	mux.Post("/documents/{id:[a-z0-9_]+}/versions/{version:[0-9]+}/restore", httph.ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		v, err := getVersion(r, db, log)
+		if err != nil {
+			return err
+		}
+
+		doc := model.Document{ID: v.DocumentID, Content: v.Content, Metadata: v.Metadata}
+
+		chunks, err := doc.Chunk(r.Context(), ai.EmbedString)
+		if err != nil {
+			log.InfoContext(r.Context(), "Error creating document chunks", "error", err)
+			return aiError(w, err, http.StatusBadGateway, "error creating document chunks")
+		}
+
+		change := changeFromRequest(r)
+		if change.Comment == "" {
+			change.Comment = fmt.Sprintf("Restore version %v", v.Version)
+		}
+
+		if _, err := db.UpdateDocument(r.Context(), doc, chunks, change); err != nil {
+			if errors.Is(err, model.ErrorDocumentNotFound) {
+				return httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("document not found")}
+			}
+
+			log.InfoContext(r.Context(), "Error restoring document version", "error", err)
+			return errors.Wrap(err, "error restoring document version")
+		}
+
+		_, _ = w.Write([]byte(doc.Content))
+
+		return nil
+	}))
+}
+
+// getVersion from the id and version URL parameters.
+func getVersion(r *http.Request, db documentVersioner, log *slog.Logger) (model.DocumentVersion, error) {
+	id := model.ID(chi.URLParam(r, "id"))
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		return model.DocumentVersion{}, httph.HTTPError{Code: http.StatusBadRequest, Err: errors.Wrap(err, "invalid version")}
+	}
+
+	v, err := db.GetDocumentVersion(r.Context(), id, version)
+	if err != nil {
+		if errors.Is(err, model.ErrorVersionNotFound) {
+			return v, httph.HTTPError{Code: http.StatusNotFound, Err: errors.New("version not found")}
+		}
+
+		log.InfoContext(r.Context(), "Error getting document version", "error", err)
+		return v, errors.Wrap(err, "error getting document version")
+	}
+
+	return v, nil
+}
@@ -0,0 +1,96 @@
+package http_test
+
+import (
+	"log/slog"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/http"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestVersions(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	t.Run("lists, gets, diffs, and restores versions", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Versions(mux, db, ai, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep\nare\nfluffy\n"}, nil)
+		is.NotError(t, err)
+		doc.Content = "Goats\nare\nfluffy\n"
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{Author: "me", Comment: "Goats"})
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/versions", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.True(t, strings.HasPrefix(w.Body.String(), "- [Version 1](/documents/"+string(doc.ID)+"/versions/1)"))
+		is.True(t, strings.HasSuffix(w.Body.String(), " by me: Goats\n"))
+
+		req = httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/versions/1", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "Sheep\nare\nfluffy\n", w.Body.String())
+
+		req = httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/diff", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+		is.Equal(t, "text/x-diff", w.Header().Get("Content-Type"))
+		expected := "--- " + string(doc.ID) + "@1\n+++ " + string(doc.ID) + "@current\n@@ -1,3 +1,3 @@\n-Sheep\n+Goats\n are\n fluffy\n"
+		is.Equal(t, expected, w.Body.String())
+
+		req = httptest.NewRequest("POST", "/documents/"+string(doc.ID)+"/versions/1/restore", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusOK, w.Code)
+
+		restored, err := db.GetDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, "Sheep\nare\nfluffy\n", restored.Content)
+
+		versions, err := db.ListDocumentVersions(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(versions))
+		is.Equal(t, "Goats\nare\nfluffy\n", versions[1].Content)
+		is.Equal(t, "Restore version 1", versions[1].Comment)
+	})
+
+	t.Run("returns not found for unknown versions", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+		mux := chi.NewRouter()
+		http.Versions(mux, db, ai, log)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		req := httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/versions/1", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+
+		req = httptest.NewRequest("GET", "/documents/"+string(doc.ID)+"/diff?from=1", nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		is.Equal(t, stdhttp.StatusNotFound, w.Code)
+	})
+}
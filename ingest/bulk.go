@@ -0,0 +1,330 @@
+// Package ingest has document ingestion that is shared between HTTP handlers and background jobs.
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"maragu.dev/errors"
+
+	"app/dedup"
+	"app/jobs"
+	"app/model"
+)
+
+// BulkJobName is the name of background jobs for [Bulk] ingestion.
+const BulkJobName = "bulk-ingest"
+
+type documentsCreator interface {
+	CreateDocuments(ctx context.Context, docs []model.Document, chunks [][]model.Chunk) ([]model.Document, error)
+	CreateDocumentsIfNew(ctx context.Context, docs []model.Document, chunks [][]model.Chunk) ([]model.Document, []bool, error)
+	GetDocumentByContentHash(ctx context.Context, hash string) (model.Document, error)
+}
+
+type bulkJobDB interface {
+	documentsCreator
+	AppendJobProgress(ctx context.Context, id model.ID, progress string) error
+}
+
+type embedder interface {
+	EmbedString(ctx context.Context, s string) ([]byte, error)
+}
+
+// BulkDocument is a single line of bulk input.
+type BulkDocument struct {
+	Content  string         `json:"content"`
+	Metadata model.Metadata `json:"metadata,omitempty"`
+}
+
+// BulkResult for a single line of bulk input, with either the ID of the created document or an error.
+// With [model.DuplicatePolicyExisting], duplicates get the ID of the existing document and Duplicate set.
+// Errors for the whole input, like when it can't be read, have no line number.
+type BulkResult struct {
+	Line      int      `json:"line,omitempty"`
+	ID        model.ID `json:"id,omitempty"`
+	Duplicate bool     `json:"duplicate,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type BulkOptions struct {
+	// BatchSize is the number of documents created per database transaction. Defaults to 100.
+	BatchSize int
+
+	// DuplicatePolicy for documents with the same content as an existing document or an earlier line.
+	// Defaults to [model.DuplicatePolicyAllow].
+	DuplicatePolicy model.DuplicatePolicy
+}
+
+// bulkItem is a parsed and chunked line, waiting to be created in a batch.
+// Duplicates aren't chunked, and refer to either an existing document or an earlier line in the same batch.
+type bulkItem struct {
+	line        int
+	doc         model.Document
+	chunks      []model.Chunk
+	err         string
+	existing    model.ID
+	duplicateOf int
+}
+
+// Bulk creates documents from NDJSON input, with one [BulkDocument] per line. Empty lines are skipped.
+// Documents are chunked and embedded one at a time, and created in batches of [BulkOptions.BatchSize] per transaction.
+// The result for each line is passed to emit after its batch is done, in line order.
+// Invalid lines and lines that fail get an error result without stopping the rest of the input.
+func Bulk(ctx context.Context, db documentsCreator, ai embedder, r io.Reader, opts BulkOptions, emit func(BulkResult) error) error {
+	return bulk(ctx, db, ai, r, opts, 0, emit, func(int) error { return nil })
+}
+
+// bulk is [Bulk], but skipping lines up to and including the given line, and calling committed with the last line of
+// each batch after the batch is created and its results are emitted.
+func bulk(ctx context.Context, db documentsCreator, ai embedder, r io.Reader, opts BulkOptions, skip int,
+	emit func(BulkResult) error, committed func(line int) error) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	if opts.DuplicatePolicy == "" {
+		opts.DuplicatePolicy = model.DuplicatePolicyAllow
+	}
+
+	br := bufio.NewReader(r)
+	var batch []bulkItem
+	var line int
+	// seen has the line of the first document with each content hash in the current batch.
+	// Earlier batches are already in the database.
+	seen := map[string]int{}
+	for {
+		data, readErr := br.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return errors.Wrap(readErr, "error reading line %v", line+1)
+		}
+
+		if len(data) > 0 {
+			line++
+			if data = bytes.TrimSpace(data); len(data) > 0 && line > skip {
+				item, err := prepareBulkItem(ctx, db, ai, opts.DuplicatePolicy, seen, line, data)
+				if err != nil {
+					return err
+				}
+				batch = append(batch, item)
+			}
+		}
+
+		if len(batch) >= opts.BatchSize || (readErr != nil && len(batch) > 0) {
+			if err := createBulkBatch(ctx, db, opts.DuplicatePolicy, batch, emit); err != nil {
+				return err
+			}
+			if err := committed(line); err != nil {
+				return err
+			}
+			batch = nil
+			clear(seen)
+		}
+
+		if readErr != nil {
+			return nil
+		}
+	}
+}
+
+// prepareBulkItem by parsing, checking for duplicates, and chunking the line. Errors for the line are recorded in the item,
+// and only context errors are returned, since they stop the whole input.
+func prepareBulkItem(ctx context.Context, db documentsCreator, ai embedder, policy model.DuplicatePolicy, seen map[string]int,
+	line int, data []byte) (bulkItem, error) {
+	item := bulkItem{line: line}
+
+	var d BulkDocument
+	if err := json.Unmarshal(data, &d); err != nil {
+		item.err = "invalid JSON: " + err.Error()
+		return item, nil
+	}
+
+	if strings.TrimSpace(d.Content) == "" {
+		item.err = "content is required"
+		return item, nil
+	}
+
+	item.doc = model.Document{Content: d.Content, Metadata: d.Metadata}
+
+	if policy != model.DuplicatePolicyAllow {
+		hash := dedup.ContentHash(d.Content)
+		if first, ok := seen[hash]; ok {
+			item.duplicateOf = first
+		} else {
+			existing, err := db.GetDocumentByContentHash(ctx, hash)
+			switch {
+			case err == nil:
+				item.existing = existing.ID
+			case errors.Is(err, model.ErrorDocumentNotFound):
+				seen[hash] = line
+			case ctx.Err() != nil:
+				return item, ctx.Err()
+			default:
+				item.err = "error checking for duplicates: " + err.Error()
+				return item, nil
+			}
+		}
+
+		if policy == model.DuplicatePolicyReject {
+			switch {
+			case item.existing != "":
+				item.err = "duplicate of document " + string(item.existing)
+			case item.duplicateOf != 0:
+				item.err = fmt.Sprintf("duplicate of line %v", item.duplicateOf)
+			}
+		}
+
+		if item.existing != "" || item.duplicateOf != 0 {
+			return item, nil
+		}
+	}
+
+	var err error
+	item.chunks, err = item.doc.Chunk(ctx, ai.EmbedString)
+	if err != nil {
+		if ctx.Err() != nil {
+			return item, ctx.Err()
+		}
+		item.err = "error creating document chunks: " + err.Error()
+	}
+
+	return item, nil
+}
+
+// createBulkBatch of valid items in one transaction, and emit the results for all items.
+// If the transaction fails, all valid items in the batch get the error.
+// Duplicates of earlier lines in the batch get the result of that line.
+// Unless the policy allows duplicates, duplicates are checked for again when creating, in case they were created concurrently.
+func createBulkBatch(ctx context.Context, db documentsCreator, policy model.DuplicatePolicy, batch []bulkItem,
+	emit func(BulkResult) error) error {
+	var docs []model.Document
+	var chunks [][]model.Chunk
+	for _, item := range batch {
+		if item.isNew() {
+			docs = append(docs, item.doc)
+			chunks = append(chunks, item.chunks)
+		}
+	}
+
+	var created []model.Document
+	var isNew []bool
+	var createErr error
+	if len(docs) > 0 {
+		if policy == model.DuplicatePolicyAllow {
+			created, createErr = db.CreateDocuments(ctx, docs, chunks)
+		} else {
+			created, isNew, createErr = db.CreateDocumentsIfNew(ctx, docs, chunks)
+		}
+		if createErr != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	var i int
+	results := map[int]BulkResult{}
+	for _, item := range batch {
+		res := BulkResult{Line: item.line, Error: item.err}
+		switch {
+		case item.err != "":
+		case item.existing != "":
+			res.ID = item.existing
+			res.Duplicate = true
+		case item.duplicateOf != 0:
+			first := results[item.duplicateOf]
+			res.ID = first.ID
+			res.Error = first.Error
+			res.Duplicate = true
+		default:
+			switch {
+			case createErr != nil:
+				res.Error = "error creating document: " + createErr.Error()
+			case isNew != nil && !isNew[i] && policy == model.DuplicatePolicyReject:
+				res.Error = "duplicate of document " + string(created[i].ID)
+			case isNew != nil && !isNew[i]:
+				res.ID = created[i].ID
+				res.Duplicate = true
+			default:
+				res.ID = created[i].ID
+			}
+			i++
+		}
+		results[item.line] = res
+
+		if err := emit(res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isNew if the item should be created, because it's valid and not a duplicate.
+func (i bulkItem) isNew() bool {
+	return i.err == "" && i.existing == "" && i.duplicateOf == 0
+}
+
+// bulkJobProgress is saved as a line of job progress after each batch, see [BulkJob].
+type bulkJobProgress struct {
+	Line    int          `json:"line"`
+	Results []BulkResult `json:"results"`
+}
+
+// BulkJob returns a [jobs.Func] that runs [Bulk] with the NDJSON payload,
+// and returns the results as a JSON array of [BulkResult].
+// The results of each batch are saved as job progress, so an interrupted job resumes after the last created batch,
+// instead of creating its documents again.
+func BulkJob(db bulkJobDB, ai embedder, opts BulkOptions) jobs.Func {
+	return func(ctx context.Context, payload string) (string, error) {
+		job, isJob := jobs.JobFromContext(ctx)
+
+		results := []BulkResult{}
+		var skip int
+		if isJob {
+			for l := range strings.Lines(job.Progress) {
+				var p bulkJobProgress
+				if err := json.Unmarshal([]byte(l), &p); err != nil {
+					return "", errors.Wrap(err, "error parsing job progress")
+				}
+				results = append(results, p.Results...)
+				skip = p.Line
+			}
+		}
+
+		// batch has the results since the last saved progress
+		var batch []BulkResult
+		err := bulk(ctx, db, ai, strings.NewReader(payload), opts, skip, func(res BulkResult) error {
+			batch = append(batch, res)
+			return nil
+		}, func(line int) error {
+			results = append(results, batch...)
+			p := bulkJobProgress{Line: line, Results: batch}
+			batch = nil
+
+			if !isJob {
+				return nil
+			}
+
+			b, err := json.Marshal(p)
+			if err != nil {
+				return errors.Wrap(err, "error marshalling job progress")
+			}
+			if err := db.AppendJobProgress(ctx, job.ID, string(b)+"\n"); err != nil {
+				return errors.Wrap(err, "error saving job progress")
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		b, err := json.Marshal(results)
+		if err != nil {
+			return "", errors.Wrap(err, "error marshalling results")
+		}
+		return string(b), nil
+	}
+}
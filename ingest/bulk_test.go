@@ -0,0 +1,158 @@
+package ingest_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/dedup"
+	"app/ingest"
+	"app/jobs"
+	"app/model"
+	"app/sqltest"
+)
+
+const bulkInput = `{"content": "Sheep are fluffy."}
+not json
+{"content": ""}
+
+{"content": "Goats are not.", "metadata": {"source": "test"}}`
+
+func TestBulk(t *testing.T) {
+	t.Run("creates documents in batches and emits a result per line", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		var results []ingest.BulkResult
+		err := ingest.Bulk(t.Context(), db, ai, strings.NewReader(bulkInput), ingest.BulkOptions{BatchSize: 2}, func(res ingest.BulkResult) error {
+			results = append(results, res)
+			return nil
+		})
+		is.NotError(t, err)
+
+		is.Equal(t, 4, len(results))
+
+		is.Equal(t, 1, results[0].Line)
+		is.True(t, results[0].ID != "")
+		is.Equal(t, "", results[0].Error)
+
+		is.Equal(t, 2, results[1].Line)
+		is.True(t, strings.HasPrefix(results[1].Error, "invalid JSON"))
+
+		is.Equal(t, 3, results[2].Line)
+		is.Equal(t, "content is required", results[2].Error)
+
+		is.Equal(t, 5, results[3].Line)
+		is.True(t, results[3].ID != "")
+
+		doc, err := db.GetDocument(t.Context(), results[3].ID)
+		is.NotError(t, err)
+		is.Equal(t, "Goats are not.", doc.Content)
+		is.Equal(t, "test", doc.Metadata["source"])
+	})
+
+	t.Run("returns existing documents for duplicates with the existing policy", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		existing, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep are fluffy."}, nil)
+		is.NotError(t, err)
+
+		input := `{"content": "Sheep are fluffy."}
+{"content": "Goats are not."}
+{"content": "Goats are not."}`
+
+		var results []ingest.BulkResult
+		opts := ingest.BulkOptions{DuplicatePolicy: model.DuplicatePolicyExisting}
+		err = ingest.Bulk(t.Context(), db, ai, strings.NewReader(input), opts, func(res ingest.BulkResult) error {
+			results = append(results, res)
+			return nil
+		})
+		is.NotError(t, err)
+
+		is.Equal(t, 3, len(results))
+		is.Equal(t, existing.ID, results[0].ID)
+		is.True(t, results[0].Duplicate)
+		is.True(t, !results[1].Duplicate)
+		is.Equal(t, results[1].ID, results[2].ID)
+		is.True(t, results[2].Duplicate)
+	})
+
+	t.Run("fails duplicates with the reject policy", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		existing, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep are fluffy."}, nil)
+		is.NotError(t, err)
+
+		input := `{"content": "Sheep are fluffy."}
+{"content": "Goats are not."}
+{"content": "Goats are not."}`
+
+		var results []ingest.BulkResult
+		opts := ingest.BulkOptions{DuplicatePolicy: model.DuplicatePolicyReject}
+		err = ingest.Bulk(t.Context(), db, ai, strings.NewReader(input), opts, func(res ingest.BulkResult) error {
+			results = append(results, res)
+			return nil
+		})
+		is.NotError(t, err)
+
+		is.Equal(t, 3, len(results))
+		is.Equal(t, "duplicate of document "+string(existing.ID), results[0].Error)
+		is.Equal(t, "", results[1].Error)
+		is.Equal(t, "duplicate of line 2", results[2].Error)
+	})
+}
+
+func TestBulkJob(t *testing.T) {
+	t.Run("returns the results as a JSON array", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		fn := ingest.BulkJob(db, ai, ingest.BulkOptions{})
+		result, err := fn(t.Context(), bulkInput)
+		is.NotError(t, err)
+
+		var results []ingest.BulkResult
+		err = json.Unmarshal([]byte(result), &results)
+		is.NotError(t, err)
+		is.Equal(t, 4, len(results))
+	})
+
+	t.Run("resumes after the last batch in the job progress, and saves progress after each batch", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		_, err := db.CreateJob(t.Context(), ingest.BulkJobName, bulkInput, "")
+		is.NotError(t, err)
+		job, err := db.ClaimJob(t.Context())
+		is.NotError(t, err)
+		err = db.AppendJobProgress(t.Context(), job.ID, `{"line":2,"results":[{"line":1,"id":"d_earlier"},{"line":2,"error":"invalid JSON"}]}`+"\n")
+		is.NotError(t, err)
+		job, err = db.GetJob(t.Context(), job.ID)
+		is.NotError(t, err)
+
+		fn := ingest.BulkJob(db, ai, ingest.BulkOptions{BatchSize: 2})
+		result, err := fn(jobs.WithJob(t.Context(), job), job.Payload)
+		is.NotError(t, err)
+
+		var results []ingest.BulkResult
+		err = json.Unmarshal([]byte(result), &results)
+		is.NotError(t, err)
+		is.Equal(t, 4, len(results))
+		is.Equal(t, model.ID("d_earlier"), results[0].ID)
+		is.Equal(t, 3, results[2].Line)
+		is.Equal(t, 5, results[3].Line)
+		is.True(t, results[3].ID != "")
+
+		_, err = db.GetDocumentByContentHash(t.Context(), dedup.ContentHash("Sheep are fluffy."))
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		job, err = db.GetJob(t.Context(), job.ID)
+		is.NotError(t, err)
+		is.True(t, strings.HasSuffix(job.Progress, `{"line":5,"results":[{"line":3,"error":"content is required"},{"line":5,"id":"`+string(results[3].ID)+`"}]}`+"\n"))
+	})
+}
@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"maragu.dev/errors"
+
+	"app/ai"
+	"app/enrich"
+	"app/model"
+	"app/sql"
+)
+
+// Extractor extracts named entities, key phrases, and topics from newly ingested chunks.
+// It runs in the background instead of during ingestion, so ingestion isn't slowed down by a chat completion per chunk.
+type Extractor struct {
+	ai        *ai.Client
+	batchSize int
+	db        *sql.Database
+	interval  time.Duration
+	log       *slog.Logger
+}
+
+type NewExtractorOptions struct {
+	AI  *ai.Client
+	DB  *sql.Database
+	Log *slog.Logger
+
+	// BatchSize is the maximum number of chunks extracted from per interval. Defaults to 20.
+	BatchSize int
+
+	// Interval between checking for chunks to extract from. Defaults to 1 minute.
+	Interval time.Duration
+}
+
+func NewExtractor(opts NewExtractorOptions) *Extractor {
+	if opts.Log == nil {
+		opts.Log = slog.New(slog.DiscardHandler)
+	}
+
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 20
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+
+	return &Extractor{
+		ai:        opts.AI,
+		batchSize: opts.BatchSize,
+		db:        opts.DB,
+		interval:  opts.Interval,
+		log:       opts.Log,
+	}
+}
+
+// Start extracting, once right away and then every interval, blocking until the context is cancelled.
+func (e *Extractor) Start(ctx context.Context) {
+	e.log.Info("Starting chunk extractor")
+
+	for {
+		e.Extract(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.log.Info("Stopped chunk extractor")
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// Extract from a batch of chunks that haven't been extracted from yet, oldest first.
+// Chunks the model gives invalid output for are saved without entities, key phrases, and topics, so they're not retried.
+// Otherwise, it stops at the first error, so an unavailable model server isn't called for every chunk.
+// Token usage is saved for [model.BackgroundAPIKey].
+func (e *Extractor) Extract(ctx context.Context) {
+	meterUsage(ctx, e.db, e.log, model.BackgroundAPIKey, "extractor", e.extract)
+}
+
+func (e *Extractor) extract(ctx context.Context) {
+	chunks, err := e.db.ListUnextractedChunks(ctx, e.batchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.log.Info("Error listing chunks to extract from", "error", err)
+		}
+		return
+	}
+
+	var n int
+	for _, c := range chunks {
+		if _, err := enrich.ExtractChunk(ctx, e.db, e.ai, c); err != nil {
+			if errors.Is(err, ai.ErrInvalidJSON) {
+				e.log.Info("Invalid output extracting from chunk, skipping", "id", c.ID, "error", err)
+				if err := e.db.SaveChunkExtraction(ctx, c, model.Extraction{}); err != nil {
+					e.log.Info("Error saving empty chunk extraction", "id", c.ID, "error", err)
+					break
+				}
+				continue
+			}
+
+			if ctx.Err() == nil {
+				e.log.Info("Error extracting from chunk", "id", c.ID, "error", err)
+			}
+			break
+		}
+		n++
+	}
+
+	if n > 0 {
+		e.log.Info("Extracted from chunks", "count", n)
+	}
+}
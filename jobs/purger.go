@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"app/sql"
+)
+
+// Purger permanently deletes documents that have been in the trash for longer than the retention period.
+type Purger struct {
+	db        *sql.Database
+	interval  time.Duration
+	log       *slog.Logger
+	retention time.Duration
+}
+
+type NewPurgerOptions struct {
+	DB  *sql.Database
+	Log *slog.Logger
+
+	// Interval between purges. Defaults to 1 hour.
+	Interval time.Duration
+
+	// Retention is how long documents stay in the trash before they're purged. Defaults to 30 days.
+	Retention time.Duration
+}
+
+func NewPurger(opts NewPurgerOptions) *Purger {
+	if opts.Log == nil {
+		opts.Log = slog.New(slog.DiscardHandler)
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = time.Hour
+	}
+
+	if opts.Retention == 0 {
+		opts.Retention = 30 * 24 * time.Hour
+	}
+
+	return &Purger{
+		db:        opts.DB,
+		interval:  opts.Interval,
+		log:       opts.Log,
+		retention: opts.Retention,
+	}
+}
+
+// Start purging, once right away and then every interval, blocking until the context is cancelled.
+func (p *Purger) Start(ctx context.Context) {
+	p.log.Info("Starting trash purger", "retention", p.retention)
+
+	for {
+		p.Purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			p.log.Info("Stopped trash purger")
+			return
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+// Purge documents that were moved to the trash longer than the retention period ago.
+func (p *Purger) Purge(ctx context.Context) {
+	n, err := p.db.PurgeDocuments(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			p.log.Info("Error purging documents", "error", err)
+		}
+		return
+	}
+
+	if n > 0 {
+		p.log.Info("Purged documents from the trash", "count", n)
+	}
+}
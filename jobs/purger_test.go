@@ -0,0 +1,38 @@
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"app/jobs"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestPurger_Purge(t *testing.T) {
+	t.Run("purges documents in the trash for longer than the retention period", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		p := jobs.NewPurger(jobs.NewPurgerOptions{DB: db, Retention: time.Hour})
+		p.Purge(t.Context())
+
+		deleted, err := db.ListDeletedDocuments(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(deleted))
+
+		time.Sleep(5 * time.Millisecond)
+		p = jobs.NewPurger(jobs.NewPurgerOptions{DB: db, Retention: time.Millisecond})
+		p.Purge(t.Context())
+
+		deleted, err = db.ListDeletedDocuments(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(deleted))
+	})
+}
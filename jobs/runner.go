@@ -0,0 +1,174 @@
+// Package jobs has a [Runner] for background jobs, which are queued in the database,
+// a [Purger] that empties the trash periodically, a [Summarizer] that keeps document summaries up to date,
+// and an [Extractor] that extracts entities, key phrases, and topics from chunks.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+
+	"app/model"
+	"app/sql"
+	"app/tracing"
+)
+
+var tracer = otel.Tracer("app/jobs")
+
+// Func runs a job with the given payload, returning a result that is stored with the job.
+// If the context is cancelled because the app is shutting down, the job is run again on the next start,
+// so job funcs should be safe to run more than once, or save their progress and resume from it.
+// The job is in the context, see [JobFromContext].
+type Func func(ctx context.Context, payload string) (string, error)
+
+type jobContextKey struct{}
+
+// WithJob returns a context with the given job, which the [Runner] passes to the [Func] running it.
+func WithJob(ctx context.Context, job model.Job) context.Context {
+	return context.WithValue(ctx, jobContextKey{}, job)
+}
+
+// JobFromContext returns the job that is being run with the context, if any. See [WithJob].
+func JobFromContext(ctx context.Context) (model.Job, bool) {
+	job, ok := ctx.Value(jobContextKey{}).(model.Job)
+	return job, ok
+}
+
+// Runner runs jobs from the queue in the database, one at a time, oldest first.
+// The token usage of each job is saved for the API key the job was created with, see [model.Job].
+type Runner struct {
+	db           *sql.Database
+	funcs        map[string]Func
+	log          *slog.Logger
+	pollInterval time.Duration
+}
+
+type NewRunnerOptions struct {
+	DB  *sql.Database
+	Log *slog.Logger
+
+	// PollInterval is how often to check the queue for new jobs when it's empty. Defaults to 1 second.
+	PollInterval time.Duration
+}
+
+func NewRunner(opts NewRunnerOptions) *Runner {
+	if opts.Log == nil {
+		opts.Log = slog.New(slog.DiscardHandler)
+	}
+
+	if opts.PollInterval == 0 {
+		opts.PollInterval = time.Second
+	}
+
+	return &Runner{
+		db:           opts.DB,
+		funcs:        map[string]Func{},
+		log:          opts.Log,
+		pollInterval: opts.PollInterval,
+	}
+}
+
+// Register a [Func] to run jobs with the given name.
+// Must be called before [Runner.Start].
+func (r *Runner) Register(name string, fn Func) {
+	if _, ok := r.funcs[name]; ok {
+		panic("job func already registered for " + name)
+	}
+	r.funcs[name] = fn
+}
+
+// Start running jobs, blocking until the context is cancelled.
+func (r *Runner) Start(ctx context.Context) {
+	r.log.Info("Starting job runner")
+
+	if err := r.db.ResetRunningJobs(ctx); err != nil {
+		r.log.Info("Error resetting running jobs", "error", err)
+	}
+
+	for {
+		if r.runNext(ctx) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			r.log.Info("Stopped job runner")
+			return
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// runNext job in the queue, returning whether there was one.
+func (r *Runner) runNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	job, err := r.db.ClaimJob(ctx)
+	if err != nil {
+		if !errors.Is(err, model.ErrorJobNotFound) && ctx.Err() == nil {
+			r.log.Info("Error claiming job", "error", err)
+		}
+		return false
+	}
+
+	r.run(ctx, job)
+	return true
+}
+
+func (r *Runner) run(ctx context.Context, job model.Job) {
+	ctx, span := tracer.Start(ctx, "jobs.run", trace.WithAttributes(
+		attribute.String("job.id", string(job.ID)),
+		attribute.String("job.name", job.Name),
+	))
+
+	log := r.log.With("id", job.ID, "name", job.Name)
+
+	fn, ok := r.funcs[job.Name]
+	if !ok {
+		err := errors.Newf("no job func registered for %v", job.Name)
+		tracing.End(span, err)
+		log.InfoContext(ctx, "Error running job", "error", err)
+		if err := r.db.FailJob(ctx, job.ID, err.Error()); err != nil {
+			log.InfoContext(ctx, "Error failing job", "error", err)
+		}
+		return
+	}
+
+	log.InfoContext(ctx, "Running job")
+	start := time.Now()
+
+	var result string
+	var err error
+	meterUsage(ctx, r.db, log, job.APIKey, job.Name, func(ctx context.Context) {
+		result, err = fn(WithJob(ctx, job), job.Payload)
+	})
+	tracing.End(span, err)
+
+	// If the app is shutting down, leave the job running, so it's reset and run again on the next start
+	if ctx.Err() != nil {
+		log.Info("Interrupted job")
+		return
+	}
+
+	if err != nil {
+		log.InfoContext(ctx, "Error running job", "error", err)
+		if err := r.db.FailJob(ctx, job.ID, err.Error()); err != nil {
+			log.InfoContext(ctx, "Error failing job", "error", err)
+		}
+		return
+	}
+
+	if err := r.db.CompleteJob(ctx, job.ID, result); err != nil {
+		log.InfoContext(ctx, "Error completing job", "error", err)
+		return
+	}
+
+	log.InfoContext(ctx, "Completed job", "duration", time.Since(start))
+}
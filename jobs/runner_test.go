@@ -0,0 +1,126 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"app/ai"
+	"app/jobs"
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestRunner_Start(t *testing.T) {
+	t.Run("runs jobs and stores results", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		r := jobs.NewRunner(jobs.NewRunnerOptions{DB: db, PollInterval: time.Millisecond})
+		r.Register("echo", func(ctx context.Context, payload string) (string, error) {
+			return "echo: " + payload, nil
+		})
+
+		job, err := db.CreateJob(t.Context(), "echo", "hi", "")
+		is.NotError(t, err)
+
+		startRunner(t, r)
+
+		job = waitForJob(t, db, job.ID)
+		is.Equal(t, model.JobStatusDone, job.Status)
+		is.Equal(t, "echo: hi", job.Result)
+	})
+
+	t.Run("fails jobs that return an error", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		r := jobs.NewRunner(jobs.NewRunnerOptions{DB: db, PollInterval: time.Millisecond})
+		r.Register("fail", func(ctx context.Context, payload string) (string, error) {
+			return "", errors.New("oh no")
+		})
+
+		job, err := db.CreateJob(t.Context(), "fail", "", "")
+		is.NotError(t, err)
+
+		startRunner(t, r)
+
+		job = waitForJob(t, db, job.ID)
+		is.Equal(t, model.JobStatusFailed, job.Status)
+		is.Equal(t, "oh no", job.Error)
+	})
+
+	t.Run("saves the token usage of jobs for the API key of the job", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"results": [{"index": 0, "relevance_score": 0.9}], "usage": {"prompt_tokens": 7, "total_tokens": 7}}`))
+		}))
+		defer server.Close()
+		c := ai.NewClient(ai.NewClientOptions{RerankerBaseURL: server.URL})
+
+		db := sqltest.NewDatabase(t)
+		r := jobs.NewRunner(jobs.NewRunnerOptions{DB: db, PollInterval: time.Millisecond})
+		r.Register("rerank", func(ctx context.Context, payload string) (string, error) {
+			_, err := c.Rerank(ctx, payload, []string{"Sheep"})
+			return "", err
+		})
+
+		job, err := db.CreateJob(t.Context(), "rerank", "fluffy animals", "abc")
+		is.NotError(t, err)
+
+		startRunner(t, r)
+
+		job = waitForJob(t, db, job.ID)
+		is.Equal(t, model.JobStatusDone, job.Status)
+
+		used, err := db.GetUsedTokens(t.Context(), "abc", time.Time{})
+		is.NotError(t, err)
+		is.Equal(t, 7, used)
+	})
+
+	t.Run("fails jobs without a registered func", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		r := jobs.NewRunner(jobs.NewRunnerOptions{DB: db, PollInterval: time.Millisecond})
+
+		job, err := db.CreateJob(t.Context(), "unknown", "", "")
+		is.NotError(t, err)
+
+		startRunner(t, r)
+
+		job = waitForJob(t, db, job.ID)
+		is.Equal(t, model.JobStatusFailed, job.Status)
+	})
+}
+
+func startRunner(t *testing.T, r *jobs.Runner) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		r.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitForJob to be done or failed.
+func waitForJob(t *testing.T, db *sql.Database, id model.ID) model.Job {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := db.GetJob(t.Context(), id)
+		is.NotError(t, err)
+		if job.Status == model.JobStatusDone || job.Status == model.JobStatusFailed {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out waiting for job")
+	return model.Job{}
+}
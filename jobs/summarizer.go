@@ -0,0 +1,125 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"app/ai"
+	"app/enrich"
+	"app/model"
+	"app/sql"
+)
+
+// Summarizer generates summaries of documents that don't have one, or whose content has changed since.
+type Summarizer struct {
+	ai          *ai.Client
+	batchSize   int
+	db          *sql.Database
+	interval    time.Duration
+	log         *slog.Logger
+	maxAttempts int
+}
+
+type NewSummarizerOptions struct {
+	AI  *ai.Client
+	DB  *sql.Database
+	Log *slog.Logger
+
+	// BatchSize is the maximum number of documents summarized per interval. Defaults to 10.
+	BatchSize int
+
+	// Interval between checking for documents to summarize. Defaults to 1 minute.
+	Interval time.Duration
+
+	// MaxAttempts at summarizing the content of a document, before it's skipped until the content changes. Defaults to 3.
+	MaxAttempts int
+}
+
+func NewSummarizer(opts NewSummarizerOptions) *Summarizer {
+	if opts.Log == nil {
+		opts.Log = slog.New(slog.DiscardHandler)
+	}
+
+	if opts.BatchSize == 0 {
+		opts.BatchSize = 10
+	}
+
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 3
+	}
+
+	return &Summarizer{
+		ai:          opts.AI,
+		batchSize:   opts.BatchSize,
+		db:          opts.DB,
+		interval:    opts.Interval,
+		log:         opts.Log,
+		maxAttempts: opts.MaxAttempts,
+	}
+}
+
+// Start summarizing, once right away and then every interval, blocking until the context is cancelled.
+func (s *Summarizer) Start(ctx context.Context) {
+	s.log.Info("Starting document summarizer")
+
+	for {
+		s.Summarize(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.log.Info("Stopped document summarizer")
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// Summarize a batch of documents with missing or stale summaries, least recently updated first.
+// Failures are recorded and the document skipped, so a document that keeps failing doesn't block the others,
+// and it's not retried after the max attempts until its content changes.
+// If the model servers are unavailable, it stops, so they aren't called for every document.
+// Token usage is saved for [model.BackgroundAPIKey].
+func (s *Summarizer) Summarize(ctx context.Context) {
+	meterUsage(ctx, s.db, s.log, model.BackgroundAPIKey, "summarizer", s.summarize)
+}
+
+func (s *Summarizer) summarize(ctx context.Context) {
+	docs, err := s.db.ListDocumentsWithStaleSummary(ctx, s.batchSize, s.maxAttempts)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.Info("Error listing documents to summarize", "error", err)
+		}
+		return
+	}
+
+	var n int
+	for _, doc := range docs {
+		if _, err := enrich.SummarizeDocument(ctx, s.db, s.ai, doc); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			if ai.IsUnavailable(err) {
+				s.log.Info("Model servers unavailable summarizing document, stopping", "id", doc.ID, "error", err)
+				break
+			}
+
+			s.log.Info("Error summarizing document, skipping", "id", doc.ID, "error", err)
+			if err := s.db.RecordSummaryFailure(ctx, doc, err.Error()); err != nil {
+				s.log.Info("Error recording summary failure", "id", doc.ID, "error", err)
+				break
+			}
+			continue
+		}
+		n++
+	}
+
+	if n > 0 {
+		s.log.Info("Summarized documents", "count", n)
+	}
+}
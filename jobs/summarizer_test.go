@@ -0,0 +1,33 @@
+package jobs_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/jobs"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestSummarizer_Summarize(t *testing.T) {
+	t.Run("summarizes documents without a summary", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		// Empty content has an empty summary, so the model servers aren't needed
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: ""}, nil)
+		is.NotError(t, err)
+
+		s := jobs.NewSummarizer(jobs.NewSummarizerOptions{AI: aitest.NewClient(t), DB: db})
+		s.Summarize(t.Context())
+
+		docs, err := db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 0, len(docs))
+
+		summary, err := db.GetDocumentSummary(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, "", summary.Content)
+	})
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+
+	"app/ai"
+	"app/model"
+	"app/sql"
+)
+
+// meterUsage of tokens in calls to the model servers made by fn, and save it for the API key with the name as the route,
+// like the usage of requests is saved by the HTTP usage middleware.
+func meterUsage(ctx context.Context, db *sql.Database, log *slog.Logger, apiKey, name string, fn func(ctx context.Context)) {
+	ctx, meter := ai.WithUsageMeter(ctx)
+
+	fn(ctx)
+
+	usage := meter.Usage()
+	if len(usage) == 0 {
+		return
+	}
+
+	records := make([]model.Usage, 0, len(usage))
+	for _, u := range usage {
+		records = append(records, model.Usage{
+			APIKey:           apiKey,
+			Route:            name,
+			Operation:        u.Operation,
+			Model:            u.Model,
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+		})
+	}
+
+	// The tokens are used even if the app is shutting down
+	ctx = context.WithoutCancel(ctx)
+	if err := db.SaveUsage(ctx, records); err != nil {
+		log.InfoContext(ctx, "Error saving token usage", "name", name, "error", err)
+	}
+}
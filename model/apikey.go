@@ -0,0 +1,36 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// APIKey issued to a client. The key itself is only known when it's created with [NewAPIKey],
+// and is stored as its hash. ID is derived from the key with [APIKeyID], so it's safe to show in reports.
+type APIKey struct {
+	ID      string `json:"id"`
+	Created Time   `json:"created"`
+	Name    string `json:"name"`
+	Revoked *Time  `json:"revoked,omitempty"` // when the key was revoked, nil if it's still valid
+}
+
+// NewAPIKey returns a new random API key.
+func NewAPIKey() string {
+	return "sk_" + rand.Text()
+}
+
+// APIKeyID identifies an API key without revealing the key itself, as the start of its hex-encoded SHA-256 hash.
+func APIKeyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:8])
+}
+
+// APIKeyHash is the hex-encoded SHA-256 hash of an API key, which is what is stored instead of the key.
+func APIKeyHash(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
@@ -3,14 +3,24 @@ package model
 import (
 	"context"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"maragu.dev/gai"
+
+	"app/tracing"
 )
 
+var tracer = otel.Tracer("app/model")
+
 type embedderFunc = func(ctx context.Context, text string) ([]byte, error)
 
 // Chunk splits document content into chunks with embeddings.
 // It uses a fixed size chunker with the specified size and overlap.
-func (d Document) Chunk(ctx context.Context, embedder embedderFunc) ([]Chunk, error) {
+func (d Document) Chunk(ctx context.Context, embedder embedderFunc) (_ []Chunk, err error) {
+	ctx, span := tracer.Start(ctx, "model.Document.Chunk", trace.WithAttributes(attribute.Int("content.length", len(d.Content))))
+	defer func() { tracing.End(span, err) }()
+
 	tokenizer := &gai.NaiveWordTokenizer{}
 	chunker := gai.NewFixedSizeChunker(gai.NewFixedSizeChunkerOptions{
 		Tokenizer: tokenizer,
@@ -19,6 +29,8 @@ func (d Document) Chunk(ctx context.Context, embedder embedderFunc) ([]Chunk, er
 	})
 
 	textChunks := chunker.Chunk(ctx, d.Content)
+	span.SetAttributes(attribute.Int("chunks", len(textChunks)))
+
 	return createChunksWithEmbeddings(ctx, textChunks, embedder)
 }
 
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Citation of a source chunk in an answer, where Number is the n in the [n] marker in the answer text.
+// Quote is the span of the chunk content that best supports the cited claim.
+type Citation struct {
+	Number     int    `json:"number"`
+	DocumentID ID     `json:"documentID"`
+	ChunkIndex int    `json:"chunkIndex"`
+	Quote      string `json:"quote"`
+}
+
+// Citations of an answer. They're stored as a JSON array.
+type Citations []Citation
+
+// Value satisfies driver.Valuer interface.
+func (c Citations) Value() (driver.Value, error) {
+	if c == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan satisfies sql.Scanner interface.
+func (c *Citations) Scan(src any) error {
+	if src == nil {
+		*c = nil
+		return nil
+	}
+
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("error scanning citations, got %+v", src)
+	}
+
+	return json.Unmarshal([]byte(s), c)
+}
+
+// UngroundedPolicy decides what happens when an answer doesn't cite any of its sources.
+type UngroundedPolicy string
+
+const (
+	// UngroundedPolicyAllow returns the answer as is.
+	UngroundedPolicyAllow = UngroundedPolicy("allow")
+
+	// UngroundedPolicyFlag returns the answer with a note that it's not grounded in the sources.
+	UngroundedPolicyFlag = UngroundedPolicy("flag")
+
+	// UngroundedPolicyRefuse replaces the answer with a refusal to answer.
+	UngroundedPolicyRefuse = UngroundedPolicy("refuse")
+)
+
+// Valid if the policy is one of the known policies.
+func (p UngroundedPolicy) Valid() bool {
+	switch p {
+	case UngroundedPolicyAllow, UngroundedPolicyFlag, UngroundedPolicyRefuse:
+		return true
+	default:
+		return false
+	}
+}
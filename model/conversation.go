@@ -0,0 +1,33 @@
+package model
+
+// Conversation is a chat session with the assistant, which has [Message]s.
+// Summary is of the oldest messages that no longer fit in the model context,
+// and SummarizedMessages is how many of the oldest messages it covers.
+type Conversation struct {
+	ID                 ID
+	Created            Time
+	Updated            Time
+	Title              string
+	Summary            string
+	SummarizedMessages int `db:"summarizedMessages"`
+}
+
+type MessageRole string
+
+const (
+	MessageRoleUser      = MessageRole("user")
+	MessageRoleAssistant = MessageRole("assistant")
+)
+
+// Message in a [Conversation], from either the user or the assistant.
+// Assistant messages have Citations of the sources in the Content,
+// and the Prompt is the ID of the prompt template that produced them.
+type Message struct {
+	ID             ID
+	Created        Time
+	ConversationID ID `db:"conversationID"`
+	Role           MessageRole
+	Content        string
+	Citations      Citations
+	Prompt         string
+}
@@ -0,0 +1,25 @@
+package model
+
+// DuplicatePolicy decides what happens when a document is created with the same content as an existing document.
+type DuplicatePolicy string
+
+const (
+	// DuplicatePolicyAllow creates the document anyway.
+	DuplicatePolicyAllow = DuplicatePolicy("allow")
+
+	// DuplicatePolicyReject fails with [ErrorDocumentDuplicate].
+	DuplicatePolicyReject = DuplicatePolicy("reject")
+
+	// DuplicatePolicyExisting returns the existing document instead of creating a new one.
+	DuplicatePolicyExisting = DuplicatePolicy("existing")
+)
+
+// Valid if the policy is one of the known policies.
+func (p DuplicatePolicy) Valid() bool {
+	switch p {
+	case DuplicatePolicyAllow, DuplicatePolicyReject, DuplicatePolicyExisting:
+		return true
+	default:
+		return false
+	}
+}
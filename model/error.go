@@ -4,7 +4,14 @@ package model
 type Error string
 
 const (
-	ErrorDocumentNotFound = Error("DOCUMENT_NOT_FOUND")
+	ErrorAPIKeyNotFound       = Error("API_KEY_NOT_FOUND")
+	ErrorConversationNotFound = Error("CONVERSATION_NOT_FOUND")
+	ErrorDocumentDuplicate    = Error("DOCUMENT_DUPLICATE")
+	ErrorDocumentNotFound     = Error("DOCUMENT_NOT_FOUND")
+	ErrorJobNotFound          = Error("JOB_NOT_FOUND")
+	ErrorSummaryNotFound      = Error("SUMMARY_NOT_FOUND")
+	ErrorUsageQuotaNotFound   = Error("USAGE_QUOTA_NOT_FOUND")
+	ErrorVersionNotFound      = Error("VERSION_NOT_FOUND")
 )
 
 func (e Error) Error() string {
@@ -0,0 +1,55 @@
+package model
+
+// EntityType is the kind of a named entity.
+type EntityType string
+
+const (
+	EntityTypePerson       = EntityType("person")
+	EntityTypePlace        = EntityType("place")
+	EntityTypeOrganization = EntityType("organization")
+)
+
+// Valid if the type is one of the known entity types.
+func (t EntityType) Valid() bool {
+	switch t {
+	case EntityTypePerson, EntityTypePlace, EntityTypeOrganization:
+		return true
+	default:
+		return false
+	}
+}
+
+// Entity is a named entity mentioned in a chunk, like a person, place, or organization.
+type Entity struct {
+	Name string     `json:"name"`
+	Type EntityType `json:"type"`
+}
+
+// Extraction is the structured output of extracting entities, key phrases, and topics from a chunk.
+type Extraction struct {
+	Entities   []Entity `json:"entities"`
+	KeyPhrases []string `json:"keyPhrases"`
+	Topics     []string `json:"topics"`
+
+	// Prompt is the ID of the prompt template that produced the extraction.
+	Prompt string `json:"-"`
+}
+
+// EntityCount is an entity and the number of documents that mention it.
+type EntityCount struct {
+	Name  string
+	Type  EntityType
+	Count int
+}
+
+// TopicCount is a topic and the number of documents that have it.
+type TopicCount struct {
+	Topic string
+	Count int
+}
+
+// Facets of search results, with the number of result documents for each value.
+type Facets struct {
+	Entities []EntityCount
+	Topics   []TopicCount
+}
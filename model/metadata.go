@@ -0,0 +1,38 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Metadata about a document, like the file name and media type it was uploaded with.
+// It's stored as a JSON object.
+type Metadata map[string]string
+
+// Value satisfies driver.Valuer interface.
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan satisfies sql.Scanner interface.
+func (m *Metadata) Scan(src any) error {
+	if src == nil {
+		*m = nil
+		return nil
+	}
+
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("error scanning metadata, got %+v", src)
+	}
+
+	return json.Unmarshal([]byte(s), m)
+}
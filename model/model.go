@@ -3,11 +3,54 @@ package model
 
 type ID string
 
+// DefaultCollection is the collection all documents are in, until documents can be organized in collections.
+const DefaultCollection = "default"
+
 type Document struct {
-	ID      ID
-	Created Time
-	Updated Time
-	Content string
+	ID          ID
+	Created     Time
+	Updated     Time
+	Content     string
+	Metadata    Metadata
+	ContentHash string `db:"contentHash"`
+	SimHash     int64  `db:"simHash"`    // the 64-bit fingerprint stored as a signed integer, since that's what SQLite has
+	ExternalID  string `db:"externalID"` // optional ID from the client's own system, unique in the collection if set
+	Deleted     *Time  // when the document was moved to the trash, nil if it's not in the trash
+	Summary     string // generated summary, only set in list views
+}
+
+// SummaryChunkIndex is the chunk index of the summary chunk of a document, which is searchable like other chunks.
+const SummaryChunkIndex = -1
+
+// DocumentSummary is a generated summary of a document.
+// ContentHash is the hash of the content that was summarized, so summaries of changed content can be found.
+// Prompt is the IDs of the prompt templates that produced the summary, separated by commas.
+type DocumentSummary struct {
+	DocumentID  ID `db:"documentID"`
+	Created     Time
+	Content     string
+	ContentHash string `db:"contentHash"`
+	Prompt      string
+}
+
+// DocumentVersion is a previous revision of a document, recorded when the document is changed.
+// Created is when the revision was written, and Replaced is when it was replaced by the next one.
+// Author and Comment are from the [Change] that replaced it.
+type DocumentVersion struct {
+	DocumentID ID `db:"documentID"`
+	Version    int
+	Created    Time
+	Replaced   Time
+	Content    string
+	Metadata   Metadata
+	Author     string
+	Comment    string
+}
+
+// Change describes a change to a document, for its version history. Both fields are optional.
+type Change struct {
+	Author  string
+	Comment string
 }
 
 type Chunk struct {
@@ -19,3 +62,28 @@ type Chunk struct {
 	Content    string
 	Embedding  []byte
 }
+
+type JobStatus string
+
+const (
+	JobStatusPending = JobStatus("pending")
+	JobStatusRunning = JobStatus("running")
+	JobStatusDone    = JobStatus("done")
+	JobStatusFailed  = JobStatus("failed")
+)
+
+// Job in the background queue. The payload, result, and progress are job-specific, usually JSON.
+// Progress is saved by running jobs, so they can resume from it if they're interrupted, and cleared when they're done.
+// APIKey is the [APIKey.ID] of the API key the job was created with, if any, which its token usage is saved for.
+type Job struct {
+	ID       ID
+	Created  Time
+	Updated  Time
+	Name     string
+	Status   JobStatus
+	Payload  string
+	Result   string
+	Error    string
+	Progress string
+	APIKey   string `db:"apiKey"`
+}
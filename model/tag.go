@@ -0,0 +1,23 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tagPattern is letters, digits, and some separators, starting with a letter or digit.
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N}_:.-]{0,63}$`)
+
+// NormalizeTag by trimming whitespace and lowercasing, returning false if the result is not a valid tag.
+// Valid tags are at most 64 characters of letters, digits, and the separators "_", ":", ".", and "-",
+// and start with a letter or digit.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return tag, tagPattern.MatchString(tag)
+}
+
+// TagCount is a tag and the number of documents with it.
+type TagCount struct {
+	Tag   string
+	Count int
+}
@@ -0,0 +1,38 @@
+package model_test
+
+import (
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+		valid    bool
+	}{
+		{tag: "farm", expected: "farm", valid: true},
+		{tag: "  Farm Animals ", expected: "farm animals", valid: false},
+		{tag: " Farm-Animals ", expected: "farm-animals", valid: true},
+		{tag: "lang:go", expected: "lang:go", valid: true},
+		{tag: "v1.2_beta", expected: "v1.2_beta", valid: true},
+		{tag: "æøå", expected: "æøå", valid: true},
+		{tag: "", expected: "", valid: false},
+		{tag: "-farm", expected: "-farm", valid: false},
+		{tag: "farm/animals", expected: "farm/animals", valid: false},
+		{tag: strings.Repeat("a", 64), expected: strings.Repeat("a", 64), valid: true},
+		{tag: strings.Repeat("a", 65), expected: strings.Repeat("a", 65), valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.tag, func(t *testing.T) {
+			tag, ok := model.NormalizeTag(test.tag)
+			is.Equal(t, test.expected, tag)
+			is.Equal(t, test.valid, ok)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+// BackgroundAPIKey is the API key of token usage by background work that isn't on behalf of an API key,
+// like summarizing documents. It's not a valid [APIKey.ID], so it has no quota.
+const BackgroundAPIKey = "background"
+
+// Usage of tokens in calls to a model server during a request or a background job, for an operation and model.
+// APIKey is the [APIKey.ID] of the API key of the request or job, empty if there was none, or [BackgroundAPIKey].
+// Route is the route pattern of the request, like /search, or the name of the background job.
+// Usage isn't recorded per collection, since all documents are in [DefaultCollection] until they can be organized
+// in collections, and requests don't name one.
+type Usage struct {
+	ID               ID
+	Created          Time
+	APIKey           string `db:"apiKey"`
+	Route            string
+	Operation        string
+	Model            string
+	PromptTokens     int `db:"promptTokens"`
+	CompletionTokens int `db:"completionTokens"`
+}
+
+// UsageGroupBy is what token usage is totalled by in a usage report.
+type UsageGroupBy string
+
+const (
+	UsageGroupByAPIKey    = UsageGroupBy("apiKey")
+	UsageGroupByRoute     = UsageGroupBy("route")
+	UsageGroupByOperation = UsageGroupBy("operation")
+	UsageGroupByModel     = UsageGroupBy("model")
+)
+
+func (g UsageGroupBy) Valid() bool {
+	switch g {
+	case UsageGroupByAPIKey, UsageGroupByRoute, UsageGroupByOperation, UsageGroupByModel:
+		return true
+	default:
+		return false
+	}
+}
+
+// UsageTotal of tokens in a usage report, for a group of usage records.
+// Only the fields that the report is grouped by are set, see [UsageGroupBy].
+type UsageTotal struct {
+	APIKey           string `db:"apiKey" json:"apiKey,omitempty"`
+	Route            string `json:"route,omitempty"`
+	Operation        string `json:"operation,omitempty"`
+	Model            string `json:"model,omitempty"`
+	PromptTokens     int    `db:"promptTokens" json:"promptTokens"`
+	CompletionTokens int    `db:"completionTokens" json:"completionTokens"`
+	TotalTokens      int    `db:"totalTokens" json:"totalTokens"`
+}
+
+// UsageQuota is the maximum number of tokens the API key with the [APIKey.ID] can use in the quota period.
+type UsageQuota struct {
+	APIKey  string `db:"apiKey" json:"apiKey"`
+	Created Time   `json:"-"`
+	Updated Time   `json:"-"`
+	Tokens  int    `json:"tokens"`
+}
@@ -0,0 +1,164 @@
+// Package prompt has the registry of versioned prompt templates.
+//
+// Templates are embedded from the templates directory, in files named like reply.v2.txt for version 2 of the reply template.
+// They're [text/template] templates, with variables like {{.Query}}.
+// Changing a prompt is done by adding a new version of the template, so completions can be compared between versions.
+// The latest version is used unless another version is selected in the context with [WithVersion].
+package prompt
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"maragu.dev/errors"
+)
+
+// ErrNotFound is returned when there is no template with the name and version.
+var ErrNotFound = errors.New("prompt template not found")
+
+//go:embed templates/*.txt
+var templates embed.FS
+
+// Template is a version of a named prompt template.
+type Template struct {
+	Name    string
+	Version int
+	Text    string
+	t       *template.Template
+}
+
+// ID of the template version, like reply@v2. It's recorded with what completions with the template produce.
+func (t Template) ID() string {
+	return fmt.Sprintf("%v@v%v", t.Name, t.Version)
+}
+
+// Execute the template with the data. Missing variables are an error.
+func (t Template) Execute(data any) (string, error) {
+	var b strings.Builder
+	if err := t.t.Execute(&b, data); err != nil {
+		return "", errors.Wrap(err, "error executing prompt template %v", t.ID())
+	}
+	return b.String(), nil
+}
+
+var registry = mustLoad(templates)
+
+var fileNamePattern = regexp.MustCompile(`^([a-z0-9-]+)\.v([1-9][0-9]*)\.txt$`)
+
+// mustLoad all templates in the templates directory of fsys, sorted by name and version.
+// It panics if a template can't be loaded, because the templates are embedded.
+func mustLoad(fsys fs.FS) map[string][]Template {
+	names, err := fs.Glob(fsys, "templates/*.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	r := map[string][]Template{}
+	for _, name := range names {
+		matches := fileNamePattern.FindStringSubmatch(path.Base(name))
+		if matches == nil {
+			panic("invalid prompt template file name " + name)
+		}
+		version, _ := strconv.Atoi(matches[2])
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			panic(err)
+		}
+		text := strings.TrimSuffix(string(b), "\n")
+
+		t := Template{Name: matches[1], Version: version, Text: text}
+		t.t = template.Must(template.New(t.ID()).Option("missingkey=error").Parse(text))
+		r[t.Name] = append(r[t.Name], t)
+	}
+
+	for _, ts := range r {
+		slices.SortFunc(ts, func(a, b Template) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+	}
+
+	return r
+}
+
+// Get the template with the name and version, or the latest version if version is 0.
+func Get(name string, version int) (Template, error) {
+	ts := registry[name]
+	if len(ts) == 0 {
+		return Template{}, errors.Wrap(ErrNotFound, "%v", name)
+	}
+
+	if version == 0 {
+		return ts[len(ts)-1], nil
+	}
+
+	for _, t := range ts {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return Template{}, errors.Wrap(ErrNotFound, "%v@v%v", name, version)
+}
+
+// List all templates, sorted by name and version.
+func List() []Template {
+	var ts []Template
+	for _, name := range slices.Sorted(maps.Keys(registry)) {
+		ts = append(ts, registry[name]...)
+	}
+	return ts
+}
+
+type contextKey struct{}
+
+// WithVersion selects the version of the named template in the context, for [Render].
+func WithVersion(ctx context.Context, name string, version int) context.Context {
+	versions := map[string]int{name: version}
+	if previous, ok := ctx.Value(contextKey{}).(map[string]int); ok {
+		for k, v := range previous {
+			if k != name {
+				versions[k] = v
+			}
+		}
+	}
+	return context.WithValue(ctx, contextKey{}, versions)
+}
+
+// Render the named template with the data, in the version selected in the context with [WithVersion],
+// or the latest version. The template is returned as well, to record its ID.
+func Render(ctx context.Context, name string, data any) (string, Template, error) {
+	versions, _ := ctx.Value(contextKey{}).(map[string]int)
+
+	t, err := Get(name, versions[name])
+	if err != nil {
+		return "", t, err
+	}
+
+	s, err := t.Execute(data)
+	return s, t, err
+}
+
+// ParseID of a template version, like reply@v2, into the name and version.
+func ParseID(id string) (string, int, bool) {
+	name, version, ok := strings.Cut(id, "@v")
+	if !ok {
+		return "", 0, false
+	}
+
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 || name == "" {
+		return "", 0, false
+	}
+
+	return name, v, true
+}
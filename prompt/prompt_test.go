@@ -0,0 +1,102 @@
+package prompt_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/prompt"
+)
+
+func TestGet(t *testing.T) {
+	t.Run("gets the latest version with version 0", func(t *testing.T) {
+		latest, err := prompt.Get("reply", 0)
+		is.NotError(t, err)
+		is.Equal(t, "reply", latest.Name)
+
+		for _, tmpl := range prompt.List() {
+			if tmpl.Name == "reply" {
+				is.True(t, tmpl.Version <= latest.Version)
+			}
+		}
+	})
+
+	t.Run("gets a specific version", func(t *testing.T) {
+		tmpl, err := prompt.Get("reply", 1)
+		is.NotError(t, err)
+		is.Equal(t, "reply@v1", tmpl.ID())
+	})
+
+	t.Run("errors on unknown name or version", func(t *testing.T) {
+		_, err := prompt.Get("unknown", 0)
+		is.Error(t, prompt.ErrNotFound, err)
+
+		_, err = prompt.Get("reply", 1000)
+		is.Error(t, prompt.ErrNotFound, err)
+	})
+}
+
+func TestList(t *testing.T) {
+	t.Run("lists templates sorted by name and version", func(t *testing.T) {
+		templates := prompt.List()
+		is.True(t, len(templates) > 0)
+
+		for i := 1; i < len(templates); i++ {
+			a, b := templates[i-1], templates[i]
+			is.True(t, a.Name < b.Name || (a.Name == b.Name && a.Version < b.Version))
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	t.Run("renders the latest version with the data", func(t *testing.T) {
+		s, tmpl, err := prompt.Render(t.Context(), "hypothetical-document", map[string]any{"Query": "What is the capital of Denmark?"})
+		is.NotError(t, err)
+		is.True(t, strings.HasSuffix(s, "Question: What is the capital of Denmark?"))
+
+		latest, err := prompt.Get("hypothetical-document", 0)
+		is.NotError(t, err)
+		is.Equal(t, latest.ID(), tmpl.ID())
+	})
+
+	t.Run("renders the version selected in the context", func(t *testing.T) {
+		ctx := prompt.WithVersion(t.Context(), "hypothetical-document", 1)
+		ctx = prompt.WithVersion(ctx, "reply", 1)
+
+		_, tmpl, err := prompt.Render(ctx, "hypothetical-document", map[string]any{"Query": "What is the capital of Denmark?"})
+		is.NotError(t, err)
+		is.Equal(t, "hypothetical-document@v1", tmpl.ID())
+	})
+
+	t.Run("errors on missing variables", func(t *testing.T) {
+		_, _, err := prompt.Render(context.Background(), "hypothetical-document", map[string]any{})
+		is.True(t, err != nil)
+	})
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		id      string
+		name    string
+		version int
+		ok      bool
+	}{
+		{"reply@v2", "reply", 2, true},
+		{"condense-query@v10", "condense-query", 10, true},
+		{"reply", "", 0, false},
+		{"reply@v0", "", 0, false},
+		{"@v1", "", 0, false},
+		{"reply@vx", "", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			name, version, ok := prompt.ParseID(test.id)
+			is.Equal(t, test.name, name)
+			is.Equal(t, test.version, version)
+			is.Equal(t, test.ok, ok)
+		})
+	}
+}
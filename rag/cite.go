@@ -0,0 +1,141 @@
+package rag
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"app/model"
+)
+
+var (
+	citationRegexp = regexp.MustCompile(`\[(\d+)\]`)
+	sentenceRegexp = regexp.MustCompile(`[^.!?\n]+[.!?]*`)
+)
+
+// citationFilter drops citation markers that reference sources that don't exist, from text that's written in parts.
+// Text that could be the start of a citation marker is held back until it's known whether it is one.
+type citationFilter struct {
+	sources int
+	pending strings.Builder
+}
+
+// write the part and return the text that's ready.
+func (f *citationFilter) write(part string) string {
+	var out strings.Builder
+	for _, r := range part {
+		if f.pending.Len() == 0 {
+			if r == '[' {
+				f.pending.WriteRune(r)
+				continue
+			}
+			out.WriteRune(r)
+			continue
+		}
+
+		switch {
+		case r >= '0' && r <= '9' && f.pending.Len() < 4:
+			f.pending.WriteRune(r)
+
+		case r == ']' && f.pending.Len() > 1:
+			n, _ := strconv.Atoi(f.pending.String()[1:])
+			if n >= 1 && n <= f.sources {
+				out.WriteString(f.pending.String() + "]")
+			}
+			f.pending.Reset()
+
+		default:
+			out.WriteString(f.pending.String())
+			f.pending.Reset()
+			if r == '[' {
+				f.pending.WriteRune(r)
+				continue
+			}
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// flush the text that's held back.
+func (f *citationFilter) flush() string {
+	s := f.pending.String()
+	f.pending.Reset()
+	return s
+}
+
+// cite the sources referenced by citation markers in the answer, by number.
+// References to sources that don't exist are left out.
+// The quote of each citation is the sentence of the source that shares the most words with the claim before the first marker.
+func cite(answer string, sources []model.Chunk) model.Citations {
+	var citations model.Citations
+	seen := map[int]bool{}
+	for _, match := range citationRegexp.FindAllStringSubmatchIndex(answer, -1) {
+		n, err := strconv.Atoi(answer[match[2]:match[3]])
+		if err != nil || n < 1 || n > len(sources) || seen[n] {
+			continue
+		}
+		seen[n] = true
+
+		source := sources[n-1]
+		citations = append(citations, model.Citation{
+			Number:     n,
+			DocumentID: source.DocumentID,
+			ChunkIndex: source.Index,
+			Quote:      quote(claim(answer[:match[0]]), source.Content),
+		})
+	}
+
+	slices.SortFunc(citations, func(a, b model.Citation) int {
+		return a.Number - b.Number
+	})
+
+	return citations
+}
+
+// claim is the last sentence of the text before a citation marker.
+func claim(before string) string {
+	before = citationRegexp.ReplaceAllString(before, "")
+	before = strings.TrimRight(strings.TrimSpace(before), ".!?")
+	return strings.TrimSpace(before[strings.LastIndexAny(before, ".!?\n")+1:])
+}
+
+// quote the sentence of the content that shares the most words with the claim.
+// The first sentence is quoted if none of them share any words.
+func quote(claim, content string) string {
+	claimWords := map[string]bool{}
+	for _, w := range words(claim) {
+		claimWords[w] = true
+	}
+
+	var best string
+	bestScore := -1
+	for _, sentence := range sentenceRegexp.FindAllString(content, -1) {
+		sentence = strings.TrimSpace(sentence)
+		if sentence == "" {
+			continue
+		}
+
+		var score int
+		for _, w := range words(sentence) {
+			if claimWords[w] {
+				score++
+			}
+		}
+
+		if score > bestScore {
+			best, bestScore = sentence, score
+		}
+	}
+
+	return best
+}
+
+// words of s in lower case.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
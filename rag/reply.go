@@ -0,0 +1,300 @@
+package rag
+
+import (
+	"context"
+	"iter"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+
+	"app/model"
+	"app/prompt"
+	"app/tracing"
+)
+
+type conversationStore interface {
+	searcher
+	GetConversation(ctx context.Context, id model.ID) (model.Conversation, error)
+	ListMessages(ctx context.Context, conversationID model.ID) ([]model.Message, error)
+	AddMessages(ctx context.Context, ms []model.Message) ([]model.Message, error)
+	SaveConversationSummary(ctx context.Context, id model.ID, summary string, summarizedMessages int) error
+}
+
+type chatAI interface {
+	retrievalAI
+	ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error)
+	CondenseQuery(ctx context.Context, transcript, question string) (string, error)
+	SummarizeConversation(ctx context.Context, previousSummary, transcript string) (string, error)
+}
+
+const (
+	// defaultHistoryTokens is the default maximum number of tokens of previous messages sent to the model.
+	defaultHistoryTokens = 1024
+
+	// defaultSources is the default number of retrieved chunks sent to the model.
+	defaultSources = 5
+)
+
+const (
+	// refusal replaces ungrounded answers with [model.UngroundedPolicyRefuse].
+	refusal = "I can't answer that from the documents."
+
+	// ungroundedNote is added to ungrounded answers with [model.UngroundedPolicyFlag].
+	ungroundedNote = "\n\n_This answer doesn't cite any sources, so it may not be grounded in the documents._"
+)
+
+type ReplyOptions struct {
+	// Retrieve options for retrieving sources for the reply. Reranking is on by default, like with [Retrieve].
+	Retrieve RetrieveOptions
+
+	// HistoryTokens is the maximum number of tokens of previous messages sent to the model, estimated by words.
+	// Older messages are summarized instead. Defaults to 1024.
+	HistoryTokens int
+
+	// Sources is the maximum number of retrieved chunks sent to the model. Defaults to 5.
+	Sources int
+
+	// Ungrounded policy for answers that don't cite any sources. Defaults to [model.UngroundedPolicyAllow].
+	// With [model.UngroundedPolicyRefuse], the answer is only sent when it's complete and known to cite its sources.
+	Ungrounded model.UngroundedPolicy
+}
+
+// Answer from [Reply], with the sources given to the model, numbered from 1 in the order of the slice.
+// Citations and Ungrounded are set once the text from [Answer.Text] has been consumed.
+type Answer struct {
+	Sources []model.Chunk
+
+	// Citations of the sources in the answer. Citations of sources that don't exist are dropped from the answer text.
+	Citations model.Citations
+
+	// Ungrounded if the answer doesn't cite any sources.
+	Ungrounded bool
+
+	// Prompt is the ID of the prompt template that produced the answer.
+	Prompt string
+
+	text iter.Seq2[string, error]
+}
+
+// Text of the answer, streamed in parts.
+// The answer and the user message are saved to the conversation when the text has been consumed without errors.
+// The text must be ranged over, or the context given to [Reply] cancelled, to release the chat completion,
+// see [ai.Client.ChatComplete].
+func (a *Answer) Text() iter.Seq2[string, error] {
+	return a.text
+}
+
+// Reply to a user message in a conversation, with chunks retrieved for the message as sources.
+// Follow-up messages are condensed into a standalone query with the conversation history before retrieval.
+// Previous messages are sent to the model as long as they fit in [ReplyOptions.HistoryTokens],
+// and older messages are summarized.
+// The model is instructed to cite the sources by number, and the citations are validated, see [Answer].
+//
+// The answer is streamed with [Answer.Text], and the user message is saved together with the answer
+// once the answer has been consumed without errors, so a failed answer saves neither.
+// The caller must consume the answer text or cancel the context, see [Answer.Text].
+func Reply(ctx context.Context, db conversationStore, ai chatAI, conversationID model.ID, content string, opts ReplyOptions) (_ *Answer, err error) {
+	if opts.HistoryTokens == 0 {
+		opts.HistoryTokens = defaultHistoryTokens
+	}
+
+	if opts.Sources == 0 {
+		opts.Sources = defaultSources
+	}
+
+	if opts.Ungrounded == "" {
+		opts.Ungrounded = model.UngroundedPolicyAllow
+	}
+
+	ctx, span := tracer.Start(ctx, "rag.Reply", trace.WithAttributes(attribute.String("conversation.id", string(conversationID))))
+	defer func() { tracing.End(span, err) }()
+
+	conversation, err := db.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := db.ListMessages(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, summary, err := fitHistory(ctx, db, ai, conversation, messages, opts.HistoryTokens)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("messages", len(messages)), attribute.Int("history", len(history)))
+
+	query := content
+	if len(messages) > 0 {
+		query, err = ai.CondenseQuery(ctx, transcript(summary, history), content)
+		if err != nil {
+			return nil, errors.Wrap(err, "error condensing query")
+		}
+	}
+
+	chunks, err := Retrieve(ctx, db, ai, query, opts.Retrieve)
+	if err != nil {
+		return nil, errors.Wrap(err, "error retrieving sources")
+	}
+	chunks = chunks[:min(len(chunks), opts.Sources)]
+	span.SetAttributes(attribute.Int("sources", len(chunks)))
+
+	req := gai.ChatCompleteRequest{Temperature: gai.Ptr(gai.Temperature(0.2))}
+	for _, m := range history {
+		role := gai.MessageRoleUser
+		if m.Role == model.MessageRoleAssistant {
+			role = gai.MessageRoleModel
+		}
+		// Citation markers are removed, because the sources are numbered anew for every answer
+		content := citationRegexp.ReplaceAllString(m.Content, "")
+		req.Messages = append(req.Messages, gai.Message{Role: role, Parts: []gai.MessagePart{gai.TextMessagePart(content)}})
+	}
+	message, t, err := replyMessage(ctx, summary, chunks, content)
+	if err != nil {
+		return nil, err
+	}
+	req.Messages = append(req.Messages, gai.NewUserTextMessage(message))
+	span.SetAttributes(attribute.String("prompt", t.ID()))
+
+	res, err := ai.ChatComplete(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error chat completing")
+	}
+
+	answer := &Answer{Sources: chunks, Prompt: t.ID()}
+	answer.text = func(yield func(string, error) bool) {
+		filter := &citationFilter{sources: len(chunks)}
+		var text strings.Builder
+		for part, err := range res.Parts() {
+			if err != nil {
+				yield("", errors.Wrap(err, "error reading chat completion"))
+				return
+			}
+
+			if part.Type != gai.MessagePartTypeText {
+				continue
+			}
+
+			filtered := filter.write(part.Text())
+			text.WriteString(filtered)
+			if opts.Ungrounded == model.UngroundedPolicyRefuse || filtered == "" {
+				continue
+			}
+			if !yield(filtered, nil) {
+				return
+			}
+		}
+
+		rest := filter.flush()
+		text.WriteString(rest)
+
+		answer.Citations = cite(text.String(), chunks)
+		answer.Ungrounded = len(answer.Citations) == 0
+		span.SetAttributes(attribute.Int("citations", len(answer.Citations)))
+
+		switch {
+		case opts.Ungrounded == model.UngroundedPolicyRefuse && answer.Ungrounded:
+			text.Reset()
+			text.WriteString(refusal)
+			rest = refusal
+		case opts.Ungrounded == model.UngroundedPolicyRefuse:
+			rest = text.String()
+		case opts.Ungrounded == model.UngroundedPolicyFlag && answer.Ungrounded:
+			text.WriteString(ungroundedNote)
+			rest += ungroundedNote
+		}
+
+		if rest != "" && !yield(rest, nil) {
+			return
+		}
+
+		// The user message is saved with the reply, so a failed reply doesn't leave the user message without one
+		ms := []model.Message{
+			{ConversationID: conversationID, Role: model.MessageRoleUser, Content: content},
+			{
+				ConversationID: conversationID,
+				Role:           model.MessageRoleAssistant,
+				Content:        strings.TrimSpace(text.String()),
+				Citations:      answer.Citations,
+				Prompt:         answer.Prompt,
+			},
+		}
+		if _, err := db.AddMessages(ctx, ms); err != nil {
+			yield("", errors.Wrap(err, "error saving messages"))
+		}
+	}
+
+	return answer, nil
+}
+
+// fitHistory returns the most recent messages that fit in the token budget, and a summary of the older messages.
+// The summary is updated and saved if more messages have fallen out of the budget since it was made.
+func fitHistory(ctx context.Context, db conversationStore, ai chatAI, c model.Conversation, messages []model.Message, maxTokens int) ([]model.Message, string, error) {
+	start := len(messages)
+	var tokens int
+	for start > 0 {
+		tokens += countTokens(messages[start-1].Content)
+		if tokens > maxTokens {
+			break
+		}
+		start--
+	}
+
+	// Messages can't be unsummarized, so messages already in the summary are left out even if they would fit
+	start = max(start, min(c.SummarizedMessages, len(messages)))
+
+	if start <= c.SummarizedMessages {
+		return messages[start:], c.Summary, nil
+	}
+
+	summary, err := ai.SummarizeConversation(ctx, c.Summary, transcript("", messages[c.SummarizedMessages:start]))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error summarizing conversation")
+	}
+
+	if err := db.SaveConversationSummary(ctx, c.ID, summary, start); err != nil {
+		return nil, "", errors.Wrap(err, "error saving conversation summary")
+	}
+
+	return messages[start:], summary, nil
+}
+
+// transcript of the messages as text, after the summary of earlier messages if there is one.
+func transcript(summary string, messages []model.Message) string {
+	var b strings.Builder
+	if summary != "" {
+		b.WriteString("Summary of earlier messages: " + summary + "\n\n")
+	}
+	for _, m := range messages {
+		role := "User"
+		if m.Role == model.MessageRoleAssistant {
+			role = "Assistant"
+		}
+		b.WriteString(role + ": " + citationRegexp.ReplaceAllString(m.Content, "") + "\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// replyMessage from the reply prompt template, with the summary of earlier messages, the numbered sources, and the question.
+func replyMessage(ctx context.Context, summary string, chunks []model.Chunk, question string) (string, prompt.Template, error) {
+	type source struct {
+		Number  int
+		Content string
+	}
+
+	var sources []source
+	for i, c := range chunks {
+		sources = append(sources, source{Number: i + 1, Content: strings.TrimSpace(c.Content)})
+	}
+
+	return prompt.Render(ctx, "reply", map[string]any{"Summary": summary, "Sources": sources, "Question": question})
+}
+
+// countTokens in s, estimated by words like [gai.NaiveWordTokenizer].
+func countTokens(s string) int {
+	return len(strings.Fields(s))
+}
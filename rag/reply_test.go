@@ -0,0 +1,243 @@
+package rag_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	"app/model"
+	"app/rag"
+)
+
+type conversationStoreMock struct {
+	searcherMock
+	conversation model.Conversation
+	messages     []model.Message
+}
+
+func (c *conversationStoreMock) GetConversation(ctx context.Context, id model.ID) (model.Conversation, error) {
+	if id != c.conversation.ID {
+		return model.Conversation{}, model.ErrorConversationNotFound
+	}
+	return c.conversation, nil
+}
+
+func (c *conversationStoreMock) ListMessages(ctx context.Context, conversationID model.ID) ([]model.Message, error) {
+	return c.messages, nil
+}
+
+func (c *conversationStoreMock) AddMessages(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	c.messages = append(c.messages, ms...)
+	return ms, nil
+}
+
+func (c *conversationStoreMock) SaveConversationSummary(ctx context.Context, id model.ID, summary string, summarizedMessages int) error {
+	c.conversation.Summary = summary
+	c.conversation.SummarizedMessages = summarizedMessages
+	return nil
+}
+
+type chatAIMock struct {
+	aiMock
+	requests   []gai.ChatCompleteRequest
+	condensed  []string
+	summarized []string
+	replyParts []string
+	replyErr   error
+}
+
+func (c *chatAIMock) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	c.requests = append(c.requests, req)
+	return gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		for _, part := range c.replyParts {
+			if !yield(gai.TextMessagePart(part), nil) {
+				return
+			}
+		}
+		if c.replyErr != nil {
+			yield(gai.MessagePart{}, c.replyErr)
+		}
+	}), nil
+}
+
+func (c *chatAIMock) CondenseQuery(ctx context.Context, transcript, question string) (string, error) {
+	c.condensed = append(c.condensed, transcript)
+	return "condensed", nil
+}
+
+func (c *chatAIMock) SummarizeConversation(ctx context.Context, previousSummary, transcript string) (string, error) {
+	c.summarized = append(c.summarized, transcript)
+	return "summary", nil
+}
+
+func TestReply(t *testing.T) {
+	t.Run("streams and saves the reply with retrieved sources", func(t *testing.T) {
+		db := &conversationStoreMock{
+			searcherMock: searcherMock{chunks: map[string][]model.Chunk{"What do sheep eat?": {{ID: "a", Content: "Sheep eat grass."}}}},
+			conversation: model.Conversation{ID: "cv_1"},
+		}
+		ai := &chatAIMock{replyParts: []string{"Sheep ", "eat grass."}}
+
+		answer, err := rag.Reply(t.Context(), db, ai, "cv_1", "What do sheep eat?", rag.ReplyOptions{})
+		is.NotError(t, err)
+
+		var parts []string
+		for part, err := range answer.Text() {
+			is.NotError(t, err)
+			parts = append(parts, part)
+		}
+		is.EqualSlice(t, []string{"Sheep ", "eat grass."}, parts)
+
+		is.Equal(t, 0, len(ai.condensed))
+		is.Equal(t, 1, len(ai.requests))
+		prompt := ai.requests[0].Messages[0].Parts[0].Text()
+		is.True(t, strings.Contains(prompt, "[1] Sheep eat grass."))
+		is.True(t, strings.HasSuffix(prompt, "Question: What do sheep eat?"))
+
+		is.Equal(t, 2, len(db.messages))
+		is.Equal(t, model.MessageRoleUser, db.messages[0].Role)
+		is.Equal(t, "What do sheep eat?", db.messages[0].Content)
+		is.Equal(t, model.MessageRoleAssistant, db.messages[1].Role)
+		is.Equal(t, "Sheep eat grass.", db.messages[1].Content)
+	})
+
+	t.Run("condenses follow-up questions with the history", func(t *testing.T) {
+		db := &conversationStoreMock{
+			searcherMock: searcherMock{chunks: map[string][]model.Chunk{"condensed": {{ID: "a", Content: "Sheep eat grass."}}}},
+			conversation: model.Conversation{ID: "cv_1"},
+			messages: []model.Message{
+				{Role: model.MessageRoleUser, Content: "What do sheep eat?"},
+				{Role: model.MessageRoleAssistant, Content: "Grass."},
+			},
+		}
+		ai := &chatAIMock{replyParts: []string{"Yes."}}
+
+		answer, err := rag.Reply(t.Context(), db, ai, "cv_1", "And goats?", rag.ReplyOptions{})
+		is.NotError(t, err)
+		for _, err := range answer.Text() {
+			is.NotError(t, err)
+		}
+
+		is.EqualSlice(t, []string{"User: What do sheep eat?\nAssistant: Grass."}, ai.condensed)
+		is.Equal(t, 1, len(db.embeddings))
+		is.Equal(t, "condensed", db.embeddings[0])
+
+		messages := ai.requests[0].Messages
+		is.Equal(t, 3, len(messages))
+		is.Equal(t, gai.MessageRoleUser, messages[0].Role)
+		is.Equal(t, gai.MessageRoleModel, messages[1].Role)
+		is.True(t, strings.HasSuffix(messages[2].Parts[0].Text(), "Question: And goats?"))
+	})
+
+	t.Run("summarizes messages that don't fit in the history", func(t *testing.T) {
+		db := &conversationStoreMock{
+			conversation: model.Conversation{ID: "cv_1"},
+			messages: []model.Message{
+				{Role: model.MessageRoleUser, Content: "one two three"},
+				{Role: model.MessageRoleAssistant, Content: "four five"},
+				{Role: model.MessageRoleUser, Content: "six"},
+				{Role: model.MessageRoleAssistant, Content: "seven"},
+			},
+		}
+		ai := &chatAIMock{}
+
+		_, err := rag.Reply(t.Context(), db, ai, "cv_1", "eight", rag.ReplyOptions{HistoryTokens: 4})
+		is.NotError(t, err)
+
+		is.EqualSlice(t, []string{"User: one two three"}, ai.summarized)
+		is.Equal(t, "summary", db.conversation.Summary)
+		is.Equal(t, 1, db.conversation.SummarizedMessages)
+		is.EqualSlice(t, []string{"Summary of earlier messages: summary\n\nAssistant: four five\nUser: six\nAssistant: seven"}, ai.condensed)
+		is.Equal(t, 4, len(ai.requests[0].Messages))
+	})
+
+	t.Run("cites sources and drops citations of sources that don't exist", func(t *testing.T) {
+		db := &conversationStoreMock{
+			searcherMock: searcherMock{chunks: map[string][]model.Chunk{"What do sheep eat?": {
+				{DocumentID: "d_1", Index: 2, Content: "Sheep are fluffy. Sheep eat grass and hay."},
+				{DocumentID: "d_2", Index: 0, Content: "Goats eat anything."},
+			}}},
+			conversation: model.Conversation{ID: "cv_1"},
+		}
+		ai := &chatAIMock{replyParts: []string{"Sheep eat grass [", "1][", "3]. Goats eat anything [2][1]."}}
+
+		answer, err := rag.Reply(t.Context(), db, ai, "cv_1", "What do sheep eat?", rag.ReplyOptions{})
+		is.NotError(t, err)
+
+		var text string
+		for part, err := range answer.Text() {
+			is.NotError(t, err)
+			text += part
+		}
+		is.Equal(t, "Sheep eat grass [1]. Goats eat anything [2][1].", text)
+
+		is.True(t, !answer.Ungrounded)
+		is.Equal(t, 2, len(answer.Citations))
+		is.Equal(t, model.Citation{Number: 1, DocumentID: "d_1", ChunkIndex: 2, Quote: "Sheep eat grass and hay."}, answer.Citations[0])
+		is.Equal(t, model.Citation{Number: 2, DocumentID: "d_2", ChunkIndex: 0, Quote: "Goats eat anything."}, answer.Citations[1])
+
+		is.Equal(t, text, db.messages[1].Content)
+		is.Equal(t, 2, len(db.messages[1].Citations))
+	})
+
+	t.Run("flags answers without citations", func(t *testing.T) {
+		db := &conversationStoreMock{conversation: model.Conversation{ID: "cv_1"}}
+		ai := &chatAIMock{replyParts: []string{"Sheep eat grass."}}
+
+		answer, err := rag.Reply(t.Context(), db, ai, "cv_1", "What do sheep eat?", rag.ReplyOptions{Ungrounded: model.UngroundedPolicyFlag})
+		is.NotError(t, err)
+
+		var text string
+		for part, err := range answer.Text() {
+			is.NotError(t, err)
+			text += part
+		}
+		is.True(t, answer.Ungrounded)
+		is.True(t, strings.HasPrefix(text, "Sheep eat grass.\n\n_This answer doesn't cite any sources"))
+	})
+
+	t.Run("refuses answers without citations", func(t *testing.T) {
+		db := &conversationStoreMock{conversation: model.Conversation{ID: "cv_1"}}
+		ai := &chatAIMock{replyParts: []string{"Sheep eat ", "grass [1]."}}
+
+		answer, err := rag.Reply(t.Context(), db, ai, "cv_1", "What do sheep eat?", rag.ReplyOptions{Ungrounded: model.UngroundedPolicyRefuse})
+		is.NotError(t, err)
+
+		var parts []string
+		for part, err := range answer.Text() {
+			is.NotError(t, err)
+			parts = append(parts, part)
+		}
+		is.True(t, answer.Ungrounded)
+		is.EqualSlice(t, []string{"I can't answer that from the documents."}, parts)
+		is.Equal(t, "I can't answer that from the documents.", db.messages[1].Content)
+	})
+
+	t.Run("saves neither message if the reply fails", func(t *testing.T) {
+		db := &conversationStoreMock{conversation: model.Conversation{ID: "cv_1"}}
+		ai := &chatAIMock{replyParts: []string{"Sheep "}, replyErr: errors.New("oh no")}
+
+		answer, err := rag.Reply(t.Context(), db, ai, "cv_1", "What do sheep eat?", rag.ReplyOptions{})
+		is.NotError(t, err)
+
+		var textErr error
+		for _, err := range answer.Text() {
+			if err != nil {
+				textErr = err
+			}
+		}
+		is.True(t, textErr != nil)
+		is.Equal(t, 0, len(db.messages))
+	})
+
+	t.Run("errors if the conversation does not exist", func(t *testing.T) {
+		db := &conversationStoreMock{conversation: model.Conversation{ID: "cv_1"}}
+
+		_, err := rag.Reply(t.Context(), db, &chatAIMock{}, "cv_2", "Hi", rag.ReplyOptions{})
+		is.Error(t, model.ErrorConversationNotFound, err)
+	})
+}
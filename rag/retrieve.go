@@ -0,0 +1,195 @@
+// Package rag has retrieval for retrieval-augmented generation, which is shared with search.
+package rag
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+
+	"app/model"
+	"app/sql"
+	"app/tracing"
+)
+
+var tracer = otel.Tracer("app/rag")
+
+type searcher interface {
+	Search(ctx context.Context, query string, embedding []byte, opts sql.SearchOptions) ([]model.Chunk, error)
+}
+
+type retrievalAI interface {
+	EmbedString(ctx context.Context, s string) ([]byte, error)
+	ExpandQuery(ctx context.Context, query string, n int) ([]string, error)
+	HypotheticalDocument(ctx context.Context, query string) (string, error)
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+	RewriteQuery(ctx context.Context, query string, n int) ([]string, error)
+}
+
+const (
+	// defaultRerankTop is the default number of top search results that are reranked.
+	defaultRerankTop = 20
+
+	// maxRewrites is the maximum number of queries a query is rewritten into.
+	maxRewrites = 3
+
+	// maxExpansions is the maximum number of synonyms and acronym expansions of a query.
+	maxExpansions = 5
+
+	// rrfK is the constant in reciprocal rank fusion, which dampens the effect of the top ranks.
+	// See https://plg.uwaterloo.ca/~gvcormac/cormacksigir09-rrf.pdf
+	rrfK = 60
+)
+
+type RetrieveOptions struct {
+	// Search options, like filters.
+	Search sql.SearchOptions
+
+	// NoRerank skips reranking the top search results, which is done by default.
+	NoRerank bool
+
+	// RerankTop is the number of top search results that are reranked. Defaults to 20.
+	// The rest of the results are kept after the reranked ones, in search order.
+	RerankTop int
+
+	// Rewrite the query into several queries with [ai.Client.RewriteQuery], and search with each of them as well.
+	Rewrite bool
+
+	// Expand the query with synonyms and acronym expansions for full-text search, with [ai.Client.ExpandQuery].
+	Expand bool
+
+	// HyDE embeds a hypothetical document that answers each query instead of the query itself,
+	// see [ai.Client.HypotheticalDocument].
+	HyDE bool
+}
+
+// Retrieve chunks relevant to the query, with [sql.Database.Search] and then [ai.Client.Rerank] of the top results.
+// With query rewriting, the results of each query are fused with reciprocal rank fusion before reranking.
+func Retrieve(ctx context.Context, db searcher, ai retrievalAI, query string, opts RetrieveOptions) (_ []model.Chunk, err error) {
+	if opts.RerankTop < 0 {
+		panic("rerank top cannot be negative")
+	}
+
+	if opts.RerankTop == 0 {
+		opts.RerankTop = defaultRerankTop
+	}
+
+	ctx, span := tracer.Start(ctx, "rag.Retrieve", trace.WithAttributes(
+		attribute.Int("query.length", len(query)),
+		attribute.Bool("rerank", !opts.NoRerank),
+		attribute.Int("rerank.top", opts.RerankTop),
+		attribute.Bool("rewrite", opts.Rewrite),
+		attribute.Bool("expand", opts.Expand),
+		attribute.Bool("hyde", opts.HyDE),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	queries := []string{query}
+	if opts.Rewrite {
+		rewritten, err := ai.RewriteQuery(ctx, query, maxRewrites)
+		if err != nil {
+			return nil, errors.Wrap(err, "error rewriting query")
+		}
+		queries = append(queries, rewritten...)
+	}
+
+	searchOpts := opts.Search
+	if opts.Expand {
+		expansions, err := ai.ExpandQuery(ctx, query, maxExpansions)
+		if err != nil {
+			return nil, errors.Wrap(err, "error expanding query")
+		}
+		searchOpts.Expansions = append(slices.Clone(searchOpts.Expansions), expansions...)
+	}
+
+	results := make([][]model.Chunk, 0, len(queries))
+	for _, q := range queries {
+		text := q
+		if opts.HyDE {
+			text, err = ai.HypotheticalDocument(ctx, q)
+			if err != nil {
+				return nil, errors.Wrap(err, "error generating hypothetical document")
+			}
+		}
+
+		embedding, err := ai.EmbedString(ctx, text)
+		if err != nil {
+			return nil, errors.Wrap(err, "error embedding query")
+		}
+
+		chunks, err := db.Search(ctx, q, embedding, searchOpts)
+		if err != nil {
+			return nil, errors.Wrap(err, "error searching")
+		}
+		results = append(results, chunks)
+	}
+
+	chunks := results[0]
+	if len(results) > 1 {
+		chunks = fuse(results)
+	}
+	span.SetAttributes(attribute.Int("queries", len(queries)), attribute.Int("chunks", len(chunks)))
+
+	if opts.NoRerank {
+		return chunks, nil
+	}
+
+	return rerank(ctx, ai, query, chunks, opts.RerankTop)
+}
+
+// rerank the top chunks by relevance to the query, keeping the rest after them.
+// Chunks with the same score keep their search order.
+func rerank(ctx context.Context, ai retrievalAI, query string, chunks []model.Chunk, top int) ([]model.Chunk, error) {
+	top = min(top, len(chunks))
+	if top <= 1 {
+		return chunks, nil
+	}
+
+	documents := make([]string, top)
+	for i, c := range chunks[:top] {
+		documents[i] = c.Content
+	}
+
+	scores, err := ai.Rerank(ctx, query, documents)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reranking")
+	}
+
+	order := make([]int, top)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(scores[b], scores[a])
+	})
+
+	reranked := make([]model.Chunk, 0, len(chunks))
+	for _, i := range order {
+		reranked = append(reranked, chunks[i])
+	}
+	return append(reranked, chunks[top:]...), nil
+}
+
+// fuse the results of several searches with reciprocal rank fusion, where each chunk scores 1/(k+rank) in each result
+// it's in. Chunks with the same score are kept in the order they're first seen.
+func fuse(results [][]model.Chunk) []model.Chunk {
+	scores := map[model.ID]float64{}
+	var chunks []model.Chunk
+	for _, result := range results {
+		for rank, c := range result {
+			if _, ok := scores[c.ID]; !ok {
+				chunks = append(chunks, c)
+			}
+			scores[c.ID] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	slices.SortStableFunc(chunks, func(a, b model.Chunk) int {
+		return cmp.Compare(scores[b.ID], scores[a.ID])
+	})
+	return chunks
+}
@@ -0,0 +1,129 @@
+package rag_test
+
+import (
+	"context"
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/rag"
+	"app/sql"
+)
+
+type searcherMock struct {
+	chunks     map[string][]model.Chunk
+	embeddings []string
+	opts       []sql.SearchOptions
+}
+
+func (s *searcherMock) Search(ctx context.Context, query string, embedding []byte, opts sql.SearchOptions) ([]model.Chunk, error) {
+	s.embeddings = append(s.embeddings, string(embedding))
+	s.opts = append(s.opts, opts)
+	return s.chunks[query], nil
+}
+
+type aiMock struct {
+	documents [][]string
+	scores    map[string]float64
+}
+
+func (a *aiMock) EmbedString(ctx context.Context, s string) ([]byte, error) {
+	return []byte(s), nil
+}
+
+func (a *aiMock) ExpandQuery(ctx context.Context, query string, n int) ([]string, error) {
+	return []string{"synonym"}, nil
+}
+
+func (a *aiMock) HypotheticalDocument(ctx context.Context, query string) (string, error) {
+	return "answer to " + query, nil
+}
+
+func (a *aiMock) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	a.documents = append(a.documents, documents)
+	scores := make([]float64, len(documents))
+	for i, d := range documents {
+		scores[i] = a.scores[d]
+	}
+	return scores, nil
+}
+
+func (a *aiMock) RewriteQuery(ctx context.Context, query string, n int) ([]string, error) {
+	return []string{"rewritten 1", "rewritten 2"}, nil
+}
+
+func TestRetrieve(t *testing.T) {
+	chunks := []model.Chunk{{ID: "a", Content: "a"}, {ID: "b", Content: "b"}, {ID: "c", Content: "c"}, {ID: "d", Content: "d"}}
+
+	t.Run("reranks the top results and keeps the rest after them", func(t *testing.T) {
+		db := &searcherMock{chunks: map[string][]model.Chunk{"query": chunks}}
+		ai := &aiMock{scores: map[string]float64{"a": 1, "b": 5, "c": 3, "d": 10}}
+
+		result, err := rag.Retrieve(t.Context(), db, ai, "query", rag.RetrieveOptions{RerankTop: 3})
+		is.NotError(t, err)
+		is.Equal(t, "b c a d", contents(result))
+		is.Equal(t, 1, len(ai.documents))
+		is.EqualSlice(t, []string{"a", "b", "c"}, ai.documents[0])
+	})
+
+	t.Run("keeps the search order for equal scores", func(t *testing.T) {
+		db := &searcherMock{chunks: map[string][]model.Chunk{"query": chunks}}
+		ai := &aiMock{scores: map[string]float64{"c": 1}}
+
+		result, err := rag.Retrieve(t.Context(), db, ai, "query", rag.RetrieveOptions{})
+		is.NotError(t, err)
+		is.Equal(t, "c a b d", contents(result))
+	})
+
+	t.Run("does not rerank if turned off", func(t *testing.T) {
+		db := &searcherMock{chunks: map[string][]model.Chunk{"query": chunks}}
+		ai := &aiMock{}
+
+		result, err := rag.Retrieve(t.Context(), db, ai, "query", rag.RetrieveOptions{NoRerank: true})
+		is.NotError(t, err)
+		is.Equal(t, "a b c d", contents(result))
+		is.Equal(t, 0, len(ai.documents))
+	})
+
+	t.Run("fuses the results of rewritten queries", func(t *testing.T) {
+		db := &searcherMock{chunks: map[string][]model.Chunk{
+			"query":       {chunks[0], chunks[1]},
+			"rewritten 1": {chunks[2], chunks[1]},
+			"rewritten 2": {chunks[1], chunks[3]},
+		}}
+		ai := &aiMock{}
+
+		result, err := rag.Retrieve(t.Context(), db, ai, "query", rag.RetrieveOptions{NoRerank: true, Rewrite: true})
+		is.NotError(t, err)
+		is.Equal(t, "b a c d", contents(result))
+		is.Equal(t, 3, len(db.opts))
+	})
+
+	t.Run("expands the query and embeds hypothetical documents", func(t *testing.T) {
+		db := &searcherMock{chunks: map[string][]model.Chunk{"query": chunks}}
+		ai := &aiMock{}
+
+		_, err := rag.Retrieve(t.Context(), db, ai, "query", rag.RetrieveOptions{
+			Search:   sql.SearchOptions{Tags: []string{"farm"}},
+			NoRerank: true,
+			Expand:   true,
+			HyDE:     true,
+		})
+		is.NotError(t, err)
+		is.EqualSlice(t, []string{"answer to query"}, db.embeddings)
+		is.EqualSlice(t, []string{"synonym"}, db.opts[0].Expansions)
+		is.EqualSlice(t, []string{"farm"}, db.opts[0].Tags)
+	})
+}
+
+func contents(chunks []model.Chunk) string {
+	var s string
+	for i, c := range chunks {
+		if i > 0 {
+			s += " "
+		}
+		s += c.Content
+	}
+	return s
+}
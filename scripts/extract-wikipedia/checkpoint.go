@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Checkpoint records how far an extraction has come, so an interrupted run can resume.
+// All pages up to and including PageID have been written when the checkpoint is saved.
+type Checkpoint struct {
+	// Input is the dump file the checkpoint is for.
+	Input string `json:"input"`
+
+	// PageID is the ID of the last page read. Pages in dumps are ordered by ID.
+	PageID int `json:"pageId"`
+
+	// Offset to resume reading from. For uncompressed dumps, it's the byte offset in the XML file.
+	// For multistream dumps with an index, it's the byte offset of the next bzip2 stream in the compressed file.
+	// For other compressed dumps, it's zero, and pages up to PageID are skipped instead.
+	Offset int64 `json:"offset"`
+
+	// Articles is the number of articles extracted so far.
+	Articles int `json:"articles"`
+
+	// Done is true when the whole dump has been read.
+	Done bool `json:"done"`
+}
+
+// loadCheckpoint from path. If the file doesn't exist, an empty checkpoint for the input is returned.
+func loadCheckpoint(path, input string) (Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{Input: input}, nil
+	}
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+
+	if cp.Input != input {
+		return Checkpoint{}, fmt.Errorf("checkpoint %s is for input %s, use -restart to start over", path, cp.Input)
+	}
+
+	return cp, nil
+}
+
+// saveCheckpoint to path atomically, by writing to a temporary file first and renaming it.
+func saveCheckpoint(path string, cp Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readPages from an XML dump or a part of one, calling yield with each page and the byte offset just after it.
+// A dump read from a checkpoint offset starts inside the <mediawiki> root element, so it ends with a
+// </mediawiki> that was never opened. If partial is true, like for a single stream of a multistream dump,
+// the input may also end before the <mediawiki> root element is closed.
+// Other syntax errors are returned, so truncated or corrupt dumps aren't mistaken for complete ones.
+func readPages(r io.Reader, partial bool, yield func(p Page, end int64) bool) error {
+	decoder := xml.NewDecoder(r)
+
+	// open elements outside of pages, which are decoded as a whole
+	var open []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				switch {
+				case len(open) == 0 && syntaxErr.Msg == "unexpected end element </mediawiki>":
+					return nil
+				case partial && len(open) == 1 && open[0] == "mediawiki" && syntaxErr.Msg == "unexpected EOF":
+					return nil
+				}
+			}
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "page" {
+				open = append(open, t.Name.Local)
+				continue
+			}
+
+			var p Page
+			if err := decoder.DecodeElement(&p, &t); err != nil {
+				return fmt.Errorf("failed to decode page: %w", err)
+			}
+
+			if !yield(p, decoder.InputOffset()) {
+				return nil
+			}
+
+		case xml.EndElement:
+			open = open[:len(open)-1]
+		}
+	}
+}
+
+// stream is a bzip2 stream in a multistream dump, holding up to 100 pages.
+type stream struct {
+	Offset int64
+	Length int64
+	Wanted bool // whether any page in the stream matches the filter
+}
+
+// readIndex of a multistream dump, with lines like "offset:pageID:title". The index may be bzip2 compressed.
+// The dump size is needed to know the length of the last stream.
+func readIndex(path string, dumpSize int64, filter Filter) ([]stream, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = bufio.NewReader(file)
+	if strings.HasSuffix(path, ".bz2") {
+		r = bzip2.NewReader(r)
+	}
+
+	var streams []stream
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid index line %q", scanner.Text())
+		}
+
+		offset, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset in index line %q: %w", scanner.Text(), err)
+		}
+		id, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid page ID in index line %q: %w", scanner.Text(), err)
+		}
+
+		if len(streams) == 0 || streams[len(streams)-1].Offset != offset {
+			streams = append(streams, stream{Offset: offset})
+		}
+		if filter.MatchIndexEntry(id, parts[2]) {
+			streams[len(streams)-1].Wanted = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range streams {
+		next := dumpSize
+		if i+1 < len(streams) {
+			next = streams[i+1].Offset
+		}
+		streams[i].Length = next - streams[i].Offset
+	}
+
+	return streams, nil
+}
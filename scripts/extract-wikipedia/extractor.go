@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// checkpointInterval is the number of pages read between checkpoints when reading a dump sequentially.
+const checkpointInterval = 10000
+
+// streamBatchSize is the number of multistream dump streams read per parallel stream, between checkpoints.
+const streamBatchSize = 10
+
+// extractor reads pages from a dump and sends the ones matching the filter to the workers,
+// saving checkpoints along the way.
+type extractor struct {
+	checkpoint     Checkpoint
+	checkpointPath string
+	filter         Filter
+	jobs           chan<- Job
+	limit          int
+	pending        sync.WaitGroup // jobs sent but not written yet
+
+	lock         sync.Mutex
+	pageCount    int
+	articleCount int // including articles from previous runs
+}
+
+// process a page by sending it to the workers if it matches the filter.
+// Returns false if the article limit has been reached.
+func (e *extractor) process(p Page) bool {
+	e.lock.Lock()
+	e.pageCount++
+	if !e.filter.Match(p) {
+		e.lock.Unlock()
+		return true
+	}
+	if e.limit > 0 && e.articleCount >= e.limit {
+		e.lock.Unlock()
+		return false
+	}
+	e.articleCount++
+	articleCount, pageCount := e.articleCount, e.pageCount
+	e.lock.Unlock()
+
+	e.pending.Add(1)
+	e.jobs <- Job{
+		Title: p.Title,
+		Text:  p.Revision.Text.Content,
+		ID:    p.ID,
+	}
+
+	// Print progress every 10,000 articles
+	if articleCount%10000 == 0 {
+		fmt.Printf("Processed %d articles (pages read this run: %d)\n", articleCount, pageCount)
+	}
+
+	if e.limit > 0 && articleCount >= e.limit {
+		fmt.Printf("Reached limit of %d articles\n", e.limit)
+		return false
+	}
+	return true
+}
+
+// save a checkpoint after all pending jobs have been written.
+func (e *extractor) save(pageID int, offset int64, done bool) {
+	e.pending.Wait()
+
+	e.lock.Lock()
+	e.checkpoint.Articles = e.articleCount
+	e.lock.Unlock()
+
+	e.checkpoint.PageID = pageID
+	e.checkpoint.Offset = offset
+	e.checkpoint.Done = done
+
+	if err := saveCheckpoint(e.checkpointPath, e.checkpoint); err != nil {
+		fmt.Printf("Error saving checkpoint: %v\n", err)
+	}
+}
+
+// extractSequentially reads an uncompressed or bzip2 compressed dump from start to end.
+// Returns whether the whole dump was read.
+func (e *extractor) extractSequentially(ctx context.Context, path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	// Uncompressed dumps resume from the checkpoint offset, compressed ones skip pages up to the checkpoint page ID
+	compressed := strings.HasSuffix(path, ".bz2")
+	var offset int64
+	var r io.Reader
+	if compressed {
+		r = bzip2.NewReader(bufio.NewReaderSize(file, 1024*1024))
+	} else {
+		offset = e.checkpoint.Offset
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return false, err
+		}
+		r = bufio.NewReaderSize(file, 1024*1024)
+	}
+
+	startID := e.checkpoint.PageID
+	lastID, lastOffset := startID, offset
+	completed := true
+	var sinceCheckpoint int
+
+	err = readPages(r, false, func(p Page, end int64) bool {
+		if p.ID <= startID {
+			return true
+		}
+		if ctx.Err() != nil {
+			completed = false
+			return false
+		}
+
+		lastID = p.ID
+		if !compressed {
+			lastOffset = offset + end
+		}
+
+		if !e.process(p) {
+			completed = false
+			return false
+		}
+
+		sinceCheckpoint++
+		if sinceCheckpoint >= checkpointInterval {
+			e.save(lastID, lastOffset, false)
+			sinceCheckpoint = 0
+		}
+		return true
+	})
+
+	// Save progress even on errors, since everything up to the last page has been written
+	e.save(lastID, lastOffset, completed && err == nil)
+
+	return completed, err
+}
+
+// extractMultistream reads the streams of a multistream dump in parallel, using its index to find the streams.
+// Streams without pages matching the filter are skipped entirely.
+// Returns whether the whole dump was read.
+func (e *extractor) extractMultistream(ctx context.Context, path, indexPath string, parallel int) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	streams, err := readIndex(indexPath, info.Size(), e.filter)
+	if err != nil {
+		return false, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var wanted []stream
+	for _, s := range streams {
+		if s.Wanted && s.Offset >= e.checkpoint.Offset {
+			wanted = append(wanted, s)
+		}
+	}
+	fmt.Printf("Reading %d of %d streams\n", len(wanted), len(streams))
+
+	batchSize := parallel * streamBatchSize
+	lastID := e.checkpoint.PageID
+	for i := 0; i < len(wanted); i += batchSize {
+		batch := wanted[i:min(i+batchSize, len(wanted))]
+
+		batchLastID, completed, err := e.readStreams(ctx, file, batch, parallel)
+		if err != nil || !completed {
+			// Partially read batches are not checkpointed, so they are read again on resume
+			return false, err
+		}
+
+		lastID = max(lastID, batchLastID)
+		next := info.Size()
+		if i+batchSize < len(wanted) {
+			next = wanted[i+batchSize].Offset
+		}
+		e.save(lastID, next, false)
+	}
+
+	e.save(lastID, info.Size(), true)
+	return true, nil
+}
+
+// readStreams from the dump file, at most parallel at a time.
+// Returns the highest page ID read, and whether all streams were read completely.
+func (e *extractor) readStreams(ctx context.Context, file *os.File, streams []stream, parallel int) (int, bool, error) {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	sem := make(chan struct{}, parallel)
+
+	var lastID int
+	completed := true
+	var firstErr error
+
+	for _, s := range streams {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r := bzip2.NewReader(bufio.NewReader(io.NewSectionReader(file, s.Offset, s.Length)))
+			err := readPages(r, true, func(p Page, _ int64) bool {
+				ok := ctx.Err() == nil && e.process(p)
+
+				lock.Lock()
+				defer lock.Unlock()
+				lastID = max(lastID, p.ID)
+				if !ok {
+					completed = false
+				}
+				return ok
+			})
+
+			if err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to read stream at offset %d: %w", s.Offset, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return lastID, completed, firstErr
+}
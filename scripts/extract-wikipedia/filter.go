@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Filter decides which pages are extracted. Redirects are always skipped.
+type Filter struct {
+	Namespaces map[int]bool
+	MinID      int             // 0 means no lower bound
+	MaxID      int             // 0 means no upper bound
+	Titles     map[string]bool // nil means all titles
+}
+
+// Match a page against the filter.
+func (f Filter) Match(p Page) bool {
+	return p.Redirect == nil && f.Namespaces[p.NS] && f.MatchIndexEntry(p.ID, p.Title)
+}
+
+// MatchIndexEntry checks page ID and title only, since the namespace isn't in the multistream index.
+func (f Filter) MatchIndexEntry(id int, title string) bool {
+	if f.MinID > 0 && id < f.MinID {
+		return false
+	}
+	if f.MaxID > 0 && id > f.MaxID {
+		return false
+	}
+	if f.Titles != nil && !f.Titles[normalizeTitle(title)] {
+		return false
+	}
+	return true
+}
+
+// parseNamespaces from a comma-separated list like "0,14".
+func parseNamespaces(s string) (map[int]bool, error) {
+	namespaces := map[int]bool{}
+	for _, v := range strings.Split(s, ",") {
+		ns, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace %q: %w", v, err)
+		}
+		namespaces[ns] = true
+	}
+	return namespaces, nil
+}
+
+// readTitles from a file with one page title per line.
+func readTitles(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	titles := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if title := normalizeTitle(scanner.Text()); title != "" {
+			titles[title] = true
+		}
+	}
+	return titles, scanner.Err()
+}
+
+// normalizeTitle so "Domestic_sheep" and "Domestic sheep" match, like in Wikipedia URLs.
+func normalizeTitle(title string) string {
+	return strings.TrimSpace(strings.ReplaceAll(title, "_", " "))
+}
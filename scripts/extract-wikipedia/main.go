@@ -1,14 +1,20 @@
 package main
 
 import (
-	"crypto/sha256"
+	"context"
 	"encoding/xml"
 	"flag"
 	"fmt"
-	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+
+	"app/wikitext"
 )
 
 // XML structures to match Wikipedia dump format
@@ -53,9 +59,17 @@ type Job struct {
 
 func main() {
 	// Parse command-line flags
-	inputFile := flag.String("input", "", "Path to Wikipedia XML dump file")
+	inputFile := flag.String("input", "", "Path to Wikipedia XML dump file, optionally bzip2 compressed (.xml.bz2)")
+	indexFile := flag.String("index", "", "Path to the index file of a multistream dump, to read the dump in parallel")
 	outputDir := flag.String("output", "pages", "Output directory for extracted pages")
 	limit := flag.Int("limit", 0, "Maximum number of pages to process (0 = no limit)")
+	checkpointFile := flag.String("checkpoint", "", "Path to the checkpoint file (default checkpoint.json in the output directory)")
+	restart := flag.Bool("restart", false, "Ignore any existing checkpoint and start from the beginning")
+	namespaces := flag.String("namespaces", "0", "Comma-separated list of namespaces to extract")
+	minID := flag.Int("min-id", 0, "Minimum page ID to extract (0 = no minimum)")
+	maxID := flag.Int("max-id", 0, "Maximum page ID to extract (0 = no maximum)")
+	titlesFile := flag.String("titles", "", "Path to a file with page titles to extract, one per line")
+	parallel := flag.Int("parallel", runtime.NumCPU(), "Number of streams to read in parallel with -index")
 	flag.Parse()
 
 	if *inputFile == "" {
@@ -64,178 +78,157 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create output directory if it doesn't exist
-	err := os.MkdirAll(*outputDir, 0755)
+	if *indexFile != "" && !strings.HasSuffix(*inputFile, ".bz2") {
+		fmt.Println("Error: An index file can only be used with a bzip2 compressed multistream dump")
+		os.Exit(1)
+	}
+
+	// Set up the filter
+	filter := Filter{MinID: *minID, MaxID: *maxID}
+	var err error
+	filter.Namespaces, err = parseNamespaces(*namespaces)
 	if err != nil {
-		fmt.Printf("Error creating output directory: %v\n", err)
+		fmt.Printf("Error parsing namespaces: %v\n", err)
 		os.Exit(1)
 	}
+	if *titlesFile != "" {
+		filter.Titles, err = readTitles(*titlesFile)
+		if err != nil {
+			fmt.Printf("Error reading titles file: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	// Open XML file
-	file, err := os.Open(*inputFile)
+	// Create output directory if it doesn't exist
+	err = os.MkdirAll(*outputDir, 0755)
 	if err != nil {
-		fmt.Printf("Error opening input file: %v\n", err)
+		fmt.Printf("Error creating output directory: %v\n", err)
 		os.Exit(1)
 	}
-	defer file.Close()
+
+	// Load the checkpoint from a previous run, if any
+	if *checkpointFile == "" {
+		*checkpointFile = filepath.Join(*outputDir, "checkpoint.json")
+	}
+	checkpoint := Checkpoint{Input: *inputFile}
+	if !*restart {
+		checkpoint, err = loadCheckpoint(*checkpointFile, *inputFile)
+		if err != nil {
+			fmt.Printf("Error loading checkpoint: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if checkpoint.Done {
+		fmt.Printf("Checkpoint %s says the extraction is already done, use -restart to start over\n", *checkpointFile)
+		return
+	}
+	if checkpoint.PageID > 0 {
+		fmt.Printf("Resuming after page ID %d (%d articles extracted so far)\n", checkpoint.PageID, checkpoint.Articles)
+	}
+
+	// Stop gracefully on interrupt, so the checkpoint is saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Create job queue and wait group
 	jobs := make(chan Job, maxQueueLength)
 	var wg sync.WaitGroup
 
+	e := &extractor{
+		checkpoint:     checkpoint,
+		checkpointPath: *checkpointFile,
+		filter:         filter,
+		jobs:           jobs,
+		limit:          *limit,
+		articleCount:   checkpoint.Articles,
+	}
+
 	// Start worker pool
 	for i := 0; i < numWorkers; i++ {
 		wg.Add(1)
-		go worker(jobs, &wg, *outputDir)
+		go worker(jobs, &wg, &e.pending, *outputDir)
 	}
 
-	// XML decoder
-	decoder := xml.NewDecoder(file)
-	var inPage bool
-	var currentPage Page
-
-	// Counters for statistics
-	pageCount := 0
-	articleCount := 0
-
 	fmt.Println("Starting to parse Wikipedia XML dump...")
 
-	// Parse XML
-outer:
-	for {
-		token, err := decoder.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Printf("Error reading XML token: %v\n", err)
-			break
-		}
-
-		switch se := token.(type) {
-		case xml.StartElement:
-			if se.Name.Local == "page" {
-				inPage = true
-				currentPage = Page{}
-			} else if inPage {
-				if se.Name.Local == "title" {
-					err := decoder.DecodeElement(&currentPage.Title, &se)
-					if err != nil {
-						fmt.Printf("Error decoding title: %v\n", err)
-					}
-				} else if se.Name.Local == "ns" {
-					err := decoder.DecodeElement(&currentPage.NS, &se)
-					if err != nil {
-						fmt.Printf("Error decoding namespace: %v\n", err)
-					}
-				} else if se.Name.Local == "id" && currentPage.ID == 0 {
-					err := decoder.DecodeElement(&currentPage.ID, &se)
-					if err != nil {
-						fmt.Printf("Error decoding ID: %v\n", err)
-					}
-				} else if se.Name.Local == "redirect" {
-					var redirect Redirect
-					for _, attr := range se.Attr {
-						if attr.Name.Local == "title" {
-							redirect.Title = attr.Value
-							break
-						}
-					}
-					currentPage.Redirect = &redirect
-				} else if se.Name.Local == "text" {
-					var text Text
-					err := decoder.DecodeElement(&text, &se)
-					if err != nil {
-						fmt.Printf("Error decoding text: %v\n", err)
-					}
-					currentPage.Revision.Text = text
-				}
-			}
-		case xml.EndElement:
-			if se.Name.Local == "page" {
-				inPage = false
-				pageCount++
-
-				// Process only main namespace articles (ns = 0) that are not redirects
-				if currentPage.NS == 0 && currentPage.Redirect == nil {
-					articleCount++
-
-					// Send job to worker pool
-					jobs <- Job{
-						Title: currentPage.Title,
-						Text:  currentPage.Revision.Text.Content,
-						ID:    currentPage.ID,
-					}
-
-					// Print progress every 10,000 articles
-					if articleCount%10000 == 0 {
-						fmt.Printf("Processed %d articles (total pages: %d)\n", articleCount, pageCount)
-					}
-
-					// Check if we've reached the limit
-					if *limit > 0 && articleCount >= *limit {
-						fmt.Printf("Reached limit of %d articles\n", *limit)
-						break outer
-					}
-				}
-			}
-		}
+	var completed bool
+	if *indexFile != "" {
+		completed, err = e.extractMultistream(ctx, *inputFile, *indexFile, *parallel)
+	} else {
+		completed, err = e.extractSequentially(ctx, *inputFile)
 	}
 
 	// Close job channel and wait for all workers to finish
 	close(jobs)
 	wg.Wait()
 
-	redirectCount := pageCount - articleCount
-	fmt.Printf("Completed! Processed %d total pages, extracted %d articles (skipped %d redirects)\n", pageCount, articleCount, redirectCount)
+	if err != nil {
+		fmt.Printf("Error reading dump: %v\n", err)
+		os.Exit(1)
+	}
+
+	articleCount := e.articleCount - checkpoint.Articles
+	skippedCount := e.pageCount - articleCount
+	if !completed {
+		fmt.Printf("Stopped! Processed %d pages, extracted %d articles (skipped %d), run again to resume\n", e.pageCount, articleCount, skippedCount)
+		return
+	}
+	fmt.Printf("Completed! Processed %d total pages, extracted %d articles (skipped %d)\n", e.pageCount, articleCount, skippedCount)
 }
 
 // worker processes jobs from the queue
-func worker(jobs <-chan Job, wg *sync.WaitGroup, outputDir string) {
+func worker(jobs <-chan Job, wg, pending *sync.WaitGroup, outputDir string) {
 	defer wg.Done()
 
 	for job := range jobs {
-		// Create sanitized filename
-		filename := fmt.Sprintf("%x", sha256.Sum256([]byte(job.Title)))
+		writePage(job, outputDir)
+		pending.Done()
+	}
+}
 
-		dirPath := filepath.Join(outputDir, filename[:3])
+// writePage to a Markdown file in a subdirectory of the output directory.
+// Files are named by page ID, so the name is stable across title changes and can be used as an external document ID.
+func writePage(job Job, outputDir string) {
+	filename := strconv.Itoa(job.ID)
 
-		// Create directory if it doesn't exist
-		err := os.MkdirAll(dirPath, 0755)
-		if err != nil {
-			fmt.Printf("Error creating directory %s: %v\n", dirPath, err)
-			continue
-		}
+	// Spread files over subdirectories by the last three digits of the page ID
+	dirPath := filepath.Join(outputDir, fmt.Sprintf("%03d", job.ID%1000))
 
-		// Full path to output file
-		filePath := filepath.Join(dirPath, filename+".md")
+	// Create directory if it doesn't exist
+	err := os.MkdirAll(dirPath, 0755)
+	if err != nil {
+		fmt.Printf("Error creating directory %s: %v\n", dirPath, err)
+		return
+	}
 
-		// Create markdown file
-		file, err := os.Create(filePath)
-		if err != nil {
-			fmt.Printf("Error creating file for '%s': %v\n", job.Title, err)
-			continue
-		}
+	// Full path to output file
+	filePath := filepath.Join(dirPath, filename+".md")
 
-		// Write markdown header with title
-		_, err = file.WriteString(fmt.Sprintf("# %s\n\n", job.Title))
-		if err != nil {
-			fmt.Printf("Error writing header for '%s': %v\n", job.Title, err)
-			if closeErr := file.Close(); closeErr != nil {
-				fmt.Printf("Error closing file for '%s': %v\n", job.Title, closeErr)
-			}
-			continue
-		}
+	// Create markdown file
+	file, err := os.Create(filePath)
+	if err != nil {
+		fmt.Printf("Error creating file for '%s': %v\n", job.Title, err)
+		return
+	}
 
-		// Write content as-is
-		_, err = file.WriteString(job.Text)
-		if err != nil {
-			fmt.Printf("Error writing content for '%s': %v\n", job.Title, err)
+	// Write markdown header with title
+	_, err = file.WriteString(fmt.Sprintf("# %s\n\n", job.Title))
+	if err != nil {
+		fmt.Printf("Error writing header for '%s': %v\n", job.Title, err)
+		if closeErr := file.Close(); closeErr != nil {
+			fmt.Printf("Error closing file for '%s': %v\n", job.Title, closeErr)
 		}
+		return
+	}
 
-		// Close the file and check for errors
-		if err := file.Close(); err != nil {
-			fmt.Printf("Error closing file for '%s': %v\n", job.Title, err)
-		}
+	// Write content converted to Markdown
+	_, err = file.WriteString(wikitext.Markdown(job.Text))
+	if err != nil {
+		fmt.Printf("Error writing content for '%s': %v\n", job.Title, err)
 	}
-}
\ No newline at end of file
+
+	// Close the file and check for errors
+	if err := file.Close(); err != nil {
+		fmt.Printf("Error closing file for '%s': %v\n", job.Title, err)
+	}
+}
@@ -1,26 +1,49 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"math/rand/v2"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
-)
 
-const (
-	numWorkers  = 8
-	maxRetries  = 3
-	apiEndpoint = "http://localhost:8080/documents"
+	"golang.org/x/time/rate"
 )
 
+const maxRetries = 3
+
+// Job is a file to upload, or a document to delete because its file is gone.
+type Job struct {
+	Path   string
+	Delete bool
+}
+
 func main() {
 	// Parse command line flags
 	inputDir := flag.String("input", "pages", "Input directory containing documents to upload")
+	endpoint := flag.String("endpoint", "http://localhost:8080/documents", "URL of the documents endpoint")
+	apiKey := flag.String("api-key", os.Getenv("API_KEY"), "API key sent as a bearer token (default $API_KEY)")
+	concurrency := flag.Int("concurrency", 8, "Number of concurrent uploads")
+	requestRate := flag.Float64("rate", 0, "Maximum requests per second (0 = no limit)")
+	timeout := flag.Duration("timeout", 30*time.Second, "Timeout for each request")
+	manifestPath := flag.String("manifest", "", "Path to the manifest of uploaded files (default manifest.json in the input directory)")
+	deleteMissing := flag.Bool("delete", false, "Delete documents whose files have disappeared since they were uploaded")
+	externalIDPrefix := flag.String("external-id-prefix", "wikipedia:", "Prefix of the external IDs of documents, which are the prefix and the file name without extension, like wikipedia:123")
 	flag.Parse()
 
 	// Check if directory exists
@@ -29,24 +52,59 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *manifestPath == "" {
+		*manifestPath = filepath.Join(*inputDir, "manifest.json")
+	}
+	manifest, err := loadManifest(*manifestPath)
+	if err != nil {
+		fmt.Printf("Error loading manifest: %v\n", err)
+		os.Exit(1)
+	}
+
+	limit := rate.Inf
+	if *requestRate > 0 {
+		limit = rate.Limit(*requestRate)
+	}
+
+	// Stop gracefully on interrupt, so the manifest is saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	u := &uploader{
+		apiKey:           *apiKey,
+		client:           &http.Client{Timeout: *timeout},
+		endpoint:         *endpoint,
+		externalIDPrefix: *externalIDPrefix,
+		inputDir:         *inputDir,
+		limiter:          rate.NewLimiter(limit, 1),
+		manifest:         manifest,
+	}
+
 	// Set up channels for workers
-	jobs := make(chan string, 10000)
+	jobs := make(chan Job, 10000)
 	var wg sync.WaitGroup
 
 	// Start worker pool
-	for range numWorkers {
+	for range *concurrency {
 		wg.Add(1)
-		go worker(jobs, &wg)
+		go worker(ctx, jobs, &wg, u)
 	}
 
 	// Track statistics
 	var fileCount int
+	seen := map[string]bool{}
+	var walkFailed bool
 
 	// Walk the directory and queue files
 	fmt.Println("Scanning for files...")
-	err := filepath.Walk(*inputDir, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(*inputDir, func(p string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+
 		if err != nil {
-			fmt.Printf("Error accessing path %s: %v\n", path, err)
+			fmt.Printf("Error accessing path %s: %v\n", p, err)
+			walkFailed = true
 			return nil
 		}
 
@@ -56,8 +114,13 @@ func main() {
 		}
 
 		// Only process markdown files
-		if filepath.Ext(path) == ".md" {
-			jobs <- path
+		if filepath.Ext(p) == ".md" {
+			rel, err := filepath.Rel(*inputDir, p)
+			if err != nil {
+				return err
+			}
+			seen[rel] = true
+			jobs <- Job{Path: rel}
 			fileCount++
 		}
 
@@ -70,80 +133,292 @@ func main() {
 
 	fmt.Printf("Found %d files to process\n", fileCount)
 
+	// Queue deletions of documents whose files are gone, but only if all of the directory could be read
+	if *deleteMissing && ctx.Err() == nil {
+		if walkFailed {
+			fmt.Println("Not deleting documents, because some paths could not be read")
+		} else {
+			for _, p := range manifest.Paths() {
+				if !seen[p] {
+					jobs <- Job{Path: p, Delete: true}
+				}
+			}
+		}
+	}
+
 	// Close the jobs channel to signal workers that no more jobs are coming
 	close(jobs)
 
 	// Wait for all workers to finish
 	wg.Wait()
 
-	fmt.Printf("Upload complete: processed %d files\n", fileCount)
+	if err := manifest.Save(); err != nil {
+		fmt.Printf("Error saving manifest: %v\n", err)
+	}
+
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted, run again to continue")
+	}
+
+	if !u.report() {
+		os.Exit(1)
+	}
 }
 
 // worker processes jobs from the queue
-func worker(jobs <-chan string, wg *sync.WaitGroup) {
+func worker(ctx context.Context, jobs <-chan Job, wg *sync.WaitGroup, u *uploader) {
 	defer wg.Done()
 
-	for path := range jobs {
-		// Process the file with retries
-		success := false
-		for attempt := range maxRetries {
-			if attempt > 0 {
-				// Wait before retry
-				backoff := time.Duration(500*(1<<uint(attempt))) * time.Millisecond
-				jitter := time.Duration(rand.IntN(250)) * time.Millisecond
-				time.Sleep(backoff + jitter)
-			}
+	for job := range jobs {
+		// Drain the queue without processing when interrupted
+		if ctx.Err() != nil {
+			continue
+		}
+
+		if job.Delete {
+			u.delete(ctx, job.Path)
+		} else {
+			u.upload(ctx, job.Path)
+		}
+	}
+}
+
+// uploader uploads files to the documents endpoint, recording uploaded documents in the manifest.
+// Documents are addressed by their external IDs, which are made from the page IDs in the file names,
+// so uploads are idempotent PUTs that can be retried without creating duplicates.
+type uploader struct {
+	apiKey           string
+	client           *http.Client
+	endpoint         string
+	externalIDPrefix string
+	inputDir         string
+	limiter          *rate.Limiter
+	manifest         *Manifest
+
+	lock     sync.Mutex
+	counts   map[string]int
+	failures []failure
+}
+
+type failure struct {
+	Path string
+	Err  error
+}
+
+// statusError is returned for unexpected HTTP status codes.
+type statusError struct {
+	Code int
+	Body string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d (%v)", e.Code, e.Body)
+}
 
-			if err := uploadFile(path); err != nil {
-				fmt.Printf("Error uploading %s (attempt %d/%d): %v\n", path, attempt+1, maxRetries, err)
-			} else {
-				success = true
-				break
+// upload a file. Unchanged files are skipped, and other files are upserted by their external ID,
+// which creates the document if it doesn't exist on the server, and updates it otherwise.
+// Updates don't return the document ID, so the ID already in the manifest is kept.
+func (u *uploader) upload(ctx context.Context, p string) {
+	content, err := os.ReadFile(filepath.Join(u.inputDir, p))
+	if err != nil {
+		u.fail(p, fmt.Errorf("failed to read file: %w", err))
+		return
+	}
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	entry, ok := u.manifest.Get(p)
+	if ok && entry.Hash == hash {
+		u.count("unchanged")
+		return
+	}
+
+	externalID := u.externalID(p)
+
+	var id string
+	var created bool
+	err = u.retry(ctx, p, func() error {
+		header, code, err := u.request(ctx, http.MethodPut, u.externalIDURL(externalID), content, http.StatusOK, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		if code == http.StatusCreated {
+			created = true
+			id = path.Base(header.Get("Location"))
+		}
+		return nil
+	})
+	if err != nil {
+		u.fail(p, err)
+		return
+	}
+
+	// Documents uploaded before external IDs were used are replaced by the created ones, so delete them
+	if created && ok && entry.ExternalID == "" && entry.ID != "" && entry.ID != id {
+		if err := u.deleteByURL(ctx, p, u.endpoint+"/"+entry.ID); err != nil {
+			u.fail(p, fmt.Errorf("failed to delete document replaced by %v: %w", externalID, err))
+		}
+	}
+
+	if !created {
+		id = entry.ID
+	}
+	u.manifest.Set(p, ManifestEntry{ID: id, ExternalID: externalID, Hash: hash})
+	if created {
+		u.count("created")
+	} else {
+		u.count("updated")
+	}
+}
+
+// externalID of the document for the file at p, with the external ID prefix and the file name without extension.
+func (u *uploader) externalID(p string) string {
+	return u.externalIDPrefix + strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+}
+
+// externalIDURL of the document with the external ID.
+func (u *uploader) externalIDURL(externalID string) string {
+	return u.endpoint + "/by-external-id/" + url.PathEscape(externalID)
+}
+
+// delete the document for a file that is gone. Documents already deleted on the server count as deleted.
+// Documents uploaded before external IDs were used are deleted by the ID in the manifest.
+func (u *uploader) delete(ctx context.Context, p string) {
+	entry, ok := u.manifest.Get(p)
+	if !ok {
+		return
+	}
+
+	documentURL := u.endpoint + "/" + entry.ID
+	if entry.ExternalID != "" {
+		documentURL = u.externalIDURL(entry.ExternalID)
+	}
+
+	if err := u.deleteByURL(ctx, p, documentURL); err != nil {
+		u.fail(p, err)
+		return
+	}
+
+	u.manifest.Delete(p)
+	u.count("deleted")
+}
+
+// deleteByURL deletes the document at the URL, treating a document that is already gone as deleted.
+func (u *uploader) deleteByURL(ctx context.Context, p, documentURL string) error {
+	return u.retry(ctx, p, func() error {
+		_, _, err := u.request(ctx, http.MethodDelete, documentURL, nil, http.StatusNoContent)
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// retry the function with backoff. Client errors other than 429 Too Many Requests are not retried.
+func (u *uploader) retry(ctx context.Context, p string, fn func() error) error {
+	var err error
+	for attempt := range maxRetries {
+		if attempt > 0 {
+			// Wait before retry
+			backoff := time.Duration(500*(1<<uint(attempt))) * time.Millisecond
+			jitter := time.Duration(rand.IntN(250)) * time.Millisecond
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff + jitter):
 			}
 		}
 
-		if !success {
-			fmt.Printf("Failed to upload after %d attempts: %s\n", maxRetries, path)
+		if err = fn(); err == nil {
+			return nil
 		}
+
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.Code < 500 && statusErr.Code != http.StatusTooManyRequests {
+			return err
+		}
+
+		fmt.Printf("Error processing %s (attempt %d/%d): %v\n", p, attempt+1, maxRetries, err)
 	}
+	return err
 }
 
-// uploadFile reads a file and uploads it to the API
-func uploadFile(path string) error {
-	// Read file content
-	file, err := os.Open(path)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// request the URL with an optional Markdown body, returning the response header and status code
+// if the status code is one of the expected ones.
+func (u *uploader) request(ctx context.Context, method, url string, body []byte, expectedCodes ...int) (http.Header, int, error) {
+	if err := u.limiter.Wait(ctx); err != nil {
+		return nil, 0, err
 	}
-	defer file.Close()
 
 	// Create the request
-	req, err := http.NewRequest(http.MethodPost, apiEndpoint, file)
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "text/markdown")
-
-	// Send the request
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if body != nil {
+		req.Header.Set("Content-Type", "text/markdown")
+	}
+	if u.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+u.apiKey)
 	}
 
-	resp, err := client.Do(req)
+	// Send the request
+	resp, err := u.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, 0, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check response status
-	if resp.StatusCode != http.StatusCreated {
+	if !slices.Contains(expectedCodes, resp.StatusCode) {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("failed to read response body: %w", err)
+			return nil, 0, fmt.Errorf("failed to read response body: %w", err)
 		}
-		return fmt.Errorf("unexpected status code: %d (%v)", resp.StatusCode, string(body))
+		return nil, 0, statusError{Code: resp.StatusCode, Body: string(bytes.TrimSpace(body))}
 	}
 
-	return nil
+	return resp.Header, resp.StatusCode, nil
+}
+
+func (u *uploader) count(result string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.counts == nil {
+		u.counts = map[string]int{}
+	}
+	u.counts[result]++
+}
+
+func (u *uploader) fail(p string, err error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.failures = append(u.failures, failure{Path: p, Err: err})
+}
+
+// report statistics and failures, returning false if anything failed.
+func (u *uploader) report() bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	fmt.Printf("Upload complete: %d created, %d updated, %d unchanged, %d deleted, %d failed\n",
+		u.counts["created"], u.counts["updated"], u.counts["unchanged"], u.counts["deleted"], len(u.failures))
+
+	if len(u.failures) == 0 {
+		return true
+	}
+
+	sort.Slice(u.failures, func(i, j int) bool {
+		return u.failures[i].Path < u.failures[j].Path
+	})
+	fmt.Println("Failures:")
+	for _, f := range u.failures {
+		fmt.Printf("- %s: %v\n", f.Path, f.Err)
+	}
+	return false
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ManifestEntry records the document uploaded for a file.
+// ExternalID is empty for documents uploaded before external IDs were used.
+type ManifestEntry struct {
+	ID         string `json:"id"`
+	ExternalID string `json:"externalID,omitempty"`
+	Hash       string `json:"hash"` // SHA-256 of the file content, hex encoded
+}
+
+// Manifest maps file paths, relative to the input directory, to uploaded documents.
+// It's safe for concurrent use.
+type Manifest struct {
+	path    string
+	lock    sync.Mutex
+	entries map[string]ManifestEntry
+	changes int
+}
+
+// manifestSaveInterval is the number of changes between saves, so an interrupted run loses little progress.
+const manifestSaveInterval = 100
+
+// loadManifest from path. If the file doesn't exist, the manifest is empty.
+func loadManifest(path string) (*Manifest, error) {
+	m := &Manifest{path: path, entries: map[string]ManifestEntry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) Get(path string) (ManifestEntry, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	e, ok := m.entries[path]
+	return e, ok
+}
+
+// Set the entry for path, saving the manifest every [manifestSaveInterval] changes.
+func (m *Manifest) Set(path string, e ManifestEntry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.entries[path] = e
+	m.changed()
+}
+
+// Delete the entry for path, saving the manifest every [manifestSaveInterval] changes.
+func (m *Manifest) Delete(path string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.entries, path)
+	m.changed()
+}
+
+// Paths in the manifest.
+func (m *Manifest) Paths() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var paths []string
+	for path := range m.entries {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// changed must be called with the lock held.
+func (m *Manifest) changed() {
+	m.changes++
+	if m.changes%manifestSaveInterval == 0 {
+		if err := m.save(); err != nil {
+			fmt.Printf("Error saving manifest: %v\n", err)
+		}
+	}
+}
+
+// Save the manifest to its file.
+func (m *Manifest) Save() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.save()
+}
+
+// save the manifest atomically, by writing to a temporary file first and renaming it.
+// Must be called with the lock held.
+func (m *Manifest) save() error {
+	data, err := json.MarshalIndent(m.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := m.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, m.path)
+}
@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// CreateAPIKey with a name, storing only the hash of the key, see [model.NewAPIKey].
+func (d *Database) CreateAPIKey(ctx context.Context, name, key string) (_ model.APIKey, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	var k model.APIKey
+	query := `
+		insert into api_keys (id, hash, name)
+		values (?, ?, ?)
+		returning id, created, name, revoked
+	`
+	if err := d.H.Get(ctx, &k, query, model.APIKeyID(key), model.APIKeyHash(key), name); err != nil {
+		return k, errors.Wrap(err, "error creating API key")
+	}
+
+	return k, nil
+}
+
+// GetAPIKey that is not revoked, by the key itself, or [model.ErrorAPIKeyNotFound] if there is none.
+func (d *Database) GetAPIKey(ctx context.Context, key string) (_ model.APIKey, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	var k model.APIKey
+	query := `
+		select id, created, name, revoked
+		from api_keys
+		where hash = ? and revoked is null
+	`
+	if err := d.H.Get(ctx, &k, query, model.APIKeyHash(key)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return k, model.ErrorAPIKeyNotFound
+		}
+		return k, errors.Wrap(err, "error getting API key")
+	}
+
+	return k, nil
+}
+
+// ListAPIKeys, including revoked keys, oldest first.
+func (d *Database) ListAPIKeys(ctx context.Context) (_ []model.APIKey, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListAPIKeys")
+	defer func() { tracing.End(span, err) }()
+
+	var ks []model.APIKey
+	if err := d.H.Select(ctx, &ks, "select id, created, name, revoked from api_keys order by created, id"); err != nil {
+		return nil, errors.Wrap(err, "error listing API keys")
+	}
+
+	return ks, nil
+}
+
+// RevokeAPIKey by its ID, or return [model.ErrorAPIKeyNotFound] if there is no such key that is not revoked.
+func (d *Database) RevokeAPIKey(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.RevokeAPIKey", trace.WithAttributes(attribute.String("apiKey.id", id)))
+	defer func() { tracing.End(span, err) }()
+
+	var ids []string
+	query := `
+		update api_keys
+		set revoked = strftime('%Y-%m-%dT%H:%M:%fZ')
+		where id = ? and revoked is null
+		returning id
+	`
+	if err := d.H.Select(ctx, &ids, query, id); err != nil {
+		return errors.Wrap(err, "error revoking API key")
+	}
+
+	if len(ids) == 0 {
+		return model.ErrorAPIKeyNotFound
+	}
+
+	return nil
+}
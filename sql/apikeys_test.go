@@ -0,0 +1,44 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDatabase_APIKeys(t *testing.T) {
+	t.Run("creates, gets, lists, and revokes API keys", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		key := model.NewAPIKey()
+		k, err := db.CreateAPIKey(t.Context(), "Sheep", key)
+		is.NotError(t, err)
+		is.Equal(t, model.APIKeyID(key), k.ID)
+		is.Equal(t, "Sheep", k.Name)
+		is.True(t, k.Revoked == nil)
+
+		k, err = db.GetAPIKey(t.Context(), key)
+		is.NotError(t, err)
+		is.Equal(t, model.APIKeyID(key), k.ID)
+
+		_, err = db.GetAPIKey(t.Context(), model.NewAPIKey())
+		is.Error(t, model.ErrorAPIKeyNotFound, err)
+
+		err = db.RevokeAPIKey(t.Context(), k.ID)
+		is.NotError(t, err)
+
+		_, err = db.GetAPIKey(t.Context(), key)
+		is.Error(t, model.ErrorAPIKeyNotFound, err)
+
+		err = db.RevokeAPIKey(t.Context(), k.ID)
+		is.Error(t, model.ErrorAPIKeyNotFound, err)
+
+		keys, err := db.ListAPIKeys(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(keys))
+		is.True(t, keys[0].Revoked != nil)
+	})
+}
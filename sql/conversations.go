@@ -0,0 +1,170 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// CreateConversation with an optional title.
+func (d *Database) CreateConversation(ctx context.Context, title string) (_ model.Conversation, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateConversation")
+	defer func() { tracing.End(span, err) }()
+
+	var c model.Conversation
+	if err := d.H.Get(ctx, &c, "insert into conversations (title) values (?) returning *", title); err != nil {
+		return c, errors.Wrap(err, "error creating conversation")
+	}
+
+	return c, nil
+}
+
+func (d *Database) GetConversation(ctx context.Context, id model.ID) (_ model.Conversation, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetConversation", trace.WithAttributes(attribute.String("conversation.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	var c model.Conversation
+	if err := d.H.Get(ctx, &c, "select * from conversations where id = ?", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c, model.ErrorConversationNotFound
+		}
+		return c, errors.Wrap(err, "error getting conversation")
+	}
+
+	return c, nil
+}
+
+// ListConversations, most recently updated first.
+func (d *Database) ListConversations(ctx context.Context) (_ []model.Conversation, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListConversations")
+	defer func() { tracing.End(span, err) }()
+
+	var cs []model.Conversation
+	if err := d.H.Select(ctx, &cs, "select * from conversations order by updated desc, id"); err != nil {
+		return nil, errors.Wrap(err, "error listing conversations")
+	}
+
+	return cs, nil
+}
+
+// DeleteConversation with all its messages.
+func (d *Database) DeleteConversation(ctx context.Context, id model.ID) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.DeleteConversation", trace.WithAttributes(attribute.String("conversation.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	var ids []model.ID
+	if err := d.H.Select(ctx, &ids, "delete from conversations where id = ? returning id", id); err != nil {
+		return errors.Wrap(err, "error deleting conversation")
+	}
+
+	if len(ids) == 0 {
+		return model.ErrorConversationNotFound
+	}
+
+	return nil
+}
+
+// AddMessage to its conversation, which also marks the conversation as updated.
+func (d *Database) AddMessage(ctx context.Context, m model.Message) (model.Message, error) {
+	ms, err := d.AddMessages(ctx, []model.Message{m})
+	if err != nil {
+		return m, err
+	}
+	return ms[0], nil
+}
+
+// AddMessages to their conversations in one transaction, in order, which also marks the conversations as updated.
+// Either all messages are added, or none are.
+func (d *Database) AddMessages(ctx context.Context, ms []model.Message) (_ []model.Message, err error) {
+	ctx, span := tracer.Start(ctx, "sql.AddMessages", trace.WithAttributes(attribute.Int("messages", len(ms))))
+	defer func() { tracing.End(span, err) }()
+
+	added := make([]model.Message, len(ms))
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		for i, m := range ms {
+			var ids []model.ID
+			// The update trigger sets the updated timestamp
+			if err := tx.Select(ctx, &ids, "update conversations set title = title where id = ? returning id", m.ConversationID); err != nil {
+				return errors.Wrap(err, "error updating conversation")
+			}
+
+			if len(ids) == 0 {
+				return model.ErrorConversationNotFound
+			}
+
+			query := `
+				insert into messages (conversationID, role, content, citations, prompt)
+				values (?, ?, ?, ?, ?)
+				returning *
+			`
+			if err := tx.Get(ctx, &added[i], query, m.ConversationID, m.Role, m.Content, m.Citations, m.Prompt); err != nil {
+				return errors.Wrap(err, "error adding message")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// ListMessages of the conversation, oldest first.
+func (d *Database) ListMessages(ctx context.Context, conversationID model.ID) (_ []model.Message, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListMessages", trace.WithAttributes(attribute.String("conversation.id", string(conversationID))))
+	defer func() { tracing.End(span, err) }()
+
+	var ms []model.Message
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from conversations where id = ?)", conversationID); err != nil {
+			return errors.Wrap(err, "error checking if conversation exists")
+		}
+
+		if !exists {
+			return model.ErrorConversationNotFound
+		}
+
+		if err := tx.Select(ctx, &ms, "select * from messages where conversationID = ? order by rowid", conversationID); err != nil {
+			return errors.Wrap(err, "error listing messages")
+		}
+
+		return nil
+	})
+
+	return ms, err
+}
+
+// SaveConversationSummary of the given number of oldest messages.
+func (d *Database) SaveConversationSummary(ctx context.Context, id model.ID, summary string, summarizedMessages int) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.SaveConversationSummary", trace.WithAttributes(
+		attribute.String("conversation.id", string(id)),
+		attribute.Int("summarizedMessages", summarizedMessages),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	var ids []model.ID
+	query := `
+		update conversations
+		set summary = ?, summarizedMessages = ?
+		where id = ?
+		returning id
+	`
+	if err := d.H.Select(ctx, &ids, query, summary, summarizedMessages, id); err != nil {
+		return errors.Wrap(err, "error saving conversation summary")
+	}
+
+	if len(ids) == 0 {
+		return model.ErrorConversationNotFound
+	}
+
+	return nil
+}
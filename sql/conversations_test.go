@@ -0,0 +1,82 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDatabase_Conversations(t *testing.T) {
+	t.Run("creates a conversation, adds messages, and deletes it", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		c, err := db.CreateConversation(t.Context(), "Sheep")
+		is.NotError(t, err)
+		is.True(t, c.ID != "")
+		is.Equal(t, "Sheep", c.Title)
+
+		for _, m := range []model.Message{
+			{ConversationID: c.ID, Role: model.MessageRoleUser, Content: "What do sheep eat?"},
+			{ConversationID: c.ID, Role: model.MessageRoleAssistant, Content: "Grass [1].", Citations: model.Citations{
+				{Number: 1, DocumentID: "d_1", ChunkIndex: 2, Quote: "Sheep eat grass."},
+			}, Prompt: "reply@v1"},
+		} {
+			_, err := db.AddMessage(t.Context(), m)
+			is.NotError(t, err)
+		}
+
+		messages, err := db.ListMessages(t.Context(), c.ID)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(messages))
+		is.Equal(t, model.MessageRoleUser, messages[0].Role)
+		is.Equal(t, "What do sheep eat?", messages[0].Content)
+		is.Equal(t, model.MessageRoleAssistant, messages[1].Role)
+		is.Equal(t, "Grass [1].", messages[1].Content)
+		is.Equal(t, 0, len(messages[0].Citations))
+		is.Equal(t, 1, len(messages[1].Citations))
+		is.Equal(t, "reply@v1", messages[1].Prompt)
+		is.Equal(t, model.Citation{Number: 1, DocumentID: "d_1", ChunkIndex: 2, Quote: "Sheep eat grass."}, messages[1].Citations[0])
+
+		cs, err := db.ListConversations(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(cs))
+		is.Equal(t, c.ID, cs[0].ID)
+
+		err = db.DeleteConversation(t.Context(), c.ID)
+		is.NotError(t, err)
+
+		_, err = db.GetConversation(t.Context(), c.ID)
+		is.Error(t, model.ErrorConversationNotFound, err)
+
+		_, err = db.ListMessages(t.Context(), c.ID)
+		is.Error(t, model.ErrorConversationNotFound, err)
+
+		err = db.DeleteConversation(t.Context(), c.ID)
+		is.Error(t, model.ErrorConversationNotFound, err)
+	})
+
+	t.Run("errors when adding a message to a conversation that does not exist", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.AddMessage(t.Context(), model.Message{ConversationID: "cv_missing", Role: model.MessageRoleUser, Content: "Hi"})
+		is.Error(t, model.ErrorConversationNotFound, err)
+	})
+
+	t.Run("saves the conversation summary", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		c, err := db.CreateConversation(t.Context(), "")
+		is.NotError(t, err)
+
+		err = db.SaveConversationSummary(t.Context(), c.ID, "About sheep.", 2)
+		is.NotError(t, err)
+
+		c, err = db.GetConversation(t.Context(), c.ID)
+		is.NotError(t, err)
+		is.Equal(t, "About sheep.", c.Summary)
+		is.Equal(t, 2, c.SummarizedMessages)
+	})
+}
@@ -2,13 +2,23 @@ package sql
 
 import (
 	"context"
+	"embed"
+	"io/fs"
 	"log/slog"
+	"path"
+	"slices"
+	"strings"
 
 	sqlitevec "github.com/asg017/sqlite-vec-go-bindings/cgo"
+	"go.opentelemetry.io/otel"
 	"maragu.dev/errors"
 	"maragu.dev/sqlh/sql"
+
+	"app/tracing"
 )
 
+var tracer = otel.Tracer("app/sql")
+
 type Database struct {
 	H   *sql.Helper
 	log *slog.Logger
@@ -42,16 +52,19 @@ func (d *Database) Connect() error {
 		return errors.Wrap(err, "error connecting to database")
 	}
 
-	var vecVersion string
-	if err := d.H.Get(context.Background(), &vecVersion, "select vec_version()"); err != nil {
-		return errors.Wrap(err, "error getting vec version")
+	vecVersion, err := d.VecVersion(context.Background())
+	if err != nil {
+		return err
 	}
 	d.log.Info("Loaded SQLite vector search extension", "version", vecVersion)
 
 	return nil
 }
 
-func (d *Database) MigrateUp(ctx context.Context) error {
+func (d *Database) MigrateUp(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.MigrateUp")
+	defer func() { tracing.End(span, err) }()
+
 	if err := d.H.MigrateUp(ctx); err != nil {
 		return err
 	}
@@ -59,10 +72,66 @@ func (d *Database) MigrateUp(ctx context.Context) error {
 	return nil
 }
 
-func (d *Database) MigrateDown(ctx context.Context) error {
+func (d *Database) MigrateDown(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.MigrateDown")
+	defer func() { tracing.End(span, err) }()
+
 	if err := d.H.MigrateDown(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// Ping the database.
+func (d *Database) Ping(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.Ping")
+	defer func() { tracing.End(span, err) }()
+
+	var one int
+	if err := d.H.Get(ctx, &one, "select 1"); err != nil {
+		return errors.Wrap(err, "error pinging database")
+	}
+	return nil
+}
+
+// VecVersion returns the version of the loaded SQLite vector search extension.
+func (d *Database) VecVersion(ctx context.Context) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "sql.VecVersion")
+	defer func() { tracing.End(span, err) }()
+
+	var version string
+	if err := d.H.Get(ctx, &version, "select vec_version()"); err != nil {
+		return "", errors.Wrap(err, "error getting vec version")
+	}
+	return version, nil
+}
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// CheckMigrations returns an error if the database is not migrated up to the latest migration.
+func (d *Database) CheckMigrations(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.CheckMigrations")
+	defer func() { tracing.End(span, err) }()
+
+	names, err := fs.Glob(migrations, "migrations/*.up.sql")
+	if err != nil {
+		return errors.Wrap(err, "error listing migrations")
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+	latest := strings.TrimSuffix(path.Base(names[len(names)-1]), ".up.sql")
+
+	var version string
+	if err := d.H.Get(ctx, &version, "select version from migrations"); err != nil {
+		return errors.Wrap(err, "error getting migration version")
+	}
+
+	if version != latest {
+		return errors.Newf("database is at migration %v, but latest is %v", version, latest)
+	}
+	return nil
+}
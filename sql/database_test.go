@@ -21,6 +21,25 @@ func TestDatabase_Migrate(t *testing.T) {
 		var version string
 		err = db.H.Get(t.Context(), &version, "select version from migrations")
 		is.NotError(t, err)
-		is.Equal(t, "1741176647-documents", version)
+		is.Equal(t, "1744110000-job-api-keys", version)
+	})
+}
+
+func TestDatabase_CheckMigrations(t *testing.T) {
+	t.Run("returns no error when migrated up", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		err := db.CheckMigrations(t.Context())
+		is.NotError(t, err)
+	})
+
+	t.Run("returns an error when not migrated up", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		err := db.MigrateDown(t.Context())
+		is.NotError(t, err)
+
+		err = db.CheckMigrations(t.Context())
+		is.True(t, err != nil)
 	})
 }
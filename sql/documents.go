@@ -1,42 +1,131 @@
 package sql
 
 import (
+	"app/dedup"
 	"app/model"
+	"app/tracing"
 	"context"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"maragu.dev/errors"
 	"maragu.dev/sqlh/sql"
 )
 
 // CreateDocument and add the chunks as well as the chunk embeddings.
-func (d *Database) CreateDocument(ctx context.Context, doc model.Document, chunks []model.Chunk) (model.Document, error) {
-	err := d.H.InTransaction(ctx, func(tx *sql.Tx) error {
-		query := `
-			insert into documents (content)
-			values (?)
-			returning *
-		`
-		err := tx.Get(ctx, &doc, query, doc.Content)
-		if err != nil {
-			return errors.Wrap(err, "error creating document")
-		}
+func (d *Database) CreateDocument(ctx context.Context, doc model.Document, chunks []model.Chunk) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateDocument", trace.WithAttributes(attribute.Int("chunks", len(chunks))))
+	defer func() { tracing.End(span, err) }()
 
-		if err := d.saveChunks(ctx, tx, doc.ID, chunks); err != nil {
-			return errors.Wrap(err, "error saving chunks")
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		doc, _, err = d.createDocument(ctx, tx, doc, chunks, false)
+		return err
+	})
+
+	return doc, err
+}
+
+// CreateDocumentIfNew is like [Database.CreateDocument], unless there's a document with the same content hash.
+// Then that document is returned, and created is false.
+// The check and the insert are one statement, so concurrent calls with the same content create only one document.
+func (d *Database) CreateDocumentIfNew(ctx context.Context, doc model.Document, chunks []model.Chunk) (_ model.Document, created bool, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateDocumentIfNew", trace.WithAttributes(attribute.Int("chunks", len(chunks))))
+	defer func() { tracing.End(span, err) }()
+
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		doc, created, err = d.createDocument(ctx, tx, doc, chunks, true)
+		return err
+	})
+
+	return doc, created, err
+}
+
+// CreateDocuments with their chunks and chunk embeddings in a single transaction.
+// The chunks slice has the chunks for each document, in the same order as docs.
+func (d *Database) CreateDocuments(ctx context.Context, docs []model.Document, chunks [][]model.Chunk) (_ []model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateDocuments", trace.WithAttributes(attribute.Int("documents", len(docs))))
+	defer func() { tracing.End(span, err) }()
+
+	created, _, err := d.createDocuments(ctx, docs, chunks, false)
+	return created, err
+}
+
+// CreateDocumentsIfNew is like [Database.CreateDocuments], but like [Database.CreateDocumentIfNew] for each document.
+// The created slice has whether each document was created, or else the existing document is returned in its place.
+func (d *Database) CreateDocumentsIfNew(ctx context.Context, docs []model.Document, chunks [][]model.Chunk) (_ []model.Document, created []bool, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateDocumentsIfNew", trace.WithAttributes(attribute.Int("documents", len(docs))))
+	defer func() { tracing.End(span, err) }()
+
+	return d.createDocuments(ctx, docs, chunks, true)
+}
+
+func (d *Database) createDocuments(ctx context.Context, docs []model.Document, chunks [][]model.Chunk, ifNew bool) ([]model.Document, []bool, error) {
+	if len(docs) != len(chunks) {
+		panic("docs and chunks must have the same length")
+	}
+
+	result := make([]model.Document, len(docs))
+	created := make([]bool, len(docs))
+	err := d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		for i, doc := range docs {
+			var err error
+			if result[i], created[i], err = d.createDocument(ctx, tx, doc, chunks[i], ifNew); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return doc, err
+	return result, created, nil
+}
+
+// createDocument with its chunks in the transaction.
+// If ifNew is true and there's a document with the same content hash, that document is returned instead, and created is false.
+func (d *Database) createDocument(ctx context.Context, tx *sql.Tx, doc model.Document, chunks []model.Chunk, ifNew bool) (model.Document, bool, error) {
+	hash := dedup.ContentHash(doc.Content)
+
+	query := `
+		insert into documents (content, metadata, contentHash, simHash)
+		select ?, ?, ?, ?
+		where not ? or not exists (select 1 from documents where contentHash = ? and deleted is null)
+		returning id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+	`
+	err := tx.Get(ctx, &doc, query, doc.Content, doc.Metadata, hash, int64(dedup.SimHash(doc.Content)), ifNew, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		query := `
+			select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+			from documents
+			where contentHash = ? and deleted is null
+			order by created, id
+			limit 1
+		`
+		if err := tx.Get(ctx, &doc, query, hash); err != nil {
+			return doc, false, errors.Wrap(err, "error getting existing document")
+		}
+		return doc, false, nil
+	}
+	if err != nil {
+		return doc, false, errors.Wrap(err, "error creating document")
+	}
+
+	if err := d.saveChunks(ctx, tx, doc.ID, chunks); err != nil {
+		return doc, false, errors.Wrap(err, "error saving chunks")
+	}
+
+	return doc, true, nil
 }
 
 // saveChunks by deleting previous chunks and inserting new ones.
+// The summary chunk is kept, see [Database.SaveDocumentSummary].
 func (d *Database) saveChunks(ctx context.Context, tx *sql.Tx, docID model.ID, chunks []model.Chunk) error {
 	query := `
-		delete from chunks where documentID = ?
+		delete from chunks where documentID = ? and "index" != ?
 	`
-	if err := tx.Exec(ctx, query, docID); err != nil {
+	if err := tx.Exec(ctx, query, docID, model.SummaryChunkIndex); err != nil {
 		return errors.Wrap(err, "error deleting previous chunks")
 	}
 
@@ -70,9 +159,12 @@ type ListDocumentsOptions struct {
 	// Cursor is the ID of the last document seen.
 	// If provided, the result will only include documents with IDs greater than the cursor.
 	Cursor model.ID
+
+	// Tag to filter documents by. If provided, the result will only include documents with the tag.
+	Tag string
 }
 
-func (d *Database) ListDocuments(ctx context.Context, opts ListDocumentsOptions) ([]model.Document, error) {
+func (d *Database) ListDocuments(ctx context.Context, opts ListDocumentsOptions) (_ []model.Document, err error) {
 	if opts.Limit < 0 {
 		panic("limit cannot be negative")
 	}
@@ -81,27 +173,29 @@ func (d *Database) ListDocuments(ctx context.Context, opts ListDocumentsOptions)
 		opts.Limit = 100
 	}
 
-	var query string
-	var args []any
+	ctx, span := tracer.Start(ctx, "sql.ListDocuments", trace.WithAttributes(
+		attribute.Int("limit", opts.Limit),
+		attribute.String("tag", opts.Tag),
+	))
+	defer func() { tracing.End(span, err) }()
 
-	if opts.Cursor != "" {
-		query = `
-			select id, created, updated, content
-			from documents
-			where id > ?
-			order by id
-			limit ?
-		`
-		args = []any{opts.Cursor, opts.Limit}
-	} else {
-		query = `
-			select id, created, updated, content
-			from documents
-			order by id
-			limit ?
-		`
-		args = []any{opts.Limit}
+	var tags []string
+	if opts.Tag != "" {
+		tags = []string{opts.Tag}
 	}
+	tagCondition, args := tagFilter("d.id", tags)
+
+	query := `
+		select d.id, d.created, d.updated, d.content, d.metadata, d.contentHash, d.simHash, d.externalID, d.deleted,
+			coalesce(s.content, '') as summary
+		from documents d
+			left join document_summaries s on (s.documentID = d.id)
+		where d.id > ? and d.deleted is null and ` + tagCondition + `
+		order by d.id
+		limit ?
+	`
+	args = append([]any{opts.Cursor}, args...)
+	args = append(args, opts.Limit)
 
 	var docs []model.Document
 	if err := d.H.Select(ctx, &docs, query, args...); err != nil {
@@ -111,11 +205,14 @@ func (d *Database) ListDocuments(ctx context.Context, opts ListDocumentsOptions)
 	return docs, nil
 }
 
-func (d *Database) GetDocument(ctx context.Context, id model.ID) (model.Document, error) {
+func (d *Database) GetDocument(ctx context.Context, id model.ID) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocument", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
 	query := `
-		select id, created, updated, content
+		select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
 		from documents
-		where id = ?
+		where id = ? and deleted is null
 	`
 
 	var doc model.Document
@@ -129,11 +226,18 @@ func (d *Database) GetDocument(ctx context.Context, id model.ID) (model.Document
 	return doc, nil
 }
 
-func (d *Database) UpdateDocument(ctx context.Context, doc model.Document, chunks []model.Chunk) (model.Document, error) {
-	err := d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+// UpdateDocument content and metadata, recording the previous content and metadata as a [model.DocumentVersion].
+func (d *Database) UpdateDocument(ctx context.Context, doc model.Document, chunks []model.Chunk, change model.Change) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.UpdateDocument", trace.WithAttributes(
+		attribute.String("document.id", string(doc.ID)),
+		attribute.Int("chunks", len(chunks)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
 		var exists bool
 		query := `
-			select exists(select 1 from documents where id = ?)
+			select exists(select 1 from documents where id = ? and deleted is null)
 		`
 		if err := tx.Get(ctx, &exists, query, doc.ID); err != nil {
 			return errors.Wrap(err, "error checking if document exists")
@@ -143,14 +247,18 @@ func (d *Database) UpdateDocument(ctx context.Context, doc model.Document, chunk
 			return model.ErrorDocumentNotFound
 		}
 
+		if err := d.saveVersion(ctx, tx, doc, change); err != nil {
+			return errors.Wrap(err, "error saving document version")
+		}
+
 		query = `
 			update documents
-			set content = ?
+			set content = ?, metadata = ?, contentHash = ?, simHash = ?
 			where id = ?
-			returning *
+			returning id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
 		`
 
-		if err := tx.Get(ctx, &doc, query, doc.Content, doc.ID); err != nil {
+		if err := tx.Get(ctx, &doc, query, doc.Content, doc.Metadata, dedup.ContentHash(doc.Content), int64(dedup.SimHash(doc.Content)), doc.ID); err != nil {
 			return errors.Wrap(err, "error updating document")
 		}
 
@@ -164,11 +272,16 @@ func (d *Database) UpdateDocument(ctx context.Context, doc model.Document, chunk
 	return doc, err
 }
 
-func (d *Database) DeleteDocument(ctx context.Context, id model.ID) error {
+// DeleteDocument by moving it to the trash, from where it can be restored with [Database.RestoreDocument]
+// until it's purged with [Database.PurgeDocument] or [Database.PurgeDocuments].
+func (d *Database) DeleteDocument(ctx context.Context, id model.ID) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.DeleteDocument", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
 	return d.H.InTransaction(ctx, func(tx *sql.Tx) error {
 		var exists bool
 		query := `
-			select exists(select 1 from documents where id = ?)
+			select exists(select 1 from documents where id = ? and deleted is null)
 		`
 		if err := tx.Get(ctx, &exists, query, id); err != nil {
 			return errors.Wrap(err, "error checking if document exists")
@@ -179,7 +292,8 @@ func (d *Database) DeleteDocument(ctx context.Context, id model.ID) error {
 		}
 
 		query = `
-			delete from documents
+			update documents
+			set deleted = strftime('%Y-%m-%dT%H:%M:%fZ')
 			where id = ?
 		`
 		if err := tx.Exec(ctx, query, id); err != nil {
@@ -190,7 +304,10 @@ func (d *Database) DeleteDocument(ctx context.Context, id model.ID) error {
 	})
 }
 
-func (d *Database) GetDocumentChunks(ctx context.Context, docID model.ID) ([]model.Chunk, error) {
+func (d *Database) GetDocumentChunks(ctx context.Context, docID model.ID) (_ []model.Chunk, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentChunks", trace.WithAttributes(attribute.String("document.id", string(docID))))
+	defer func() { tracing.End(span, err) }()
+
 	query := `
 		select c.id, c.created, c.updated, c.documentID, c."index", c.content, e.embedding
 		from chunks c
@@ -200,8 +317,7 @@ func (d *Database) GetDocumentChunks(ctx context.Context, docID model.ID) ([]mod
 	`
 
 	var chunks []model.Chunk
-	err := d.H.Select(ctx, &chunks, query, docID)
-	if err != nil {
+	if err := d.H.Select(ctx, &chunks, query, docID); err != nil {
 		return nil, errors.Wrap(err, "error getting document chunks with embeddings")
 	}
 
@@ -19,7 +19,8 @@ func TestDocuments_CRUD(t *testing.T) {
 
 		// Create
 		doc := model.Document{
-			Content: "Test document content",
+			Content:  "Test document content",
+			Metadata: model.Metadata{"filename": "test.md"},
 		}
 
 		chunks, err := doc.Chunk(t.Context(), ai.EmbedString)
@@ -39,6 +40,7 @@ func TestDocuments_CRUD(t *testing.T) {
 		is.Equal(t, created.Created, retrieved.Created)
 		is.Equal(t, created.Updated, retrieved.Updated)
 		is.Equal(t, created.Content, retrieved.Content)
+		is.Equal(t, "test.md", retrieved.Metadata["filename"])
 
 		// Update
 		doc.ID = created.ID
@@ -47,7 +49,7 @@ func TestDocuments_CRUD(t *testing.T) {
 		chunks, err = doc.Chunk(t.Context(), ai.EmbedString)
 		is.NotError(t, err)
 
-		updated, err := db.UpdateDocument(t.Context(), doc, chunks)
+		updated, err := db.UpdateDocument(t.Context(), doc, chunks, model.Change{})
 		is.NotError(t, err)
 		is.Equal(t, created.ID, updated.ID)
 		is.Equal(t, created.Created, updated.Created)
@@ -0,0 +1,187 @@
+package sql
+
+import (
+	"context"
+	"maps"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/dedup"
+	"app/model"
+	"app/tracing"
+)
+
+// GetDocumentByContentHash returns the oldest document with the given content hash.
+func (d *Database) GetDocumentByContentHash(ctx context.Context, hash string) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentByContentHash")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+		from documents
+		where contentHash = ? and deleted is null
+		order by created, id
+		limit 1
+	`
+
+	var doc model.Document
+	if err := d.H.Get(ctx, &doc, query, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return doc, model.ErrorDocumentNotFound
+		}
+		return doc, errors.Wrap(err, "error getting document by content hash")
+	}
+
+	return doc, nil
+}
+
+// ListFingerprints of all documents, for near-duplicate detection with [dedup.Groups].
+func (d *Database) ListFingerprints(ctx context.Context) (_ []dedup.Fingerprint, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListFingerprints")
+	defer func() { tracing.End(span, err) }()
+
+	var rows []struct {
+		ID      model.ID
+		SimHash int64 `db:"simHash"`
+	}
+	if err := d.H.Select(ctx, &rows, "select id, simHash from documents where deleted is null order by id"); err != nil {
+		return nil, errors.Wrap(err, "error listing fingerprints")
+	}
+
+	fingerprints := make([]dedup.Fingerprint, len(rows))
+	for i, r := range rows {
+		fingerprints[i] = dedup.Fingerprint{ID: r.ID, SimHash: uint64(r.SimHash)}
+	}
+
+	return fingerprints, nil
+}
+
+// BackfillDocumentHashes for documents created before content hashes were stored.
+// Returns the number of documents updated.
+func (d *Database) BackfillDocumentHashes(ctx context.Context) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "sql.BackfillDocumentHashes")
+	defer func() { tracing.End(span, err) }()
+
+	var count int
+	for {
+		var docs []model.Document
+		query := `
+			select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+			from documents
+			where contentHash = ''
+			limit 100
+		`
+		if err := d.H.Select(ctx, &docs, query); err != nil {
+			return count, errors.Wrap(err, "error listing documents without hashes")
+		}
+
+		if len(docs) == 0 {
+			break
+		}
+
+		err := d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+			for _, doc := range docs {
+				query := `
+					update documents
+					set contentHash = ?, simHash = ?
+					where id = ?
+				`
+				if err := tx.Exec(ctx, query, dedup.ContentHash(doc.Content), int64(dedup.SimHash(doc.Content)), doc.ID); err != nil {
+					return errors.Wrap(err, "error updating document hashes")
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+
+		count += len(docs)
+	}
+
+	span.SetAttributes(attribute.Int("documents", count))
+	return count, nil
+}
+
+// MergeDocuments by moving the duplicates to the trash and keeping one document.
+// Metadata keys from the duplicates that the kept document doesn't have are added to it,
+// and so is the first external ID of the duplicates, if the kept document doesn't have one.
+// The duplicate the external ID is taken from loses it, so it can't be restored with the same external ID.
+func (d *Database) MergeDocuments(ctx context.Context, keep model.ID, duplicates []model.ID) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.MergeDocuments", trace.WithAttributes(
+		attribute.String("document.id", string(keep)),
+		attribute.Int("duplicates", len(duplicates)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	var doc model.Document
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		query := `
+			select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+			from documents
+			where id = ? and deleted is null
+		`
+		if err := tx.Get(ctx, &doc, query, keep); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return model.ErrorDocumentNotFound
+			}
+			return errors.Wrap(err, "error getting document to keep")
+		}
+
+		metadata := model.Metadata{}
+		externalID := doc.ExternalID
+		for _, id := range duplicates {
+			if id == keep {
+				continue
+			}
+
+			var duplicate model.Document
+			query := `
+				select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+				from documents
+				where id = ? and deleted is null
+			`
+			if err := tx.Get(ctx, &duplicate, query, id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return model.ErrorDocumentNotFound
+				}
+				return errors.Wrap(err, "error getting duplicate document")
+			}
+			maps.Copy(metadata, duplicate.Metadata)
+			duplicateExternalID := duplicate.ExternalID
+			if externalID == "" {
+				externalID = duplicate.ExternalID
+				duplicateExternalID = ""
+			}
+
+			query = `
+				update documents
+				set deleted = strftime('%Y-%m-%dT%H:%M:%fZ'), externalID = ?
+				where id = ?
+			`
+			if err := tx.Exec(ctx, query, duplicateExternalID, id); err != nil {
+				return errors.Wrap(err, "error deleting duplicate document")
+			}
+		}
+
+		// The kept document's own metadata wins
+		maps.Copy(metadata, doc.Metadata)
+
+		query = `
+			update documents
+			set metadata = ?, externalID = ?
+			where id = ?
+			returning id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+		`
+		if err := tx.Get(ctx, &doc, query, metadata, externalID, keep); err != nil {
+			return errors.Wrap(err, "error updating document metadata")
+		}
+
+		return nil
+	})
+
+	return doc, err
+}
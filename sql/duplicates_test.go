@@ -0,0 +1,176 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/dedup"
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDatabase_GetDocumentByContentHash(t *testing.T) {
+	t.Run("gets the oldest document with the content hash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		first, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		_, err = db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		doc, err := db.GetDocumentByContentHash(t.Context(), dedup.ContentHash("Sheep"))
+		is.NotError(t, err)
+		is.Equal(t, first.ID, doc.ID)
+		is.Equal(t, dedup.ContentHash("Sheep"), doc.ContentHash)
+	})
+
+	t.Run("errors if there is no document with the content hash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.GetDocumentByContentHash(t.Context(), dedup.ContentHash("Sheep"))
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+}
+
+func TestDatabase_CreateDocumentIfNew(t *testing.T) {
+	t.Run("creates a document, then returns it instead of creating a duplicate", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, created, err := db.CreateDocumentIfNew(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		is.True(t, created)
+
+		existing, created, err := db.CreateDocumentIfNew(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		is.True(t, !created)
+		is.Equal(t, doc.ID, existing.ID)
+	})
+
+	t.Run("creates a document if the duplicate is in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		_, created, err := db.CreateDocumentIfNew(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		is.True(t, created)
+	})
+}
+
+func TestDatabase_CreateDocumentsIfNew(t *testing.T) {
+	t.Run("creates new documents and returns existing ones in place of duplicates", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		existing, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		docs, created, err := db.CreateDocumentsIfNew(t.Context(), []model.Document{{Content: "Sheep"}, {Content: "Goats"}}, [][]model.Chunk{nil, nil})
+		is.NotError(t, err)
+		is.EqualSlice(t, []bool{false, true}, created)
+		is.Equal(t, existing.ID, docs[0].ID)
+		is.Equal(t, "Goats", docs[1].Content)
+	})
+}
+
+func TestDatabase_ListFingerprints(t *testing.T) {
+	t.Run("lists the sim hash of each document", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep are fluffy and goats are not."}, nil)
+		is.NotError(t, err)
+
+		fingerprints, err := db.ListFingerprints(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(fingerprints))
+		is.Equal(t, doc.ID, fingerprints[0].ID)
+		is.Equal(t, dedup.SimHash("Sheep are fluffy and goats are not."), fingerprints[0].SimHash)
+	})
+}
+
+func TestDatabase_BackfillDocumentHashes(t *testing.T) {
+	t.Run("sets hashes for documents without them", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.H.Exec(t.Context(), "update documents set contentHash = '', simHash = 0")
+		is.NotError(t, err)
+
+		n, err := db.BackfillDocumentHashes(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, n)
+
+		doc, err = db.GetDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, dedup.ContentHash("Sheep"), doc.ContentHash)
+
+		n, err = db.BackfillDocumentHashes(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 0, n)
+	})
+}
+
+func TestDatabase_MergeDocuments(t *testing.T) {
+	t.Run("moves duplicates to the trash and merges their metadata into the kept document", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		keep, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep", Metadata: model.Metadata{"source": "a"}}, nil)
+		is.NotError(t, err)
+		duplicate, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep", Metadata: model.Metadata{"source": "b", "author": "me"}}, nil)
+		is.NotError(t, err)
+
+		doc, err := db.MergeDocuments(t.Context(), keep.ID, []model.ID{duplicate.ID})
+		is.NotError(t, err)
+		is.Equal(t, keep.ID, doc.ID)
+		is.Equal(t, "a", doc.Metadata["source"])
+		is.Equal(t, "me", doc.Metadata["author"])
+
+		_, err = db.GetDocument(t.Context(), duplicate.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		deleted, err := db.ListDeletedDocuments(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(deleted))
+		is.Equal(t, duplicate.ID, deleted[0].ID)
+
+		err = db.RestoreDocument(t.Context(), duplicate.ID)
+		is.NotError(t, err)
+	})
+
+	t.Run("moves the external ID of a duplicate to the kept document", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		keep, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		duplicate, _, err := db.UpsertDocumentByExternalID(t.Context(), model.Document{Content: "Sheep", ExternalID: "sheep"}, nil, model.Change{})
+		is.NotError(t, err)
+
+		doc, err := db.MergeDocuments(t.Context(), keep.ID, []model.ID{duplicate.ID})
+		is.NotError(t, err)
+		is.Equal(t, "sheep", doc.ExternalID)
+
+		deleted, err := db.ListDeletedDocuments(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(deleted))
+		is.Equal(t, "", deleted[0].ExternalID)
+	})
+
+	t.Run("errors and deletes nothing if a duplicate does not exist", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		keep, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		duplicate, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		_, err = db.MergeDocuments(t.Context(), keep.ID, []model.ID{duplicate.ID, "d_unknown"})
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		_, err = db.GetDocument(t.Context(), duplicate.ID)
+		is.NotError(t, err)
+	})
+}
@@ -0,0 +1,123 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/dedup"
+	"app/model"
+	"app/tracing"
+)
+
+// UpsertDocumentByExternalID creates the document with its external ID if there's no document with it,
+// or updates the content and metadata of the document that has it, like [Database.UpdateDocument].
+// External IDs are unique in a collection, and all documents are in [model.DefaultCollection] for now.
+// A document with the external ID in the trash is restored.
+// Returns whether the document was created.
+func (d *Database) UpsertDocumentByExternalID(ctx context.Context, doc model.Document, chunks []model.Chunk, change model.Change) (_ model.Document, created bool, err error) {
+	if doc.ExternalID == "" {
+		panic("external ID cannot be empty")
+	}
+
+	ctx, span := tracer.Start(ctx, "sql.UpsertDocumentByExternalID", trace.WithAttributes(
+		attribute.String("document.externalID", doc.ExternalID),
+		attribute.Int("chunks", len(chunks)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		contentHash, simHash := dedup.ContentHash(doc.Content), int64(dedup.SimHash(doc.Content))
+
+		// Inserting first makes the transaction a write transaction right away, so concurrent upserts with the same
+		// external ID are serialized, and the later ones update the document the first one created
+		query := `
+			insert into documents (content, metadata, contentHash, simHash, collection, externalID)
+			values (?, ?, ?, ?, ?, ?)
+			on conflict (collection, externalID) where externalID != '' do nothing
+			returning id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+		`
+		err := tx.Get(ctx, &doc, query, doc.Content, doc.Metadata, contentHash, simHash, model.DefaultCollection, doc.ExternalID)
+		switch {
+		case err == nil:
+			created = true
+
+		case errors.Is(err, sql.ErrNoRows):
+			if err := tx.Get(ctx, &doc.ID, "select id from documents where collection = ? and externalID = ?", model.DefaultCollection, doc.ExternalID); err != nil {
+				return errors.Wrap(err, "error getting document by external ID")
+			}
+
+			if err := d.saveVersion(ctx, tx, doc, change); err != nil {
+				return errors.Wrap(err, "error saving document version")
+			}
+
+			query := `
+				update documents
+				set content = ?, metadata = ?, contentHash = ?, simHash = ?, deleted = null
+				where id = ?
+				returning id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+			`
+			if err := tx.Get(ctx, &doc, query, doc.Content, doc.Metadata, contentHash, simHash, doc.ID); err != nil {
+				return errors.Wrap(err, "error updating document")
+			}
+
+		default:
+			return errors.Wrap(err, "error creating document")
+		}
+
+		if err := d.saveChunks(ctx, tx, doc.ID, chunks); err != nil {
+			return errors.Wrap(err, "error saving chunks")
+		}
+
+		return nil
+	})
+
+	span.SetAttributes(attribute.Bool("created", created))
+	return doc, created, err
+}
+
+func (d *Database) GetDocumentByExternalID(ctx context.Context, externalID string) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentByExternalID", trace.WithAttributes(attribute.String("document.externalID", externalID)))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+		from documents
+		where collection = ? and externalID = ? and externalID != '' and deleted is null
+	`
+
+	var doc model.Document
+	if err := d.H.Get(ctx, &doc, query, model.DefaultCollection, externalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return doc, model.ErrorDocumentNotFound
+		}
+		return doc, errors.Wrap(err, "error getting document by external ID")
+	}
+
+	return doc, nil
+}
+
+func (d *Database) DeleteDocumentByExternalID(ctx context.Context, externalID string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.DeleteDocumentByExternalID", trace.WithAttributes(attribute.String("document.externalID", externalID)))
+	defer func() { tracing.End(span, err) }()
+
+	var ids []model.ID
+	query := `
+		update documents
+		set deleted = strftime('%Y-%m-%dT%H:%M:%fZ')
+		where collection = ? and externalID = ? and externalID != '' and deleted is null
+		returning id
+	`
+	if err := d.H.Select(ctx, &ids, query, model.DefaultCollection, externalID); err != nil {
+		return errors.Wrap(err, "error deleting document by external ID")
+	}
+
+	if len(ids) == 0 {
+		return model.ErrorDocumentNotFound
+	}
+
+	return nil
+}
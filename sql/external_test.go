@@ -0,0 +1,75 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestDatabase_UpsertDocumentByExternalID(t *testing.T) {
+	t.Run("creates a document, then updates it", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, created, err := db.UpsertDocumentByExternalID(t.Context(), model.Document{Content: "Sheep", ExternalID: "123"}, nil, model.Change{})
+		is.NotError(t, err)
+		is.True(t, created)
+		is.Equal(t, "123", doc.ExternalID)
+
+		updated, created, err := db.UpsertDocumentByExternalID(t.Context(), model.Document{
+			Content:    "Goats",
+			Metadata:   model.Metadata{"source": "test"},
+			ExternalID: "123",
+		}, nil, model.Change{})
+		is.NotError(t, err)
+		is.True(t, !created)
+		is.Equal(t, doc.ID, updated.ID)
+		is.Equal(t, "Goats", updated.Content)
+		is.Equal(t, "test", updated.Metadata["source"])
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+
+		versions, err := db.ListDocumentVersions(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(versions))
+		is.Equal(t, "Sheep", versions[0].Content)
+	})
+}
+
+func TestDatabase_GetDocumentByExternalID(t *testing.T) {
+	t.Run("errors if there is no document with the external ID", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		_, err = db.GetDocumentByExternalID(t.Context(), "")
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		_, err = db.GetDocumentByExternalID(t.Context(), "123")
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+}
+
+func TestDatabase_DeleteDocumentByExternalID(t *testing.T) {
+	t.Run("deletes the document with the external ID", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, _, err := db.UpsertDocumentByExternalID(t.Context(), model.Document{Content: "Sheep", ExternalID: "123"}, nil, model.Change{})
+		is.NotError(t, err)
+
+		err = db.DeleteDocumentByExternalID(t.Context(), "123")
+		is.NotError(t, err)
+
+		_, err = db.GetDocument(t.Context(), doc.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		err = db.DeleteDocumentByExternalID(t.Context(), "123")
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+}
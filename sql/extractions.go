@@ -0,0 +1,215 @@
+package sql
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// ListUnextractedChunks lists chunks that entities, key phrases, and topics haven't been extracted from yet,
+// oldest first. Summary chunks and chunks of documents in the trash are left out.
+func (d *Database) ListUnextractedChunks(ctx context.Context, limit int) (_ []model.Chunk, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListUnextractedChunks", trace.WithAttributes(attribute.Int("limit", limit)))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select c.id, c.created, c.updated, c.documentID, c."index", c.content
+		from chunks c
+			join documents d on (c.documentID = d.id)
+			left join chunk_extractions e on (e.chunkID = c.id)
+		where e.chunkID is null and c."index" != ? and d.deleted is null
+		order by c.created, c.id
+		limit ?
+	`
+
+	var chunks []model.Chunk
+	if err := d.H.Select(ctx, &chunks, query, model.SummaryChunkIndex, limit); err != nil {
+		return nil, errors.Wrap(err, "error listing unextracted chunks")
+	}
+
+	return chunks, nil
+}
+
+// SaveChunkExtraction with the entities, key phrases, and topics of the chunk.
+// If the chunk has been replaced in the meantime, nothing is saved.
+func (d *Database) SaveChunkExtraction(ctx context.Context, c model.Chunk, e model.Extraction) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.SaveChunkExtraction", trace.WithAttributes(
+		attribute.String("chunk.id", string(c.ID)),
+		attribute.Int("entities", len(e.Entities)),
+		attribute.Int("keyPhrases", len(e.KeyPhrases)),
+		attribute.Int("topics", len(e.Topics)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	return d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from chunks where id = ?)", c.ID); err != nil {
+			return errors.Wrap(err, "error checking if chunk exists")
+		}
+
+		if !exists {
+			return nil
+		}
+
+		query := `
+			insert into chunk_extractions (chunkID, prompt)
+			values (?, ?)
+			on conflict (chunkID) do update set prompt = excluded.prompt
+		`
+		if err := tx.Exec(ctx, query, c.ID, e.Prompt); err != nil {
+			return errors.Wrap(err, "error saving chunk extraction")
+		}
+
+		for _, entity := range e.Entities {
+			query := `insert or ignore into entities (chunkID, documentID, name, key, type) values (?, ?, ?, ?, ?)`
+			if err := tx.Exec(ctx, query, c.ID, c.DocumentID, entity.Name, entityKey(entity.Name), entity.Type); err != nil {
+				return errors.Wrap(err, "error saving entity")
+			}
+		}
+
+		for _, phrase := range e.KeyPhrases {
+			query := `insert or ignore into key_phrases (chunkID, documentID, phrase) values (?, ?, ?)`
+			if err := tx.Exec(ctx, query, c.ID, c.DocumentID, phrase); err != nil {
+				return errors.Wrap(err, "error saving key phrase")
+			}
+		}
+
+		for _, topic := range e.Topics {
+			query := `insert or ignore into topics (chunkID, documentID, topic) values (?, ?, ?)`
+			if err := tx.Exec(ctx, query, c.ID, c.DocumentID, topic); err != nil {
+				return errors.Wrap(err, "error saving topic")
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListEntities with the number of documents that mention each, most mentioned first.
+// Documents in the trash are not counted.
+func (d *Database) ListEntities(ctx context.Context, limit int) (_ []model.EntityCount, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListEntities", trace.WithAttributes(attribute.Int("limit", limit)))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select min(e.name) as name, e.type, count(distinct e.documentID) as count
+		from entities e
+			join documents d on (e.documentID = d.id)
+		where d.deleted is null
+		group by e.key, e.type
+		order by count desc, name
+		limit ?
+	`
+
+	var entities []model.EntityCount
+	if err := d.H.Select(ctx, &entities, query, limit); err != nil {
+		return nil, errors.Wrap(err, "error listing entities")
+	}
+
+	return entities, nil
+}
+
+// ListEntityDocuments lists documents that mention the entity, regardless of case and entity type,
+// with the most mentions first. Listed documents include their summary, like [Database.ListDocuments].
+func (d *Database) ListEntityDocuments(ctx context.Context, name string) (_ []model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListEntityDocuments", trace.WithAttributes(attribute.String("entity.name", name)))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select d.id, d.created, d.updated, d.content, d.metadata, d.contentHash, d.simHash, d.externalID, d.deleted,
+			coalesce(s.content, '') as summary
+		from (
+			select documentID, count(*) as mentions
+			from entities
+			where key = ?
+			group by documentID
+		) as m
+			join documents d on (m.documentID = d.id)
+			left join document_summaries s on (s.documentID = d.id)
+		where d.deleted is null
+		order by m.mentions desc, d.id
+	`
+
+	var docs []model.Document
+	if err := d.H.Select(ctx, &docs, query, entityKey(name)); err != nil {
+		return nil, errors.Wrap(err, "error listing entity documents")
+	}
+
+	return docs, nil
+}
+
+// GetFacets of the documents, with the entities and topics that are most common among them.
+func (d *Database) GetFacets(ctx context.Context, ids []model.ID, limit int) (_ model.Facets, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetFacets", trace.WithAttributes(attribute.Int("documents", len(ids))))
+	defer func() { tracing.End(span, err) }()
+
+	var facets model.Facets
+	if len(ids) == 0 {
+		return facets, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, limit)
+
+	query := `
+		select min(name) as name, type, count(distinct documentID) as count
+		from entities
+		where documentID in (` + placeholders + `)
+		group by key, type
+		order by count desc, name
+		limit ?
+	`
+	if err := d.H.Select(ctx, &facets.Entities, query, args...); err != nil {
+		return facets, errors.Wrap(err, "error getting entity facets")
+	}
+
+	query = `
+		select topic, count(distinct documentID) as count
+		from topics
+		where documentID in (` + placeholders + `)
+		group by topic
+		order by count desc, topic
+		limit ?
+	`
+	if err := d.H.Select(ctx, &facets.Topics, query, args...); err != nil {
+		return facets, errors.Wrap(err, "error getting topic facets")
+	}
+
+	return facets, nil
+}
+
+// entityFilter is a SQL condition for the document ID column mentioning all the entities, regardless of case,
+// and the query arguments for it. Returns "1 = 1" if there are no entities.
+func entityFilter(column string, names []string) (string, []any) {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, entityKey(name))
+	}
+	return allValuesFilter(column, "entities", "key", keys)
+}
+
+// entityKey for finding entities by name regardless of case.
+func entityKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// topicFilter is a SQL condition for the document ID column having all the topics, and the query arguments for it.
+// Returns "1 = 1" if there are no topics.
+func topicFilter(column string, topics []string) (string, []any) {
+	normalized := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(topic)))
+	}
+	return allValuesFilter(column, "topics", "topic", normalized)
+}
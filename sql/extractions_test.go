@@ -0,0 +1,112 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestDatabase_ChunkExtractions(t *testing.T) {
+	t.Run("saves extractions, and lists entities, entity documents, and facets", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		for _, content := range []string{"Ada Lovelace lived in London.", "Charles Babbage designed the Analytical Engine."} {
+			doc := model.Document{Content: content}
+			chunks, err := doc.Chunk(t.Context(), ai.EmbedString)
+			is.NotError(t, err)
+			_, err = db.CreateDocument(t.Context(), doc, chunks)
+			is.NotError(t, err)
+		}
+
+		chunks, err := db.ListUnextractedChunks(t.Context(), 10)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(chunks))
+		londonDocID, otherDocID := chunks[0].DocumentID, chunks[1].DocumentID
+
+		err = db.SaveChunkExtraction(t.Context(), chunks[0], model.Extraction{
+			Entities: []model.Entity{
+				{Name: "Ada Lovelace", Type: model.EntityTypePerson},
+				{Name: "London", Type: model.EntityTypePlace},
+			},
+			KeyPhrases: []string{"lived in london"},
+			Topics:     []string{"history"},
+		})
+		is.NotError(t, err)
+		err = db.SaveChunkExtraction(t.Context(), chunks[1], model.Extraction{
+			Entities: []model.Entity{{Name: "ada lovelace", Type: model.EntityTypePerson}},
+			Topics:   []string{"computing", "history"},
+		})
+		is.NotError(t, err)
+
+		chunks, err = db.ListUnextractedChunks(t.Context(), 10)
+		is.NotError(t, err)
+		is.Equal(t, 0, len(chunks))
+
+		entities, err := db.ListEntities(t.Context(), 10)
+		is.NotError(t, err)
+		is.EqualSlice(t, []model.EntityCount{
+			{Name: "Ada Lovelace", Type: model.EntityTypePerson, Count: 2},
+			{Name: "London", Type: model.EntityTypePlace, Count: 1},
+		}, entities)
+
+		entityDocs, err := db.ListEntityDocuments(t.Context(), "ADA LOVELACE")
+		is.NotError(t, err)
+		is.Equal(t, 2, len(entityDocs))
+
+		entityDocs, err = db.ListEntityDocuments(t.Context(), "london")
+		is.NotError(t, err)
+		is.Equal(t, 1, len(entityDocs))
+		is.Equal(t, londonDocID, entityDocs[0].ID)
+
+		facets, err := db.GetFacets(t.Context(), []model.ID{otherDocID}, 10)
+		is.NotError(t, err)
+		is.EqualSlice(t, []model.EntityCount{{Name: "ada lovelace", Type: model.EntityTypePerson, Count: 1}}, facets.Entities)
+		is.EqualSlice(t, []model.TopicCount{{Topic: "computing", Count: 1}, {Topic: "history", Count: 1}}, facets.Topics)
+	})
+
+	t.Run("filters search by entities and topics", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		for _, content := range []string{"Ada Lovelace wrote about the engine.", "Charles Babbage built the engine."} {
+			doc := model.Document{Content: content}
+			chunks, err := doc.Chunk(t.Context(), ai.EmbedString)
+			is.NotError(t, err)
+			_, err = db.CreateDocument(t.Context(), doc, chunks)
+			is.NotError(t, err)
+		}
+
+		chunks, err := db.ListUnextractedChunks(t.Context(), 10)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(chunks))
+
+		err = db.SaveChunkExtraction(t.Context(), chunks[0], model.Extraction{
+			Entities: []model.Entity{{Name: "Ada Lovelace", Type: model.EntityTypePerson}},
+			Topics:   []string{"computing"},
+		})
+		is.NotError(t, err)
+		err = db.SaveChunkExtraction(t.Context(), chunks[1], model.Extraction{Topics: []string{"computing"}})
+		is.NotError(t, err)
+
+		embedding, err := ai.EmbedString(t.Context(), "engine")
+		is.NotError(t, err)
+
+		results, err := db.Search(t.Context(), "engine", embedding, sql.SearchOptions{Topics: []string{"Computing"}})
+		is.NotError(t, err)
+		is.Equal(t, 2, len(results))
+
+		results, err = db.Search(t.Context(), "engine", embedding, sql.SearchOptions{
+			Entities: []string{"ada lovelace"},
+			Topics:   []string{"computing"},
+		})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(results))
+		is.Equal(t, chunks[0].ID, results[0].ID)
+	})
+}
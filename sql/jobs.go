@@ -0,0 +1,149 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// CreateJob in the background queue, with the given name and payload, and the ID of the API key it's created with, if any.
+func (d *Database) CreateJob(ctx context.Context, name, payload, apiKey string) (_ model.Job, err error) {
+	ctx, span := tracer.Start(ctx, "sql.CreateJob", trace.WithAttributes(attribute.String("job.name", name)))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		insert into jobs (name, payload, apiKey)
+		values (?, ?, ?)
+		returning *
+	`
+
+	var job model.Job
+	if err := d.H.Get(ctx, &job, query, name, payload, apiKey); err != nil {
+		return job, errors.Wrap(err, "error creating job")
+	}
+
+	return job, nil
+}
+
+func (d *Database) GetJob(ctx context.Context, id model.ID) (_ model.Job, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetJob", trace.WithAttributes(attribute.String("job.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select * from jobs where id = ?
+	`
+
+	var job model.Job
+	if err := d.H.Get(ctx, &job, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return job, model.ErrorJobNotFound
+		}
+		return job, errors.Wrap(err, "error getting job")
+	}
+
+	return job, nil
+}
+
+// ClaimJob marks the oldest pending job as running and returns it.
+// If there are no pending jobs, it returns [model.ErrorJobNotFound].
+func (d *Database) ClaimJob(ctx context.Context) (_ model.Job, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ClaimJob")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		update jobs
+		set status = 'running'
+		where id = (
+			select id from jobs
+			where status = 'pending'
+			order by created, id
+			limit 1
+		)
+		returning *
+	`
+
+	var job model.Job
+	if err := d.H.Get(ctx, &job, query); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return job, model.ErrorJobNotFound
+		}
+		return job, errors.Wrap(err, "error claiming job")
+	}
+
+	return job, nil
+}
+
+// CompleteJob by marking it as done with the given result.
+func (d *Database) CompleteJob(ctx context.Context, id model.ID, result string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.CompleteJob", trace.WithAttributes(attribute.String("job.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		update jobs
+		set status = 'done', result = ?, progress = ''
+		where id = ?
+	`
+	if err := d.H.Exec(ctx, query, result, id); err != nil {
+		return errors.Wrap(err, "error completing job")
+	}
+
+	return nil
+}
+
+// FailJob by marking it as failed with the given error message.
+func (d *Database) FailJob(ctx context.Context, id model.ID, message string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.FailJob", trace.WithAttributes(attribute.String("job.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		update jobs
+		set status = 'failed', error = ?, progress = ''
+		where id = ?
+	`
+	if err := d.H.Exec(ctx, query, message, id); err != nil {
+		return errors.Wrap(err, "error failing job")
+	}
+
+	return nil
+}
+
+// AppendJobProgress to the progress of a running job, see [model.Job].
+// Appending instead of replacing keeps saving progress cheap for jobs that save a little at a time.
+func (d *Database) AppendJobProgress(ctx context.Context, id model.ID, progress string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.AppendJobProgress", trace.WithAttributes(attribute.String("job.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		update jobs
+		set progress = progress || ?
+		where id = ?
+	`
+	if err := d.H.Exec(ctx, query, progress, id); err != nil {
+		return errors.Wrap(err, "error appending job progress")
+	}
+
+	return nil
+}
+
+// ResetRunningJobs back to pending, keeping their progress, so jobs interrupted by a shutdown are run again.
+func (d *Database) ResetRunningJobs(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.ResetRunningJobs")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		update jobs
+		set status = 'pending'
+		where status = 'running'
+	`
+	if err := d.H.Exec(ctx, query); err != nil {
+		return errors.Wrap(err, "error resetting running jobs")
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDatabase_Jobs(t *testing.T) {
+	t.Run("create, claim, and complete a job", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		created, err := db.CreateJob(t.Context(), "test", `{"a":1}`, "")
+		is.NotError(t, err)
+		is.True(t, created.ID != "")
+		is.Equal(t, model.JobStatusPending, created.Status)
+
+		claimed, err := db.ClaimJob(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, created.ID, claimed.ID)
+		is.Equal(t, model.JobStatusRunning, claimed.Status)
+		is.Equal(t, `{"a":1}`, claimed.Payload)
+
+		_, err = db.ClaimJob(t.Context())
+		is.Error(t, model.ErrorJobNotFound, err)
+
+		err = db.CompleteJob(t.Context(), claimed.ID, "result")
+		is.NotError(t, err)
+
+		job, err := db.GetJob(t.Context(), claimed.ID)
+		is.NotError(t, err)
+		is.Equal(t, model.JobStatusDone, job.Status)
+		is.Equal(t, "result", job.Result)
+	})
+
+	t.Run("claims jobs oldest first", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		first, err := db.CreateJob(t.Context(), "test", "", "")
+		is.NotError(t, err)
+		_, err = db.CreateJob(t.Context(), "test", "", "")
+		is.NotError(t, err)
+
+		claimed, err := db.ClaimJob(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, first.ID, claimed.ID)
+	})
+
+	t.Run("fails a job with a message", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		created, err := db.CreateJob(t.Context(), "test", "", "")
+		is.NotError(t, err)
+
+		err = db.FailJob(t.Context(), created.ID, "oh no")
+		is.NotError(t, err)
+
+		job, err := db.GetJob(t.Context(), created.ID)
+		is.NotError(t, err)
+		is.Equal(t, model.JobStatusFailed, job.Status)
+		is.Equal(t, "oh no", job.Error)
+	})
+
+	t.Run("resets running jobs to pending", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.CreateJob(t.Context(), "test", "", "")
+		is.NotError(t, err)
+		claimed, err := db.ClaimJob(t.Context())
+		is.NotError(t, err)
+
+		err = db.ResetRunningJobs(t.Context())
+		is.NotError(t, err)
+
+		job, err := db.GetJob(t.Context(), claimed.ID)
+		is.NotError(t, err)
+		is.Equal(t, model.JobStatusPending, job.Status)
+	})
+
+	t.Run("appends progress, keeps it when resetting, and clears it when completing", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.CreateJob(t.Context(), "test", "", "")
+		is.NotError(t, err)
+		claimed, err := db.ClaimJob(t.Context())
+		is.NotError(t, err)
+
+		err = db.AppendJobProgress(t.Context(), claimed.ID, "a")
+		is.NotError(t, err)
+		err = db.AppendJobProgress(t.Context(), claimed.ID, "b")
+		is.NotError(t, err)
+
+		err = db.ResetRunningJobs(t.Context())
+		is.NotError(t, err)
+
+		claimed, err = db.ClaimJob(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, "ab", claimed.Progress)
+
+		err = db.CompleteJob(t.Context(), claimed.ID, "result")
+		is.NotError(t, err)
+
+		job, err := db.GetJob(t.Context(), claimed.ID)
+		is.NotError(t, err)
+		is.Equal(t, "", job.Progress)
+	})
+
+	t.Run("returns not found for a missing job", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.GetJob(t.Context(), "j_missing")
+		is.Error(t, model.ErrorJobNotFound, err)
+	})
+}
@@ -0,0 +1,91 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"app/model"
+)
+
+var (
+	searchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "app_search_duration_seconds",
+		Help:    "Duration of search phases.",
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"phase"})
+
+	searchResults = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "app_search_results",
+		Help:    "Number of chunks found per search phase.",
+		Buckets: []float64{0, 1, 5, 10, 25, 50, 100, 250},
+	}, []string{"phase"})
+)
+
+var (
+	documentsDesc = prometheus.NewDesc("app_documents", "Number of documents.", nil, nil)
+	chunksDesc    = prometheus.NewDesc("app_chunks", "Number of chunks.", nil, nil)
+	jobsDesc      = prometheus.NewDesc("app_jobs", "Number of background jobs by status. Pending jobs are the queue depth.", []string{"status"}, nil)
+)
+
+// collector of metrics that are queried from the database on each scrape.
+type collector struct {
+	d *Database
+}
+
+// Collector returns a [prometheus.Collector] for document, chunk, and background job totals.
+func (d *Database) Collector() prometheus.Collector {
+	return &collector{d: d}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- documentsDesc
+	ch <- chunksDesc
+	ch <- jobsDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var documents, chunks int
+	if err := c.d.H.Get(ctx, &documents, "select count(*) from documents where deleted is null"); err != nil {
+		c.d.log.Info("Error counting documents for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(documentsDesc, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(documentsDesc, prometheus.GaugeValue, float64(documents))
+	}
+
+	if err := c.d.H.Get(ctx, &chunks, "select count(*) from chunks"); err != nil {
+		c.d.log.Info("Error counting chunks for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(chunksDesc, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(chunksDesc, prometheus.GaugeValue, float64(chunks))
+	}
+
+	var jobCounts []struct {
+		Status model.JobStatus
+		Count  int
+	}
+	if err := c.d.H.Select(ctx, &jobCounts, "select status, count(*) as count from jobs group by status"); err != nil {
+		c.d.log.Info("Error counting jobs for metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(jobsDesc, err)
+		return
+	}
+
+	// Always export all statuses, so the queue depth is zero instead of missing when the queue is empty
+	jobs := map[model.JobStatus]int{
+		model.JobStatusPending: 0,
+		model.JobStatusRunning: 0,
+		model.JobStatusDone:    0,
+		model.JobStatusFailed:  0,
+	}
+	for _, jc := range jobCounts {
+		jobs[jc.Status] = jc.Count
+	}
+	for status, count := range jobs {
+		ch <- prometheus.MustNewConstMetric(jobsDesc, prometheus.GaugeValue, float64(count), string(status))
+	}
+}
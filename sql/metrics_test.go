@@ -0,0 +1,47 @@
+package sql_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDatabase_Collector(t *testing.T) {
+	t.Run("collects document and chunk totals", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.CreateDocument(t.Context(), model.Document{Content: "Test document"}, nil)
+		is.NotError(t, err)
+
+		expected := `
+			# HELP app_documents Number of documents.
+			# TYPE app_documents gauge
+			app_documents 1
+		`
+		err = testutil.CollectAndCompare(db.Collector(), strings.NewReader(expected), "app_documents")
+		is.NotError(t, err)
+	})
+
+	t.Run("collects job totals by status", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.CreateJob(t.Context(), "test", "", "")
+		is.NotError(t, err)
+
+		expected := `
+			# HELP app_jobs Number of background jobs by status. Pending jobs are the queue depth.
+			# TYPE app_jobs gauge
+			app_jobs{status="done"} 0
+			app_jobs{status="failed"} 0
+			app_jobs{status="pending"} 1
+			app_jobs{status="running"} 0
+		`
+		err = testutil.CollectAndCompare(db.Collector(), strings.NewReader(expected), "app_jobs")
+		is.NotError(t, err)
+	})
+}
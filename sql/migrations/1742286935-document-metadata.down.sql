@@ -0,0 +1 @@
+alter table documents drop column metadata;
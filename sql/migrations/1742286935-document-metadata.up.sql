@@ -0,0 +1 @@
+alter table documents add column metadata text not null default '{}';
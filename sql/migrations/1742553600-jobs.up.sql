@@ -0,0 +1,16 @@
+create table jobs (
+  id text primary key default ('j_' || lower(hex(randomblob(16)))),
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  updated text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  name text not null,
+  status text not null default 'pending' check (status in ('pending', 'running', 'done', 'failed')),
+  payload text not null,
+  result text not null default '',
+  error text not null default ''
+) strict;
+
+create trigger jobs_updated_timestamp after update on jobs begin
+  update jobs set updated = strftime('%Y-%m-%dT%H:%M:%fZ') where id = old.id;
+end;
+
+create index jobs_status_created on jobs (status, created);
@@ -0,0 +1,4 @@
+drop index documents_contentHash;
+
+alter table documents drop column simHash;
+alter table documents drop column contentHash;
@@ -0,0 +1,4 @@
+alter table documents add column contentHash text not null default '';
+alter table documents add column simHash integer not null default 0;
+
+create index documents_contentHash on documents (contentHash);
@@ -0,0 +1,4 @@
+drop index documents_collection_externalID;
+
+alter table documents drop column collection;
+alter table documents drop column externalID;
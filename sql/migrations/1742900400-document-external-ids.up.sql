@@ -0,0 +1,7 @@
+alter table documents add column externalID text not null default '';
+
+-- Collection the document is in, which external IDs are unique in. All documents are in the default collection for now,
+-- see model.DefaultCollection
+alter table documents add column collection text not null default 'default';
+
+create unique index documents_collection_externalID on documents (collection, externalID) where externalID != '';
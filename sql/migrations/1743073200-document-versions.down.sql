@@ -0,0 +1,5 @@
+drop trigger documents_revised_timestamp;
+
+alter table documents drop column revised;
+
+drop table document_versions;
@@ -0,0 +1,29 @@
+create table document_versions (
+  documentID text not null references documents (id) on delete cascade,
+  version int not null,
+  created text not null,
+  replaced text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  content text not null,
+  metadata text not null default '{}',
+  author text not null default '',
+  comment text not null default '',
+  primary key (documentID, version)
+) strict;
+
+-- When the content or metadata of the document last changed, null if they haven't since it was created.
+-- It's when the current revision was written, which the updated column isn't, since that changes on any update.
+alter table documents add column revised text;
+
+create trigger documents_revised_timestamp after update of content, metadata on documents
+when old.content is not new.content or old.metadata is not new.metadata begin
+  update documents set revised = strftime('%Y-%m-%dT%H:%M:%fZ') where id = old.id;
+end;
+
+-- Backfill from the updated timestamp, which is the best there is, without the update bumping it
+drop trigger documents_updated_timestamp;
+
+update documents set revised = updated where updated != created;
+
+create trigger documents_updated_timestamp after update on documents begin
+  update documents set updated = strftime('%Y-%m-%dT%H:%M:%fZ') where id = old.id;
+end;
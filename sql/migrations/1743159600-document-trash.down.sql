@@ -0,0 +1,3 @@
+drop index documents_deleted;
+
+alter table documents drop column deleted;
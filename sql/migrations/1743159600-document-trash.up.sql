@@ -0,0 +1,3 @@
+alter table documents add column deleted text;
+
+create index documents_deleted on documents (deleted) where deleted is not null;
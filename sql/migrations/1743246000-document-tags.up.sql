@@ -0,0 +1,8 @@
+create table document_tags (
+  documentID text not null references documents (id) on delete cascade,
+  tag text not null,
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  primary key (documentID, tag)
+) strict;
+
+create index document_tags_tag_documentID on document_tags (tag, documentID);
@@ -0,0 +1,3 @@
+delete from chunks where "index" = -1;
+
+drop table document_summaries;
@@ -0,0 +1,6 @@
+create table document_summaries (
+  documentID text primary key references documents (id) on delete cascade,
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  content text not null,
+  contentHash text not null
+) strict;
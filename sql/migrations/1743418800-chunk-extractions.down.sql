@@ -0,0 +1,4 @@
+drop table topics;
+drop table key_phrases;
+drop table entities;
+drop table chunk_extractions;
@@ -0,0 +1,35 @@
+-- chunks that entities, key phrases, and topics have been extracted from, even if nothing was found
+create table chunk_extractions (
+  chunkID text primary key references chunks (id) on delete cascade,
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ'))
+) strict;
+
+create table entities (
+  chunkID text not null references chunks (id) on delete cascade,
+  documentID text not null references documents (id) on delete cascade,
+  name text not null,
+  -- the lowercased name, for finding entities regardless of case
+  key text not null,
+  type text not null check (type in ('person', 'place', 'organization')),
+  primary key (chunkID, key, type)
+) strict;
+
+create index entities_key on entities (key, documentID);
+
+create table key_phrases (
+  chunkID text not null references chunks (id) on delete cascade,
+  documentID text not null references documents (id) on delete cascade,
+  phrase text not null,
+  primary key (chunkID, phrase)
+) strict;
+
+create index key_phrases_phrase on key_phrases (phrase, documentID);
+
+create table topics (
+  chunkID text not null references chunks (id) on delete cascade,
+  documentID text not null references documents (id) on delete cascade,
+  topic text not null,
+  primary key (chunkID, topic)
+) strict;
+
+create index topics_topic on topics (topic, documentID);
@@ -0,0 +1,26 @@
+create table conversations (
+  id text primary key default ('cv_' || lower(hex(randomblob(16)))),
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  updated text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  title text not null default '',
+  -- summary of the oldest messages, which no longer fit in the model context
+  summary text not null default '',
+  -- number of oldest messages included in the summary
+  summarizedMessages int not null default 0
+) strict;
+
+create trigger conversations_updated_timestamp after update on conversations begin
+  update conversations set updated = strftime('%Y-%m-%dT%H:%M:%fZ') where id = old.id;
+end;
+
+create index conversations_updated on conversations (updated);
+
+create table messages (
+  id text primary key default ('m_' || lower(hex(randomblob(16)))),
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  conversationID text not null references conversations (id) on delete cascade,
+  role text not null check (role in ('user', 'assistant')),
+  content text not null
+) strict;
+
+create index messages_conversationID on messages (conversationID);
@@ -0,0 +1 @@
+alter table messages drop column citations;
@@ -0,0 +1,2 @@
+-- citations of the sources in assistant messages, as a JSON array
+alter table messages add column citations text not null default '[]';
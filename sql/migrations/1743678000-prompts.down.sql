@@ -0,0 +1,3 @@
+alter table chunk_extractions drop column prompt;
+alter table document_summaries drop column prompt;
+alter table messages drop column prompt;
@@ -0,0 +1,4 @@
+-- IDs of the prompt templates that produced generated content, like reply@v2, to compare prompt versions
+alter table messages add column prompt text not null default '';
+alter table document_summaries add column prompt text not null default '';
+alter table chunk_extractions add column prompt text not null default '';
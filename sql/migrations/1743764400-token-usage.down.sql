@@ -0,0 +1,2 @@
+drop table usage_quotas;
+drop table token_usage;
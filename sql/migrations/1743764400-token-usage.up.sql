@@ -0,0 +1,28 @@
+-- Tokens used in calls to the model servers, per request, operation, and model
+create table token_usage (
+  id text primary key default ('tu_' || lower(hex(randomblob(16)))),
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  -- ID of the API key of the request, not the key itself, or empty if there was none
+  apiKey text not null default '',
+  -- route pattern of the request, like /search
+  route text not null default '',
+  operation text not null,
+  model text not null,
+  promptTokens int not null default 0,
+  completionTokens int not null default 0
+) strict;
+
+create index token_usage_created on token_usage (created);
+create index token_usage_apiKey_created on token_usage (apiKey, created);
+
+-- Maximum number of tokens each API key can use in the quota period
+create table usage_quotas (
+  apiKey text primary key,
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  updated text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  tokens int not null check (tokens >= 0)
+) strict;
+
+create trigger usage_quotas_updated_timestamp after update on usage_quotas begin
+  update usage_quotas set updated = strftime('%Y-%m-%dT%H:%M:%fZ') where apiKey = old.apiKey;
+end;
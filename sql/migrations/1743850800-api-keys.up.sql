@@ -0,0 +1,9 @@
+-- API keys issued to clients, stored as hashes of the keys
+create table api_keys (
+  -- start of the hash of the key, which identifies it in usage records and quotas
+  id text primary key,
+  created text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  hash text unique not null,
+  name text not null default '',
+  revoked text
+) strict;
@@ -0,0 +1,2 @@
+-- progress of running jobs, so they can resume instead of starting over if they're interrupted
+alter table jobs add column progress text not null default '';
@@ -0,0 +1 @@
+drop table summary_failures;
@@ -0,0 +1,9 @@
+-- failed attempts at summarizing documents, so documents that keep failing are skipped until their content changes
+create table summary_failures (
+  documentID text primary key references documents (id) on delete cascade,
+  updated text not null default (strftime('%Y-%m-%dT%H:%M:%fZ')),
+  -- content hash of the document content that failed, see documents.contentHash
+  contentHash text not null,
+  attempts integer not null default 1,
+  error text not null default ''
+) strict;
@@ -0,0 +1,2 @@
+-- ID of the API key of the request that created the job, for token usage, see token_usage.apiKey
+alter table jobs add column apiKey text not null default '';
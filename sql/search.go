@@ -2,61 +2,154 @@ package sql
 
 import (
 	"app/model"
+	"app/tracing"
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"maragu.dev/errors"
 )
 
+type SearchOptions struct {
+	// Tags to filter by. If provided, the result will only include chunks of documents with all the tags.
+	Tags []string
+
+	// Entities to filter by, regardless of case.
+	// If provided, the result will only include chunks of documents that mention all the entities.
+	Entities []string
+
+	// Topics to filter by. If provided, the result will only include chunks of documents with all the topics.
+	Topics []string
+
+	// Expansions of the query for full-text search, like synonyms, which are matched in addition to the query.
+	Expansions []string
+}
+
+// filter is a SQL condition for the document ID column matching the options, and the query arguments for it.
+func (o SearchOptions) filter(column string) (string, []any) {
+	tagCondition, tagArgs := tagFilter(column, o.Tags)
+	entityCondition, entityArgs := entityFilter(column, o.Entities)
+	topicCondition, topicArgs := topicFilter(column, o.Topics)
+
+	return tagCondition + " and " + entityCondition + " and " + topicCondition, slices.Concat(tagArgs, entityArgs, topicArgs)
+}
+
 // Search chunks that match the query and embedding. Matches using FTS first, then vector similarity search.
-// See https://alexgarcia.xyz/blog/2024/sqlite-vec-hybrid-search/ for the search query.
-func (d *Database) Search(ctx context.Context, q string, embedding []byte) ([]model.Chunk, error) {
-	// Do exact matches only in FTS for now
+// See https://alexgarcia.xyz/blog/2024/sqlite-vec-hybrid-search/ for the search approach.
+// The two phases run as separate queries so they can be measured separately, and are combined here.
+func (d *Database) Search(ctx context.Context, q string, embedding []byte, opts SearchOptions) (_ []model.Chunk, err error) {
+	ctx, span := tracer.Start(ctx, "sql.Search", trace.WithAttributes(
+		attribute.Int("query.length", len(q)),
+		attribute.StringSlice("tags", opts.Tags),
+		attribute.StringSlice("entities", opts.Entities),
+		attribute.StringSlice("topics", opts.Topics),
+		attribute.Int("expansions", len(opts.Expansions)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	ftsChunks, err := d.searchFTS(ctx, q, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vectorChunks, err := d.searchVector(ctx, embedding, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := combineChunks(ftsChunks, vectorChunks)
+	searchResults.WithLabelValues("combined").Observe(float64(len(chunks)))
+	span.SetAttributes(attribute.Int("chunks", len(chunks)))
+
+	return chunks, nil
+}
+
+// searchFTS for chunks with an exact match of the query, ordered by BM25 rank.
+func (d *Database) searchFTS(ctx context.Context, q string, opts SearchOptions) (_ []model.Chunk, err error) {
+	ctx, span := tracer.Start(ctx, "sql.searchFTS", trace.WithAttributes(attribute.Int("query.length", len(q))))
+	defer func() { tracing.End(span, err) }()
+
+	// Do exact matches only in FTS for now, of the query or any of its expansions
 	q = fmt.Sprintf(`"%v"`, strings.Trim(q, `"`))
+	for _, e := range opts.Expansions {
+		q += ` OR "` + strings.ReplaceAll(e, `"`, `""`) + `"`
+	}
+
+	filterCondition, filterArgs := opts.filter("documents.id")
 
 	query := `
-		with
-
-		fts_matches as (
-			select
-				id,
-				row_number() over (order by bm25(chunks_fts)) as rank_number,
-				1 as source
-			from chunks
-				join chunks_fts on (chunks.rowid = chunks_fts.rowid)
-			where chunks_fts.content match ?
-			order by bm25(chunks_fts)
-		),
-
-		vector_matches as (
-			select
-				id,
-				row_number() over (order by distance) as rank_number,
-				2 as source
-			from chunks
-				join chunk_embeddings on (chunks.id = chunk_embeddings.chunkID)
+		select chunks.*
+		from chunks
+			join chunks_fts on (chunks.rowid = chunks_fts.rowid)
+			join documents on (chunks.documentID = documents.id)
+		where chunks_fts.content match ? and documents.deleted is null and ` + filterCondition + `
+		order by bm25(chunks_fts)`
+
+	start := time.Now()
+	var chunks []model.Chunk
+	if err := d.H.Select(ctx, &chunks, query, append([]any{q}, filterArgs...)...); err != nil {
+		return nil, errors.Wrap(err, "error searching chunks with full-text search")
+	}
+	searchDuration.WithLabelValues("fts").Observe(time.Since(start).Seconds())
+	searchResults.WithLabelValues("fts").Observe(float64(len(chunks)))
+	span.SetAttributes(attribute.Int("chunks", len(chunks)))
+
+	return chunks, nil
+}
+
+// vectorSearchK is the number of nearest neighbors to find in vector search.
+const vectorSearchK = 100
+
+// searchVector for the chunks closest to the embedding, ordered by distance.
+func (d *Database) searchVector(ctx context.Context, embedding []byte, opts SearchOptions) (_ []model.Chunk, err error) {
+	ctx, span := tracer.Start(ctx, "sql.searchVector", trace.WithAttributes(attribute.Int("k", vectorSearchK)))
+	defer func() { tracing.End(span, err) }()
+
+	filterCondition, filterArgs := opts.filter("documents.id")
+
+	// The nearest neighbors are found first, and then chunks of documents in the trash or not matching the filters are left out
+	query := `
+		select chunks.*
+		from (
+			select chunkID, distance
+			from chunk_embeddings
 			where
-				k = 100 and
-				distance < 0.75 and
+				k = ? and
 				embedding match ?
-			order by distance
-		),
+		) as neighbors
+			join chunks on (chunks.id = neighbors.chunkID)
+			join documents on (chunks.documentID = documents.id)
+		where neighbors.distance < 0.75 and documents.deleted is null and ` + filterCondition + `
+		order by neighbors.distance`
 
-		combined as (
-			select id, rank_number, source from fts_matches
-			union all
-			select id, rank_number, source from vector_matches
-		)
+	start := time.Now()
+	var chunks []model.Chunk
+	if err := d.H.Select(ctx, &chunks, query, append([]any{vectorSearchK, embedding}, filterArgs...)...); err != nil {
+		return nil, errors.Wrap(err, "error searching chunks with vector search")
+	}
+	searchDuration.WithLabelValues("vector").Observe(time.Since(start).Seconds())
+	searchResults.WithLabelValues("vector").Observe(float64(len(chunks)))
+	span.SetAttributes(attribute.Int("chunks", len(chunks)))
 
-		select distinct chunks.*
-		from chunks
-			join combined using (id)
-		order by combined.source, combined.rank_number`
+	return chunks, nil
+}
 
+// combineChunks in order, skipping chunks that have already been seen.
+func combineChunks(lists ...[]model.Chunk) []model.Chunk {
+	seen := map[model.ID]bool{}
 	var chunks []model.Chunk
-	if err := d.H.Select(ctx, &chunks, query, q, embedding); err != nil {
-		return chunks, errors.Wrap(err, "error searching chunks")
+	for _, list := range lists {
+		for _, c := range list {
+			if seen[c.ID] {
+				continue
+			}
+			seen[c.ID] = true
+			chunks = append(chunks, c)
+		}
 	}
-	return chunks, nil
+	return chunks
 }
@@ -7,6 +7,7 @@ import (
 
 	"app/aitest"
 	"app/model"
+	"app/sql"
 	"app/sqltest"
 )
 
@@ -33,7 +34,7 @@ func TestDatabase_Search(t *testing.T) {
 		embedding, err := ai.EmbedString(t.Context(), "it's a pony")
 		is.NotError(t, err)
 
-		chunks, err = db.Search(t.Context(), query, embedding)
+		chunks, err = db.Search(t.Context(), query, embedding, sql.SearchOptions{})
 		is.NotError(t, err)
 		is.Equal(t, 1, len(chunks))
 
@@ -60,7 +61,7 @@ func TestDatabase_Search(t *testing.T) {
 		embedding, err := ai.EmbedString(t.Context(), query)
 		is.NotError(t, err)
 
-		chunks, err = db.Search(t.Context(), query, embedding)
+		chunks, err = db.Search(t.Context(), query, embedding, sql.SearchOptions{})
 		is.NotError(t, err)
 		is.Equal(t, 1, len(chunks))
 
@@ -87,8 +88,74 @@ func TestDatabase_Search(t *testing.T) {
 		embedding, err := ai.EmbedString(t.Context(), query)
 		is.NotError(t, err)
 
-		results, err := db.Search(t.Context(), query, embedding)
+		results, err := db.Search(t.Context(), query, embedding, sql.SearchOptions{})
 		is.NotError(t, err)
 		is.Equal(t, 0, len(results))
 	})
+
+	t.Run("leaves out documents in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		doc := model.Document{
+			Content: "This is a test document about artificial intelligence",
+		}
+
+		chunks, err := doc.Chunk(t.Context(), ai.EmbedString)
+		is.NotError(t, err)
+
+		doc, err = db.CreateDocument(t.Context(), doc, chunks)
+		is.NotError(t, err)
+
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		query := "artificial intelligence"
+		embedding, err := ai.EmbedString(t.Context(), query)
+		is.NotError(t, err)
+
+		chunks, err = db.Search(t.Context(), query, embedding, sql.SearchOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(chunks))
+	})
+
+	t.Run("only finds documents with all the tags", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		var ids []model.ID
+		for _, tags := range [][]string{{"ai", "research"}, {"ai"}} {
+			doc := model.Document{
+				Content: "This is a test document about artificial intelligence",
+			}
+
+			chunks, err := doc.Chunk(t.Context(), ai.EmbedString)
+			is.NotError(t, err)
+
+			doc, err = db.CreateDocument(t.Context(), doc, chunks)
+			is.NotError(t, err)
+
+			err = db.AddDocumentTags(t.Context(), doc.ID, tags)
+			is.NotError(t, err)
+
+			ids = append(ids, doc.ID)
+		}
+
+		query := "artificial intelligence"
+		embedding, err := ai.EmbedString(t.Context(), query)
+		is.NotError(t, err)
+
+		chunks, err := db.Search(t.Context(), query, embedding, sql.SearchOptions{Tags: []string{"ai"}})
+		is.NotError(t, err)
+		is.Equal(t, 2, len(chunks))
+
+		chunks, err = db.Search(t.Context(), query, embedding, sql.SearchOptions{Tags: []string{"research", "ai"}})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(chunks))
+		is.Equal(t, ids[0], chunks[0].DocumentID)
+
+		chunks, err = db.Search(t.Context(), query, embedding, sql.SearchOptions{Tags: []string{"unknown"}})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(chunks))
+	})
 }
@@ -0,0 +1,143 @@
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// SaveDocumentSummary and its embedding as the summary chunk of the document, replacing any previous summary.
+func (d *Database) SaveDocumentSummary(ctx context.Context, s model.DocumentSummary, embedding []byte) (_ model.DocumentSummary, err error) {
+	ctx, span := tracer.Start(ctx, "sql.SaveDocumentSummary", trace.WithAttributes(attribute.String("document.id", string(s.DocumentID))))
+	defer func() { tracing.End(span, err) }()
+
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from documents where id = ? and deleted is null)", s.DocumentID); err != nil {
+			return errors.Wrap(err, "error checking if document exists")
+		}
+
+		if !exists {
+			return model.ErrorDocumentNotFound
+		}
+
+		query := `
+			insert into document_summaries (documentID, content, contentHash, prompt)
+			values (?, ?, ?, ?)
+			on conflict (documentID) do update set
+				created = strftime('%Y-%m-%dT%H:%M:%fZ'),
+				content = excluded.content,
+				contentHash = excluded.contentHash,
+				prompt = excluded.prompt
+			returning *
+		`
+		if err := tx.Get(ctx, &s, query, s.DocumentID, s.Content, s.ContentHash, s.Prompt); err != nil {
+			return errors.Wrap(err, "error saving document summary")
+		}
+
+		if err := tx.Exec(ctx, "delete from summary_failures where documentID = ?", s.DocumentID); err != nil {
+			return errors.Wrap(err, "error deleting summary failures")
+		}
+
+		if err := tx.Exec(ctx, `delete from chunks where documentID = ? and "index" = ?`, s.DocumentID, model.SummaryChunkIndex); err != nil {
+			return errors.Wrap(err, "error deleting previous summary chunk")
+		}
+
+		if s.Content == "" {
+			return nil
+		}
+
+		var c model.Chunk
+		query = `
+			insert into chunks (documentID, "index", content)
+			values (?, ?, ?)
+			returning *
+		`
+		if err := tx.Get(ctx, &c, query, s.DocumentID, model.SummaryChunkIndex, s.Content); err != nil {
+			return errors.Wrap(err, "error creating summary chunk")
+		}
+
+		if err := tx.Exec(ctx, "insert into chunk_embeddings (chunkID, embedding) values (?, ?)", c.ID, embedding); err != nil {
+			return errors.Wrap(err, "error creating summary chunk embedding")
+		}
+
+		return nil
+	})
+
+	return s, err
+}
+
+// GetDocumentSummary of a document that's not in the trash.
+func (d *Database) GetDocumentSummary(ctx context.Context, id model.ID) (_ model.DocumentSummary, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentSummary", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select s.documentID, s.created, s.content, s.contentHash
+		from document_summaries s
+			join documents d on (s.documentID = d.id)
+		where s.documentID = ? and d.deleted is null
+	`
+
+	var s model.DocumentSummary
+	if err := d.H.Get(ctx, &s, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s, model.ErrorSummaryNotFound
+		}
+		return s, errors.Wrap(err, "error getting document summary")
+	}
+
+	return s, nil
+}
+
+// ListDocumentsWithStaleSummary lists documents that have no summary, or a summary of content that has since changed,
+// least recently updated first. Documents that have failed to be summarized maxAttempts times are skipped,
+// until their content changes, see [Database.RecordSummaryFailure].
+func (d *Database) ListDocumentsWithStaleSummary(ctx context.Context, limit, maxAttempts int) (_ []model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListDocumentsWithStaleSummary", trace.WithAttributes(attribute.Int("limit", limit)))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select d.id, d.created, d.updated, d.content, d.metadata, d.contentHash, d.simHash, d.externalID, d.deleted
+		from documents d
+			left join document_summaries s on (s.documentID = d.id)
+			left join summary_failures f on (f.documentID = d.id and f.contentHash = d.contentHash)
+		where d.deleted is null and (s.contentHash is null or s.contentHash != d.contentHash) and (f.attempts is null or f.attempts < ?)
+		order by d.updated, d.id
+		limit ?
+	`
+
+	var docs []model.Document
+	if err := d.H.Select(ctx, &docs, query, maxAttempts, limit); err != nil {
+		return nil, errors.Wrap(err, "error listing documents with stale summary")
+	}
+
+	return docs, nil
+}
+
+// RecordSummaryFailure of the current content of the document, counting the attempts for the same content.
+func (d *Database) RecordSummaryFailure(ctx context.Context, doc model.Document, message string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.RecordSummaryFailure", trace.WithAttributes(attribute.String("document.id", string(doc.ID))))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		insert into summary_failures (documentID, contentHash, error)
+		values (?, ?, ?)
+		on conflict (documentID) do update set
+			updated = strftime('%Y-%m-%dT%H:%M:%fZ'),
+			attempts = case when contentHash = excluded.contentHash then attempts + 1 else 1 end,
+			contentHash = excluded.contentHash,
+			error = excluded.error
+	`
+	if err := d.H.Exec(ctx, query, doc.ID, doc.ContentHash, message); err != nil {
+		return errors.Wrap(err, "error recording summary failure")
+	}
+
+	return nil
+}
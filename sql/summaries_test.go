@@ -0,0 +1,160 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/aitest"
+	"app/dedup"
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestDatabase_DocumentSummaries(t *testing.T) {
+	t.Run("saves, gets, and lists summaries, which are searchable", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		_, err = db.GetDocumentSummary(t.Context(), doc.ID)
+		is.Error(t, model.ErrorSummaryNotFound, err)
+
+		summary := "A document about artificial intelligence"
+		embedding, err := ai.EmbedString(t.Context(), summary)
+		is.NotError(t, err)
+
+		s, err := db.SaveDocumentSummary(t.Context(), model.DocumentSummary{
+			DocumentID:  doc.ID,
+			Content:     summary,
+			ContentHash: dedup.ContentHash(doc.Content),
+			Prompt:      "summarize-document@v1",
+		}, embedding)
+		is.NotError(t, err)
+		is.Equal(t, summary, s.Content)
+		is.Equal(t, "summarize-document@v1", s.Prompt)
+		is.True(t, !s.Created.T.IsZero())
+
+		s, err = db.GetDocumentSummary(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, summary, s.Content)
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+		is.Equal(t, summary, docs[0].Summary)
+
+		chunks, err := db.Search(t.Context(), "artificial intelligence", embedding, sql.SearchOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(chunks))
+		is.Equal(t, model.SummaryChunkIndex, chunks[0].Index)
+		is.Equal(t, doc.ID, chunks[0].DocumentID)
+	})
+
+	t.Run("keeps the summary chunk when the document chunks are saved again", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+		ai := aitest.NewClient(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		summary := "A document about artificial intelligence"
+		embedding, err := ai.EmbedString(t.Context(), summary)
+		is.NotError(t, err)
+
+		_, err = db.SaveDocumentSummary(t.Context(), model.DocumentSummary{DocumentID: doc.ID, Content: summary}, embedding)
+		is.NotError(t, err)
+
+		doc.Metadata = model.Metadata{"animal": "sheep"}
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		chunks, err := db.Search(t.Context(), "artificial intelligence", embedding, sql.SearchOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(chunks))
+	})
+
+	t.Run("lists documents without a summary or with a summary of changed content", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc1, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		doc2, err := db.CreateDocument(t.Context(), model.Document{Content: "Goat"}, nil)
+		is.NotError(t, err)
+
+		docs, err := db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(docs))
+
+		_, err = db.SaveDocumentSummary(t.Context(), model.DocumentSummary{DocumentID: doc1.ID, ContentHash: dedup.ContentHash("Sheep")}, nil)
+		is.NotError(t, err)
+		_, err = db.SaveDocumentSummary(t.Context(), model.DocumentSummary{DocumentID: doc2.ID, ContentHash: dedup.ContentHash("Goat")}, nil)
+		is.NotError(t, err)
+
+		docs, err = db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 0, len(docs))
+
+		doc1.Content = "Sheep are fluffy"
+		_, err = db.UpdateDocument(t.Context(), doc1, nil, model.Change{})
+		is.NotError(t, err)
+
+		docs, err = db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+		is.Equal(t, doc1.ID, docs[0].ID)
+	})
+
+	t.Run("skips documents that failed the max attempts until their content changes", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		for range 2 {
+			err = db.RecordSummaryFailure(t.Context(), doc, "oh no")
+			is.NotError(t, err)
+		}
+
+		docs, err := db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+
+		err = db.RecordSummaryFailure(t.Context(), doc, "oh no")
+		is.NotError(t, err)
+
+		docs, err = db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 0, len(docs))
+
+		doc.Content = "Sheep are fluffy"
+		doc, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		docs, err = db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+
+		err = db.RecordSummaryFailure(t.Context(), doc, "oh no")
+		is.NotError(t, err)
+
+		docs, err = db.ListDocumentsWithStaleSummary(t.Context(), 10, 3)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+	})
+
+	t.Run("errors on documents in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		_, err = db.SaveDocumentSummary(t.Context(), model.DocumentSummary{DocumentID: doc.ID, Content: "Sheep"}, nil)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+}
@@ -0,0 +1,151 @@
+package sql
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// AddDocumentTags to the document. Tags the document already has are ignored.
+// Tags must be normalized with [model.NormalizeTag].
+func (d *Database) AddDocumentTags(ctx context.Context, id model.ID, tags []string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.AddDocumentTags", trace.WithAttributes(
+		attribute.String("document.id", string(id)),
+		attribute.StringSlice("tags", tags),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	return d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from documents where id = ? and deleted is null)", id); err != nil {
+			return errors.Wrap(err, "error checking if document exists")
+		}
+
+		if !exists {
+			return model.ErrorDocumentNotFound
+		}
+
+		for _, tag := range tags {
+			if err := tx.Exec(ctx, "insert or ignore into document_tags (documentID, tag) values (?, ?)", id, tag); err != nil {
+				return errors.Wrap(err, "error adding document tag")
+			}
+		}
+
+		return nil
+	})
+}
+
+// RemoveDocumentTag from the document. Removing a tag the document doesn't have is not an error.
+func (d *Database) RemoveDocumentTag(ctx context.Context, id model.ID, tag string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.RemoveDocumentTag", trace.WithAttributes(
+		attribute.String("document.id", string(id)),
+		attribute.String("tag", tag),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	return d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from documents where id = ? and deleted is null)", id); err != nil {
+			return errors.Wrap(err, "error checking if document exists")
+		}
+
+		if !exists {
+			return model.ErrorDocumentNotFound
+		}
+
+		if err := tx.Exec(ctx, "delete from document_tags where documentID = ? and tag = ?", id, tag); err != nil {
+			return errors.Wrap(err, "error removing document tag")
+		}
+
+		return nil
+	})
+}
+
+// GetDocumentTags of the document, sorted alphabetically.
+func (d *Database) GetDocumentTags(ctx context.Context, id model.ID) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentTags", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	var tags []string
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from documents where id = ? and deleted is null)", id); err != nil {
+			return errors.Wrap(err, "error checking if document exists")
+		}
+
+		if !exists {
+			return model.ErrorDocumentNotFound
+		}
+
+		if err := tx.Select(ctx, &tags, "select tag from document_tags where documentID = ? order by tag", id); err != nil {
+			return errors.Wrap(err, "error getting document tags")
+		}
+
+		return nil
+	})
+
+	return tags, err
+}
+
+// ListTags with the number of documents that have each, sorted alphabetically.
+// Documents in the trash are not counted, and tags only on documents in the trash are left out.
+func (d *Database) ListTags(ctx context.Context) (_ []model.TagCount, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListTags")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select t.tag, count(*) as count
+		from document_tags t
+			join documents d on (t.documentID = d.id)
+		where d.deleted is null
+		group by t.tag
+		order by t.tag
+	`
+
+	var tags []model.TagCount
+	if err := d.H.Select(ctx, &tags, query); err != nil {
+		return nil, errors.Wrap(err, "error listing tags")
+	}
+
+	return tags, nil
+}
+
+// tagFilter is a SQL condition for the document ID column having all the tags, and the query arguments for it.
+// Returns "1 = 1" if there are no tags.
+func tagFilter(column string, tags []string) (string, []any) {
+	return allValuesFilter(column, "document_tags", "tag", tags)
+}
+
+// allValuesFilter is a SQL condition for the document ID column having all the values in the value column
+// of the table, and the query arguments for it. Returns "1 = 1" if there are no values.
+func allValuesFilter(column, table, valueColumn string, values []string) (string, []any) {
+	if len(values) == 0 {
+		return "1 = 1", nil
+	}
+
+	// Duplicate values would make the count never match
+	values = slices.Compact(slices.Sorted(slices.Values(values)))
+
+	args := make([]any, 0, len(values)+1)
+	for _, v := range values {
+		args = append(args, v)
+	}
+	args = append(args, len(values))
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return column + ` in (
+		select documentID
+		from ` + table + `
+		where ` + valueColumn + ` in (` + placeholders + `)
+		group by documentID
+		having count(distinct ` + valueColumn + `) = ?
+	)`, args
+}
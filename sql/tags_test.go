@@ -0,0 +1,108 @@
+package sql_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestDatabase_Tags(t *testing.T) {
+	t.Run("adds, gets, and removes document tags", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		err = db.AddDocumentTags(t.Context(), doc.ID, []string{"farm", "animals", "farm"})
+		is.NotError(t, err)
+
+		tags, err := db.GetDocumentTags(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.EqualSlice(t, []string{"animals", "farm"}, tags)
+
+		err = db.RemoveDocumentTag(t.Context(), doc.ID, "farm")
+		is.NotError(t, err)
+
+		tags, err = db.GetDocumentTags(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.EqualSlice(t, []string{"animals"}, tags)
+
+		err = db.RemoveDocumentTag(t.Context(), doc.ID, "farm")
+		is.NotError(t, err)
+	})
+
+	t.Run("errors on documents that don't exist or are in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		err := db.AddDocumentTags(t.Context(), "doc_doesnotexist", []string{"farm"})
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		err = db.AddDocumentTags(t.Context(), doc.ID, []string{"farm"})
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		_, err = db.GetDocumentTags(t.Context(), doc.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		err = db.RemoveDocumentTag(t.Context(), doc.ID, "farm")
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+
+	t.Run("lists tags with document counts, leaving out documents in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc1, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		doc2, err := db.CreateDocument(t.Context(), model.Document{Content: "Goat"}, nil)
+		is.NotError(t, err)
+		doc3, err := db.CreateDocument(t.Context(), model.Document{Content: "Tractor"}, nil)
+		is.NotError(t, err)
+
+		is.NotError(t, db.AddDocumentTags(t.Context(), doc1.ID, []string{"animals", "farm"}))
+		is.NotError(t, db.AddDocumentTags(t.Context(), doc2.ID, []string{"animals", "farm"}))
+		is.NotError(t, db.AddDocumentTags(t.Context(), doc3.ID, []string{"farm", "machines"}))
+		is.NotError(t, db.DeleteDocument(t.Context(), doc3.ID))
+
+		tags, err := db.ListTags(t.Context())
+		is.NotError(t, err)
+		is.EqualSlice(t, []model.TagCount{{Tag: "animals", Count: 2}, {Tag: "farm", Count: 2}}, tags)
+	})
+
+	t.Run("lists documents by tag with a cursor", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		var tagged []model.ID
+		for _, content := range []string{"A", "B", "C", "D", "E"} {
+			doc, err := db.CreateDocument(t.Context(), model.Document{Content: content}, nil)
+			is.NotError(t, err)
+
+			if content != "C" {
+				is.NotError(t, db.AddDocumentTags(t.Context(), doc.ID, []string{"letters"}))
+				tagged = append(tagged, doc.ID)
+			}
+		}
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{Tag: "letters", Limit: 3})
+		is.NotError(t, err)
+		is.Equal(t, 3, len(docs))
+		is.Equal(t, tagged[0], docs[0].ID)
+		is.Equal(t, tagged[2], docs[2].ID)
+
+		docs, err = db.ListDocuments(t.Context(), sql.ListDocumentsOptions{Tag: "letters", Limit: 3, Cursor: docs[2].ID})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(docs))
+		is.Equal(t, tagged[3], docs[0].ID)
+
+		docs, err = db.ListDocuments(t.Context(), sql.ListDocumentsOptions{Tag: "numbers"})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(docs))
+	})
+}
@@ -0,0 +1,97 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+
+	"app/model"
+	"app/tracing"
+)
+
+// ListDeletedDocuments in the trash, most recently deleted first.
+func (d *Database) ListDeletedDocuments(ctx context.Context) (_ []model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListDeletedDocuments")
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select id, created, updated, content, metadata, contentHash, simHash, externalID, deleted
+		from documents
+		where deleted is not null
+		order by deleted desc, id
+	`
+
+	var docs []model.Document
+	if err := d.H.Select(ctx, &docs, query); err != nil {
+		return nil, errors.Wrap(err, "error listing deleted documents")
+	}
+
+	return docs, nil
+}
+
+// RestoreDocument from the trash.
+func (d *Database) RestoreDocument(ctx context.Context, id model.ID) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.RestoreDocument", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	var ids []model.ID
+	query := `
+		update documents
+		set deleted = null
+		where id = ? and deleted is not null
+		returning id
+	`
+	if err := d.H.Select(ctx, &ids, query, id); err != nil {
+		return errors.Wrap(err, "error restoring document")
+	}
+
+	if len(ids) == 0 {
+		return model.ErrorDocumentNotFound
+	}
+
+	return nil
+}
+
+// PurgeDocument from the trash, which permanently deletes it with its chunks and versions.
+func (d *Database) PurgeDocument(ctx context.Context, id model.ID) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.PurgeDocument", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	var ids []model.ID
+	query := `
+		delete from documents
+		where id = ? and deleted is not null
+		returning id
+	`
+	if err := d.H.Select(ctx, &ids, query, id); err != nil {
+		return errors.Wrap(err, "error purging document")
+	}
+
+	if len(ids) == 0 {
+		return model.ErrorDocumentNotFound
+	}
+
+	return nil
+}
+
+// PurgeDocuments that were moved to the trash before the given time, returning the number of documents purged.
+func (d *Database) PurgeDocuments(ctx context.Context, before time.Time) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "sql.PurgeDocuments")
+	defer func() { tracing.End(span, err) }()
+
+	var ids []model.ID
+	query := `
+		delete from documents
+		where deleted is not null and deleted < ?
+		returning id
+	`
+	if err := d.H.Select(ctx, &ids, query, model.Time{T: before}); err != nil {
+		return 0, errors.Wrap(err, "error purging documents")
+	}
+
+	span.SetAttributes(attribute.Int("documents", len(ids)))
+	return len(ids), nil
+}
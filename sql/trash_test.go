@@ -0,0 +1,84 @@
+package sql_test
+
+import (
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestDatabase_Trash(t *testing.T) {
+	t.Run("deleted documents are moved to the trash and can be restored", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		docs, err := db.ListDocuments(t.Context(), sql.ListDocumentsOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(docs))
+
+		deleted, err := db.ListDeletedDocuments(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(deleted))
+		is.Equal(t, doc.ID, deleted[0].ID)
+		is.True(t, deleted[0].Deleted != nil)
+
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		err = db.RestoreDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		restored, err := db.GetDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.True(t, restored.Deleted == nil)
+
+		err = db.RestoreDocument(t.Context(), doc.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+
+	t.Run("purges a document in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		err = db.PurgeDocument(t.Context(), doc.ID)
+		is.Error(t, model.ErrorDocumentNotFound, err)
+
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		err = db.PurgeDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		deleted, err := db.ListDeletedDocuments(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 0, len(deleted))
+	})
+
+	t.Run("purges documents deleted before a time", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		n, err := db.PurgeDocuments(t.Context(), time.Now().Add(-time.Hour))
+		is.NotError(t, err)
+		is.Equal(t, 0, n)
+
+		n, err = db.PurgeDocuments(t.Context(), time.Now().Add(time.Second))
+		is.NotError(t, err)
+		is.Equal(t, 1, n)
+	})
+}
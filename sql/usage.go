@@ -0,0 +1,188 @@
+package sql
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// SaveUsage records of token usage.
+func (d *Database) SaveUsage(ctx context.Context, usage []model.Usage) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.SaveUsage", trace.WithAttributes(attribute.Int("records", len(usage))))
+	defer func() { tracing.End(span, err) }()
+
+	return d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		query := `
+			insert into token_usage (apiKey, route, operation, model, promptTokens, completionTokens)
+			values (?, ?, ?, ?, ?, ?)
+		`
+		for _, u := range usage {
+			if err := tx.Exec(ctx, query, u.APIKey, u.Route, u.Operation, u.Model, u.PromptTokens, u.CompletionTokens); err != nil {
+				return errors.Wrap(err, "error saving usage")
+			}
+		}
+		return nil
+	})
+}
+
+// GetUsedTokens by the API key since the given time, both prompt and completion tokens.
+func (d *Database) GetUsedTokens(ctx context.Context, apiKey string, since time.Time) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetUsedTokens")
+	defer func() { tracing.End(span, err) }()
+
+	var tokens int
+	query := `
+		select coalesce(sum(promptTokens + completionTokens), 0)
+		from token_usage
+		where apiKey = ? and created >= ?
+	`
+	if err := d.H.Get(ctx, &tokens, query, apiKey, model.Time{T: since}); err != nil {
+		return 0, errors.Wrap(err, "error getting used tokens")
+	}
+
+	return tokens, nil
+}
+
+type GetUsageReportOptions struct {
+	// From and To limit the report to usage recorded in the time range, including From and excluding To.
+	// The zero time means no limit.
+	From, To time.Time
+
+	// GroupBy is what to total the usage by, in the order given. No grouping gives a single total.
+	GroupBy []model.UsageGroupBy
+}
+
+// GetUsageReport with totals of token usage, most tokens first.
+func (d *Database) GetUsageReport(ctx context.Context, opts GetUsageReportOptions) (_ []model.UsageTotal, err error) {
+	var columns []string
+	for _, g := range opts.GroupBy {
+		if !g.Valid() {
+			panic("invalid usage group by " + string(g))
+		}
+		columns = append(columns, string(g))
+	}
+
+	ctx, span := tracer.Start(ctx, "sql.GetUsageReport", trace.WithAttributes(attribute.StringSlice("groupBy", columns)))
+	defer func() { tracing.End(span, err) }()
+
+	conditions := []string{"1 = 1"}
+	var args []any
+	if !opts.From.IsZero() {
+		conditions = append(conditions, "created >= ?")
+		args = append(args, model.Time{T: opts.From})
+	}
+	if !opts.To.IsZero() {
+		conditions = append(conditions, "created < ?")
+		args = append(args, model.Time{T: opts.To})
+	}
+
+	// The columns are from the valid group bys above, so they're safe to put in the query
+	selects := append(slices.Clone(columns),
+		"coalesce(sum(promptTokens), 0) as promptTokens",
+		"coalesce(sum(completionTokens), 0) as completionTokens",
+		"coalesce(sum(promptTokens + completionTokens), 0) as totalTokens",
+	)
+	query := "select " + strings.Join(selects, ", ") + " from token_usage where " + strings.Join(conditions, " and ")
+	if len(columns) > 0 {
+		query += " group by " + strings.Join(columns, ", ")
+	}
+	query += " order by totalTokens desc"
+	if len(columns) > 0 {
+		query += ", " + strings.Join(columns, ", ")
+	}
+
+	var totals []model.UsageTotal
+	if err := d.H.Select(ctx, &totals, query, args...); err != nil {
+		return nil, errors.Wrap(err, "error getting usage report")
+	}
+
+	return totals, nil
+}
+
+// SetUsageQuota for the API key with the ID, replacing any previous quota,
+// or return [model.ErrorAPIKeyNotFound] if there is no such key that is not revoked.
+func (d *Database) SetUsageQuota(ctx context.Context, apiKey string, tokens int) (_ model.UsageQuota, err error) {
+	ctx, span := tracer.Start(ctx, "sql.SetUsageQuota", trace.WithAttributes(attribute.Int("tokens", tokens)))
+	defer func() { tracing.End(span, err) }()
+
+	var q model.UsageQuota
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from api_keys where id = ? and revoked is null)", apiKey); err != nil {
+			return errors.Wrap(err, "error checking if API key exists")
+		}
+
+		if !exists {
+			return model.ErrorAPIKeyNotFound
+		}
+
+		query := `
+			insert into usage_quotas (apiKey, tokens)
+			values (?, ?)
+			on conflict (apiKey) do update set tokens = excluded.tokens
+			returning *
+		`
+		if err := tx.Get(ctx, &q, query, apiKey, tokens); err != nil {
+			return errors.Wrap(err, "error setting usage quota")
+		}
+
+		return nil
+	})
+
+	return q, err
+}
+
+// GetUsageQuota for the API key, or [model.ErrorUsageQuotaNotFound] if it doesn't have one.
+func (d *Database) GetUsageQuota(ctx context.Context, apiKey string) (_ model.UsageQuota, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetUsageQuota")
+	defer func() { tracing.End(span, err) }()
+
+	var q model.UsageQuota
+	if err := d.H.Get(ctx, &q, "select * from usage_quotas where apiKey = ?", apiKey); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return q, model.ErrorUsageQuotaNotFound
+		}
+		return q, errors.Wrap(err, "error getting usage quota")
+	}
+
+	return q, nil
+}
+
+// ListUsageQuotas ordered by API key.
+func (d *Database) ListUsageQuotas(ctx context.Context) (_ []model.UsageQuota, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListUsageQuotas")
+	defer func() { tracing.End(span, err) }()
+
+	var qs []model.UsageQuota
+	if err := d.H.Select(ctx, &qs, "select * from usage_quotas order by apiKey"); err != nil {
+		return nil, errors.Wrap(err, "error listing usage quotas")
+	}
+
+	return qs, nil
+}
+
+// DeleteUsageQuota for the API key, or return [model.ErrorUsageQuotaNotFound] if it doesn't have one.
+func (d *Database) DeleteUsageQuota(ctx context.Context, apiKey string) (err error) {
+	ctx, span := tracer.Start(ctx, "sql.DeleteUsageQuota")
+	defer func() { tracing.End(span, err) }()
+
+	var keys []string
+	if err := d.H.Select(ctx, &keys, "delete from usage_quotas where apiKey = ? returning apiKey", apiKey); err != nil {
+		return errors.Wrap(err, "error deleting usage quota")
+	}
+
+	if len(keys) == 0 {
+		return model.ErrorUsageQuotaNotFound
+	}
+
+	return nil
+}
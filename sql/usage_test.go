@@ -0,0 +1,121 @@
+package sql_test
+
+import (
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sql"
+	"app/sqltest"
+)
+
+func TestDatabase_Usage(t *testing.T) {
+	t.Run("saves usage and reports totals, grouped or not", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		err := db.SaveUsage(t.Context(), []model.Usage{
+			{APIKey: "a", Route: "/search", Operation: "embed", Model: "embedder", PromptTokens: 10},
+			{APIKey: "a", Route: "/conversations/{id}/messages", Operation: "chat_complete", Model: "llama", PromptTokens: 100, CompletionTokens: 50},
+			{APIKey: "b", Route: "/search", Operation: "embed", Model: "embedder", PromptTokens: 5},
+		})
+		is.NotError(t, err)
+
+		totals, err := db.GetUsageReport(t.Context(), sql.GetUsageReportOptions{})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(totals))
+		is.Equal(t, 115, totals[0].PromptTokens)
+		is.Equal(t, 50, totals[0].CompletionTokens)
+		is.Equal(t, 165, totals[0].TotalTokens)
+
+		totals, err = db.GetUsageReport(t.Context(), sql.GetUsageReportOptions{
+			GroupBy: []model.UsageGroupBy{model.UsageGroupByAPIKey, model.UsageGroupByModel},
+		})
+		is.NotError(t, err)
+		is.Equal(t, 3, len(totals))
+		is.Equal(t, "a", totals[0].APIKey)
+		is.Equal(t, "llama", totals[0].Model)
+		is.Equal(t, 150, totals[0].TotalTokens)
+		is.Equal(t, "", totals[0].Route)
+		is.Equal(t, "a", totals[1].APIKey)
+		is.Equal(t, "embedder", totals[1].Model)
+		is.Equal(t, 10, totals[1].TotalTokens)
+		is.Equal(t, "b", totals[2].APIKey)
+		is.Equal(t, 5, totals[2].TotalTokens)
+
+		totals, err = db.GetUsageReport(t.Context(), sql.GetUsageReportOptions{
+			GroupBy: []model.UsageGroupBy{model.UsageGroupByRoute},
+		})
+		is.NotError(t, err)
+		is.Equal(t, 2, len(totals))
+		is.Equal(t, "/conversations/{id}/messages", totals[0].Route)
+		is.Equal(t, "/search", totals[1].Route)
+		is.Equal(t, 15, totals[1].TotalTokens)
+
+		totals, err = db.GetUsageReport(t.Context(), sql.GetUsageReportOptions{From: time.Now().Add(time.Minute)})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(totals))
+		is.Equal(t, 0, totals[0].TotalTokens)
+	})
+
+	t.Run("gets used tokens by an API key since a time", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		err := db.SaveUsage(t.Context(), []model.Usage{
+			{APIKey: "a", Operation: "chat_complete", Model: "llama", PromptTokens: 100, CompletionTokens: 50},
+			{APIKey: "b", Operation: "embed", Model: "embedder", PromptTokens: 5},
+		})
+		is.NotError(t, err)
+
+		used, err := db.GetUsedTokens(t.Context(), "a", time.Now().Add(-time.Minute))
+		is.NotError(t, err)
+		is.Equal(t, 150, used)
+
+		used, err = db.GetUsedTokens(t.Context(), "a", time.Now().Add(time.Minute))
+		is.NotError(t, err)
+		is.Equal(t, 0, used)
+
+		used, err = db.GetUsedTokens(t.Context(), "c", time.Now().Add(-time.Minute))
+		is.NotError(t, err)
+		is.Equal(t, 0, used)
+	})
+}
+
+func TestDatabase_UsageQuotas(t *testing.T) {
+	t.Run("sets, gets, lists, and deletes quotas", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		k, err := db.CreateAPIKey(t.Context(), "Sheep", model.NewAPIKey())
+		is.NotError(t, err)
+
+		_, err = db.GetUsageQuota(t.Context(), k.ID)
+		is.Error(t, model.ErrorUsageQuotaNotFound, err)
+
+		_, err = db.SetUsageQuota(t.Context(), "a", 1000)
+		is.Error(t, model.ErrorAPIKeyNotFound, err)
+
+		q, err := db.SetUsageQuota(t.Context(), k.ID, 1000)
+		is.NotError(t, err)
+		is.Equal(t, k.ID, q.APIKey)
+		is.Equal(t, 1000, q.Tokens)
+
+		q, err = db.SetUsageQuota(t.Context(), k.ID, 2000)
+		is.NotError(t, err)
+		is.Equal(t, 2000, q.Tokens)
+
+		q, err = db.GetUsageQuota(t.Context(), k.ID)
+		is.NotError(t, err)
+		is.Equal(t, 2000, q.Tokens)
+
+		quotas, err := db.ListUsageQuotas(t.Context())
+		is.NotError(t, err)
+		is.Equal(t, 1, len(quotas))
+
+		err = db.DeleteUsageQuota(t.Context(), k.ID)
+		is.NotError(t, err)
+
+		err = db.DeleteUsageQuota(t.Context(), k.ID)
+		is.Error(t, model.ErrorUsageQuotaNotFound, err)
+	})
+}
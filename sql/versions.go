@@ -0,0 +1,131 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/sqlh/sql"
+
+	"app/model"
+	"app/tracing"
+)
+
+// saveVersion of the document as it is before it's changed to the new document, created when it was last revised.
+// Nothing is saved if the content and metadata stay the same.
+func (d *Database) saveVersion(ctx context.Context, tx *sql.Tx, doc model.Document, change model.Change) error {
+	query := `
+		insert into document_versions (documentID, version, created, content, metadata, author, comment)
+		select id, coalesce((select max(version) from document_versions where documentID = d.id), 0) + 1, coalesce(revised, created), content, metadata, ?, ?
+		from documents d
+		where id = ? and not (content = ? and metadata = ?)
+	`
+	return tx.Exec(ctx, query, change.Author, change.Comment, doc.ID, doc.Content, doc.Metadata)
+}
+
+// ListDocumentVersions of the document, oldest first. The current revision is not included.
+func (d *Database) ListDocumentVersions(ctx context.Context, id model.ID) (_ []model.DocumentVersion, err error) {
+	ctx, span := tracer.Start(ctx, "sql.ListDocumentVersions", trace.WithAttributes(attribute.String("document.id", string(id))))
+	defer func() { tracing.End(span, err) }()
+
+	var versions []model.DocumentVersion
+	err = d.H.InTransaction(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.Get(ctx, &exists, "select exists(select 1 from documents where id = ? and deleted is null)", id); err != nil {
+			return errors.Wrap(err, "error checking if document exists")
+		}
+
+		if !exists {
+			return model.ErrorDocumentNotFound
+		}
+
+		query := `
+			select documentID, version, created, replaced, content, metadata, author, comment
+			from document_versions
+			where documentID = ?
+			order by version
+		`
+		if err := tx.Select(ctx, &versions, query, id); err != nil {
+			return errors.Wrap(err, "error listing document versions")
+		}
+
+		return nil
+	})
+
+	return versions, err
+}
+
+// GetDocumentVersion by document ID and version number, starting at 1.
+// Versions of documents in the trash are not found.
+func (d *Database) GetDocumentVersion(ctx context.Context, id model.ID, version int) (_ model.DocumentVersion, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentVersion", trace.WithAttributes(
+		attribute.String("document.id", string(id)),
+		attribute.Int("version", version),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	query := `
+		select v.documentID, v.version, v.created, v.replaced, v.content, v.metadata, v.author, v.comment
+		from document_versions v
+			join documents d on d.id = v.documentID
+		where v.documentID = ? and v.version = ? and d.deleted is null
+	`
+
+	var v model.DocumentVersion
+	if err := d.H.Get(ctx, &v, query, id, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return v, model.ErrorVersionNotFound
+		}
+		return v, errors.Wrap(err, "error getting document version")
+	}
+
+	return v, nil
+}
+
+// GetDocumentAt returns the document with the content and metadata it had at the given time.
+// Updated is when that revision was written. Returns [model.ErrorDocumentNotFound] if the document didn't exist yet.
+func (d *Database) GetDocumentAt(ctx context.Context, id model.ID, t time.Time) (_ model.Document, err error) {
+	ctx, span := tracer.Start(ctx, "sql.GetDocumentAt", trace.WithAttributes(
+		attribute.String("document.id", string(id)),
+		attribute.String("at", t.UTC().Format(time.RFC3339Nano)),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	doc, err := d.GetDocument(ctx, id)
+	if err != nil {
+		return doc, err
+	}
+
+	if t.Before(doc.Created.T) {
+		return model.Document{}, model.ErrorDocumentNotFound
+	}
+
+	query := `
+		select documentID, version, created, replaced, content, metadata, author, comment
+		from document_versions
+		where documentID = ? and replaced > ?
+		order by version
+		limit 1
+	`
+
+	var versions []model.DocumentVersion
+	if err := d.H.Select(ctx, &versions, query, id, model.Time{T: t}); err != nil {
+		return doc, errors.Wrap(err, "error getting document version")
+	}
+
+	// The first version replaced after the time is the one that was current then, otherwise it's the current revision
+	if len(versions) > 0 {
+		doc.Content = versions[0].Content
+		doc.Metadata = versions[0].Metadata
+		doc.Updated = versions[0].Created
+		return doc, nil
+	}
+
+	if err := d.H.Get(ctx, &doc.Updated, "select coalesce(revised, created) from documents where id = ?", id); err != nil {
+		return doc, errors.Wrap(err, "error getting document revision time")
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,132 @@
+package sql_test
+
+import (
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"app/model"
+	"app/sqltest"
+)
+
+func TestDatabase_DocumentVersions(t *testing.T) {
+	t.Run("records previous revisions on update", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep", Metadata: model.Metadata{"v": "1"}}, nil)
+		is.NotError(t, err)
+
+		doc.Content = "Goats"
+		doc.Metadata = model.Metadata{"v": "2"}
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{Author: "me", Comment: "Sheep to goats"})
+		is.NotError(t, err)
+
+		// Updating without changes doesn't record a version
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		versions, err := db.ListDocumentVersions(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, 1, len(versions))
+		is.Equal(t, 1, versions[0].Version)
+		is.Equal(t, "Sheep", versions[0].Content)
+		is.Equal(t, "1", versions[0].Metadata["v"])
+		is.Equal(t, "me", versions[0].Author)
+		is.Equal(t, "Sheep to goats", versions[0].Comment)
+
+		v, err := db.GetDocumentVersion(t.Context(), doc.ID, 1)
+		is.NotError(t, err)
+		is.Equal(t, "Sheep", v.Content)
+
+		_, err = db.GetDocumentVersion(t.Context(), doc.ID, 2)
+		is.Error(t, model.ErrorVersionNotFound, err)
+	})
+
+	t.Run("records when revisions were written, not when the document was last updated otherwise", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		doc.Content = "Goats"
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		time.Sleep(5 * time.Millisecond)
+		beforeTrash := time.Now()
+		time.Sleep(5 * time.Millisecond)
+
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+		err = db.RestoreDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		current, err := db.GetDocumentAt(t.Context(), doc.ID, time.Now())
+		is.NotError(t, err)
+		is.True(t, current.Updated.T.Before(beforeTrash))
+
+		doc.Content = "Cows"
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		versions, err := db.ListDocumentVersions(t.Context(), doc.ID)
+		is.NotError(t, err)
+		is.Equal(t, 2, len(versions))
+		is.Equal(t, doc.Created, versions[0].Created)
+		is.Equal(t, "Goats", versions[1].Content)
+		is.True(t, versions[1].Created.T.Before(beforeTrash))
+	})
+
+	t.Run("does not get versions of documents in the trash", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		doc.Content = "Goats"
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		err = db.DeleteDocument(t.Context(), doc.ID)
+		is.NotError(t, err)
+
+		_, err = db.GetDocumentVersion(t.Context(), doc.ID, 1)
+		is.Error(t, model.ErrorVersionNotFound, err)
+	})
+
+	t.Run("errors listing versions of a document that does not exist", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		_, err := db.ListDocumentVersions(t.Context(), "d_unknown")
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+}
+
+func TestDatabase_GetDocumentAt(t *testing.T) {
+	t.Run("gets the content the document had at a time", func(t *testing.T) {
+		db := sqltest.NewDatabase(t)
+
+		doc, err := db.CreateDocument(t.Context(), model.Document{Content: "Sheep"}, nil)
+		is.NotError(t, err)
+
+		time.Sleep(5 * time.Millisecond)
+		between := time.Now()
+		time.Sleep(5 * time.Millisecond)
+
+		doc.Content = "Goats"
+		_, err = db.UpdateDocument(t.Context(), doc, nil, model.Change{})
+		is.NotError(t, err)
+
+		past, err := db.GetDocumentAt(t.Context(), doc.ID, between)
+		is.NotError(t, err)
+		is.Equal(t, "Sheep", past.Content)
+
+		current, err := db.GetDocumentAt(t.Context(), doc.ID, time.Now())
+		is.NotError(t, err)
+		is.Equal(t, "Goats", current.Content)
+
+		_, err = db.GetDocumentAt(t.Context(), doc.ID, doc.Created.T.Add(-time.Second))
+		is.Error(t, model.ErrorDocumentNotFound, err)
+	})
+}
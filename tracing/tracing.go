@@ -0,0 +1,117 @@
+// Package tracing sets up OpenTelemetry tracing, and has helpers for spans and trace-aware logging.
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+)
+
+type Exporter string
+
+const (
+	ExporterNone   = Exporter("")
+	ExporterOTLP   = Exporter("otlp")
+	ExporterStdout = Exporter("stdout")
+)
+
+type NewTracerProviderOptions struct {
+	// Exporter to send spans with. If none, spans are created but not exported.
+	// The OTLP exporter is configured with the standard OTEL_EXPORTER_OTLP_* environment variables.
+	Exporter    Exporter
+	ServiceName string
+}
+
+// NewTracerProvider with the given options, and set it as the global tracer provider,
+// together with the W3C trace context propagator.
+// Call [sdktrace.TracerProvider.Shutdown] to flush remaining spans on shutdown.
+func NewTracerProvider(ctx context.Context, opts NewTracerProviderOptions) (*sdktrace.TracerProvider, error) {
+	var tpOpts []sdktrace.TracerProviderOption
+
+	switch opts.Exporter {
+	case ExporterNone:
+	case ExporterOTLP:
+		e, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating OTLP exporter")
+		}
+		tpOpts = append(tpOpts, sdktrace.WithBatcher(e))
+	case ExporterStdout:
+		e, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating stdout exporter")
+		}
+		tpOpts = append(tpOpts, sdktrace.WithSyncer(e))
+	default:
+		return nil, errors.Newf("unknown exporter %v", opts.Exporter)
+	}
+
+	r, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", opts.ServiceName),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating resource")
+	}
+	tpOpts = append(tpOpts, sdktrace.WithResource(r))
+
+	tp := sdktrace.NewTracerProvider(tpOpts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tp, nil
+}
+
+// End the span, recording err on it if it's not nil.
+// Use it with a named error return value, like:
+//
+//	defer func() { tracing.End(span, err) }()
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// logHandler adds trace and span IDs to log records, if the log context has a span.
+type logHandler struct {
+	h slog.Handler
+}
+
+// NewLogHandler wraps h so log records logged with a context carry the trace and span IDs from it.
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return &logHandler{h: h}
+}
+
+func (l *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return l.h.Enabled(ctx, level)
+}
+
+func (l *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return l.h.Handle(ctx, r)
+}
+
+func (l *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logHandler{h: l.h.WithAttrs(attrs)}
+}
+
+func (l *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{h: l.h.WithGroup(name)}
+}
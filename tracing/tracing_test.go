@@ -0,0 +1,39 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"maragu.dev/is"
+
+	"app/tracing"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	t.Run("adds trace and span IDs if the context has a span", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := slog.New(tracing.NewLogHandler(slog.NewTextHandler(&buf, nil)))
+
+		tp := sdktrace.NewTracerProvider()
+		ctx, span := tp.Tracer("test").Start(t.Context(), "test")
+		defer span.End()
+
+		log.InfoContext(ctx, "Hi")
+
+		is.True(t, strings.Contains(buf.String(), "trace_id="+span.SpanContext().TraceID().String()))
+		is.True(t, strings.Contains(buf.String(), "span_id="+span.SpanContext().SpanID().String()))
+	})
+
+	t.Run("does not add trace and span IDs without a span", func(t *testing.T) {
+		var buf bytes.Buffer
+		log := slog.New(tracing.NewLogHandler(slog.NewTextHandler(&buf, nil)))
+
+		log.InfoContext(context.Background(), "Hi")
+
+		is.True(t, !strings.Contains(buf.String(), "trace_id"))
+	})
+}
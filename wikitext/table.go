@@ -0,0 +1,143 @@
+package wikitext
+
+import (
+	"strings"
+)
+
+// tables converts wikitext tables to Markdown tables. Nested tables are removed.
+// A table without a closing "|}" ends at the next blank line or heading, so it doesn't swallow the rest of the page.
+func tables(s string) string {
+	lines := strings.Split(s, "\n")
+
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), "{|") {
+			out = append(out, lines[i])
+			continue
+		}
+
+		end, closed := tableEnd(lines[i:])
+
+		var tableLines []string
+		for _, line := range lines[i : i+end] {
+			tableLines = append(tableLines, strings.TrimSpace(line))
+		}
+
+		// Skip past the closing line of a closed table, but not past the line that ends an unclosed one
+		if closed {
+			i += end
+		} else {
+			i += end - 1
+		}
+
+		out = append(out, "", table(tableLines), "")
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// tableEnd returns the index of the "|}" line that closes the table starting at the first line, and true.
+// If the table isn't closed, it returns the index of the first blank line or heading after the start, or the number
+// of lines if there is none, and false.
+func tableEnd(lines []string) (int, bool) {
+	var depth int
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "{|") {
+			depth++
+		}
+		if strings.HasPrefix(line, "|}") {
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		}
+	}
+
+	for i, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line == "" || headingRegexp.MatchString(line) {
+			return i + 1, false
+		}
+	}
+	return len(lines), false
+}
+
+// table converts the lines of a single table, starting with the "{|" line, to a Markdown table.
+// The first row is always used as the header row, since Markdown tables must have one.
+func table(lines []string) string {
+	var caption string
+	var rows [][]string
+	var row []string
+	var depth int
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "{|"):
+			depth++
+			continue
+		case strings.HasPrefix(line, "|}"):
+			depth--
+			continue
+		}
+		if depth > 1 {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "|+"):
+			caption = tableCell(line[2:])
+		case strings.HasPrefix(line, "|-"):
+			if len(row) > 0 {
+				rows = append(rows, row)
+				row = nil
+			}
+		case strings.HasPrefix(line, "!"):
+			for _, cell := range strings.Split(strings.ReplaceAll(line[1:], "!!", "||"), "||") {
+				row = append(row, tableCell(cell))
+			}
+		case strings.HasPrefix(line, "|"):
+			for _, cell := range strings.Split(line[1:], "||") {
+				row = append(row, tableCell(cell))
+			}
+		default:
+			// Continuation of the previous cell
+			if len(row) > 0 && line != "" {
+				row[len(row)-1] = strings.TrimSpace(row[len(row)-1] + " " + line)
+			}
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	var columns int
+	for _, r := range rows {
+		columns = max(columns, len(r))
+	}
+	if columns == 0 {
+		return caption
+	}
+
+	var b strings.Builder
+	if caption != "" {
+		b.WriteString(caption + "\n\n")
+	}
+	for i, r := range rows {
+		for len(r) < columns {
+			r = append(r, "")
+		}
+		b.WriteString("| " + strings.Join(r, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// tableCell content, without attributes like in `style="color: red" | content`.
+func tableCell(cell string) string {
+	if attributes, content, ok := strings.Cut(cell, "|"); ok && strings.Contains(attributes, "=") {
+		cell = content
+	}
+	return strings.ReplaceAll(strings.TrimSpace(cell), "|", `\|`)
+}
@@ -0,0 +1,130 @@
+package wikitext
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type templateField struct {
+	key   string
+	value string
+}
+
+// templates replaces all templates, innermost first, with their rendered text.
+// An opening "{{" without a matching "}}" is removed, so templates after it are still replaced.
+func templates(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+		end := matching(s[start:], "{{", "}}")
+		if end < 0 {
+			b.WriteString(s[:start])
+			s = s[start+2:]
+			continue
+		}
+
+		b.WriteString(s[:start])
+		b.WriteString(template(templates(s[start+2 : start+end-2])))
+		s = s[start+end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// template renders the template with the given content, which has no nested templates.
+// Infoboxes become a list of their fields, a few inline templates become their text, and all others are removed.
+func template(content string) string {
+	params := splitParams(content)
+	name := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(params[0], "_", " ")))
+
+	var positional []string
+	var named []templateField
+	for _, p := range params[1:] {
+		if key, value, ok := strings.Cut(p, "="); ok && !strings.Contains(key, "[[") {
+			named = append(named, templateField{key: strings.TrimSpace(key), value: strings.TrimSpace(value)})
+			continue
+		}
+		positional = append(positional, strings.TrimSpace(p))
+	}
+
+	switch {
+	case strings.HasPrefix(name, "infobox"):
+		return infobox(named)
+	case name == "lang":
+		return param(positional, 1)
+	case strings.HasPrefix(name, "lang-"), name == "nowrap", name == "nobr", name == "small":
+		return param(positional, 0)
+	case name == "convert", name == "cvt":
+		return strings.TrimSpace(param(positional, 0) + " " + param(positional, 1))
+	case name == "!":
+		return "|"
+	default:
+		return ""
+	}
+}
+
+// splitParams on pipes that are not inside internal links.
+func splitParams(s string) []string {
+	var params []string
+	var depth, start int
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "[["):
+			depth++
+			i++
+		case strings.HasPrefix(s[i:], "]]") && depth > 0:
+			depth--
+			i++
+		case s[i] == '|' && depth == 0:
+			params = append(params, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, s[start:])
+}
+
+func param(params []string, i int) string {
+	if i >= len(params) {
+		return ""
+	}
+	return params[i]
+}
+
+// infobox as a list of its fields, skipping empty fields and fields for images and maps.
+func infobox(fields []templateField) string {
+	var b strings.Builder
+	b.WriteString("\n\n")
+	for _, f := range fields {
+		value := strings.Join(strings.Fields(brRegexp.ReplaceAllString(f.value, ", ")), " ")
+		if value == "" || isMediaField(f.key) {
+			continue
+		}
+		b.WriteString("- " + fieldName(f.key) + ": " + value + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func isMediaField(key string) bool {
+	key = strings.ToLower(key)
+	if key == "alt" || strings.HasPrefix(key, "map") {
+		return true
+	}
+	for _, s := range []string{"image", "caption", "logo", "signature", "pushpin"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName turns a field key like "birth_date" into "Birth date".
+func fieldName(key string) string {
+	key = strings.ReplaceAll(key, "_", " ")
+	r, size := utf8.DecodeRuneInString(key)
+	return string(unicode.ToUpper(r)) + key[size:]
+}
@@ -0,0 +1,241 @@
+// Package wikitext converts MediaWiki markup, as found in Wikipedia dumps, to Markdown.
+package wikitext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	commentRegexp        = regexp.MustCompile(`(?s)<!--.*?-->`)
+	selfClosingRefRegexp = regexp.MustCompile(`(?i)<ref[^>]*/>`)
+	refRegexp            = regexp.MustCompile(`(?is)<ref[^>]*>.*?</ref\s*>`)
+	externalLinkRegexp   = regexp.MustCompile(`\[(?:https?:)?//[^\s\]]+(?:\s+([^\]]*))?\]`)
+	headingRegexp        = regexp.MustCompile(`^(={1,6})\s*(.+?)\s*(={1,6})$`)
+	listRegexp           = regexp.MustCompile(`^([*#:;]+)\s*(.*)$`)
+	boldItalicRegexp     = regexp.MustCompile(`'''''(.+?)'''''`)
+	boldRegexp           = regexp.MustCompile(`'''(.+?)'''`)
+	italicRegexp         = regexp.MustCompile(`''(.+?)''`)
+	brRegexp             = regexp.MustCompile(`(?i)<br\s*/?>`)
+	tagRegexp            = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	magicWordRegexp      = regexp.MustCompile(`__[A-Z]+__`)
+	emptyParensRegexp    = regexp.MustCompile(`\(\s*[,;]?\s*\)`)
+	spacesRegexp         = regexp.MustCompile(` {2,}`)
+)
+
+// droppedTagRegexps match elements that are removed with their content, because they don't render to useful text.
+var droppedTagRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)<gallery[^>]*>.*?</gallery\s*>`),
+	regexp.MustCompile(`(?is)<imagemap[^>]*>.*?</imagemap\s*>`),
+	regexp.MustCompile(`(?is)<math[^>]*>.*?</math\s*>`),
+	regexp.MustCompile(`(?is)<score[^>]*>.*?</score\s*>`),
+	regexp.MustCompile(`(?is)<timeline[^>]*>.*?</timeline\s*>`),
+}
+
+// droppedSections are removed with everything under them, because they are mostly lists of links and sources.
+var droppedSections = map[string]bool{
+	"bibliography":    true,
+	"citations":       true,
+	"external links":  true,
+	"footnotes":       true,
+	"further reading": true,
+	"notes":           true,
+	"references":      true,
+	"see also":        true,
+	"sources":         true,
+}
+
+// Markdown converts the wikitext to Markdown.
+// Headings, bold and italic text, lists, and simple tables are converted, and internal and external links are
+// replaced by their link text. Infoboxes are summarized as a list of their fields, and a few inline templates
+// like {{lang}} and {{convert}} are kept as text. All other templates, references, comments, files, and categories
+// are removed, as are sections like "References" and "External links".
+func Markdown(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = commentRegexp.ReplaceAllString(text, "")
+	text = selfClosingRefRegexp.ReplaceAllString(text, "")
+	text = refRegexp.ReplaceAllString(text, "")
+	for _, r := range droppedTagRegexps {
+		text = r.ReplaceAllString(text, "")
+	}
+	text = templates(text)
+	text = links(text)
+	text = externalLinkRegexp.ReplaceAllString(text, "$1")
+	text = tables(text)
+	text = blocks(text)
+	return collapseBlankLines(text)
+}
+
+// blocks converts headings and lists line by line, and inline markup in all lines.
+func blocks(text string) string {
+	var b strings.Builder
+
+	// When droppedLevel is set, lines are dropped until the next heading of the same or a higher level
+	var droppedLevel int
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		if m := headingRegexp.FindStringSubmatch(line); m != nil {
+			level := min(len(m[1]), len(m[3]))
+			if droppedLevel > 0 && level > droppedLevel {
+				continue
+			}
+			droppedLevel = 0
+
+			title := inline(m[2])
+			if droppedSections[strings.ToLower(title)] {
+				droppedLevel = level
+				continue
+			}
+
+			b.WriteString("\n" + strings.Repeat("#", level) + " " + title + "\n\n")
+			continue
+		}
+
+		if droppedLevel > 0 || strings.HasPrefix(line, "----") {
+			continue
+		}
+
+		if m := listRegexp.FindStringSubmatch(line); m != nil {
+			line = listItem(m[1], inline(m[2]))
+		} else {
+			line = inline(line)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return b.String()
+}
+
+// listItem from the wikitext list markers, where "*" is a bullet, "#" is numbered, ";" is a definition term,
+// and ":" is indentation or a definition.
+func listItem(markers, text string) string {
+	if text == "" {
+		return ""
+	}
+
+	indent := strings.Repeat("  ", len(markers)-1)
+	switch markers[len(markers)-1] {
+	case '*':
+		return indent + "- " + text
+	case '#':
+		return indent + "1. " + text
+	case ';':
+		return indent + "**" + text + "**"
+	default:
+		return indent + text
+	}
+}
+
+// inline converts bold and italic text, and removes HTML tags and magic words like __NOTOC__.
+func inline(s string) string {
+	s = boldItalicRegexp.ReplaceAllString(s, "***$1***")
+	s = boldRegexp.ReplaceAllString(s, "**$1**")
+	s = italicRegexp.ReplaceAllString(s, "*$1*")
+	s = brRegexp.ReplaceAllString(s, " ")
+	s = tagRegexp.ReplaceAllString(s, "")
+	s = magicWordRegexp.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	// Removed templates often leave empty parentheses behind, like in "Sheep ({{IPA|ʃiːp}})"
+	s = emptyParensRegexp.ReplaceAllString(s, "")
+	s = spacesRegexp.ReplaceAllString(s, " ")
+	return strings.TrimSpace(s)
+}
+
+// removedNamespaces are link namespaces for which the whole link is removed.
+var removedNamespaces = map[string]bool{
+	"category": true,
+	"file":     true,
+	"image":    true,
+	"media":    true,
+}
+
+// links replaces internal links with their link text, and removes files and categories.
+// An opening "[[" without a matching "]]" is removed, so links after it are still replaced.
+func links(s string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "[[")
+		if start < 0 {
+			break
+		}
+		end := matching(s[start:], "[[", "]]")
+		if end < 0 {
+			b.WriteString(s[:start])
+			s = s[start+2:]
+			continue
+		}
+
+		b.WriteString(s[:start])
+		b.WriteString(linkText(s[start+2 : start+end-2]))
+		s = s[start+end:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+var parenthesesSuffixRegexp = regexp.MustCompile(`\s*\([^)]*\)$`)
+
+func linkText(link string) string {
+	target, label, hasLabel := strings.Cut(link, "|")
+	target = strings.TrimSpace(target)
+
+	if namespace, _, ok := strings.Cut(target, ":"); ok && removedNamespaces[strings.ToLower(strings.TrimSpace(namespace))] {
+		return ""
+	}
+
+	if hasLabel {
+		if label = strings.TrimSpace(label); label != "" {
+			return label
+		}
+		// The "pipe trick", where [[Sheep (animal)|]] is shown as "Sheep"
+		return parenthesesSuffixRegexp.ReplaceAllString(target, "")
+	}
+
+	return strings.TrimPrefix(target, ":")
+}
+
+// matching returns the index just after the close delimiter that matches the open delimiter at the start of s,
+// or -1 if there is none.
+func matching(s, open, close string) int {
+	var depth int
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], open):
+			depth++
+			i += len(open)
+		case strings.HasPrefix(s[i:], close):
+			depth--
+			i += len(close)
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// collapseBlankLines so there's at most one blank line between blocks of text.
+func collapseBlankLines(s string) string {
+	var b strings.Builder
+	var blank bool
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if !blank {
+				b.WriteString("\n")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return strings.TrimSpace(b.String())
+}
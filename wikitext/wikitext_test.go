@@ -0,0 +1,42 @@
+package wikitext_test
+
+import (
+	"testing"
+
+	"maragu.dev/is"
+
+	"app/wikitext"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "converts headings", text: "== History ==\nText\n=== Early ===\nMore", expected: "## History\n\nText\n\n### Early\n\nMore"},
+		{name: "converts bold and italic", text: "'''Sheep''' are ''fluffy'' and '''''woolly'''''.", expected: "**Sheep** are *fluffy* and ***woolly***."},
+		{name: "replaces internal links with their text", text: "[[Sheep]], [[Domestic sheep|sheep]], [[Goat (animal)|]], and [[lamb]]s", expected: "Sheep, sheep, Goat, and lambs"},
+		{name: "replaces external links with their label", text: "See [https://example.com the site] or [https://example.com].", expected: "See the site or ."},
+		{name: "removes files and categories", text: "[[File:Sheep.jpg|thumb|A [[sheep]] grazing]]Sheep graze.\n[[Category:Animals]]", expected: "Sheep graze."},
+		{name: "removes references and comments", text: `Sheep<ref name="a">Smith, 2020.</ref> graze<ref name="a" />.<!-- TODO -->`, expected: "Sheep graze."},
+		{name: "removes templates and leftover parentheses", text: "{{Short description|Animal}}'''Sheep''' ({{IPA|ʃiːp}}) are {{cite web|url=x}}animals.", expected: "**Sheep** are animals."},
+		{name: "keeps text from inline templates", text: "{{lang|fr|Mouton}} weigh {{convert|100|kg}}.", expected: "Mouton weigh 100 kg."},
+		{name: "summarizes infoboxes as lists", text: "{{Infobox animal\n| name = Sheep\n| image = Sheep.jpg\n| legs = 4\n| birth_date = {{birth date|2000|1|1}}\n| habitat = [[Grassland]]s\n}}\nSheep are animals.", expected: "- Name: Sheep\n- Legs: 4\n- Habitat: Grasslands\n\nSheep are animals."},
+		{name: "converts lists", text: "* Wool\n** Fine wool\n# First\n# Second\n; Term\n: Definition", expected: "- Wool\n  - Fine wool\n1. First\n1. Second\n**Term**\nDefinition"},
+		{name: "converts simple tables", text: "{| class=\"wikitable\"\n|+ Breeds\n! Name !! Origin\n|-\n| Merino || Spain\n|-\n| style=\"color: red\" | Suffolk\n| England\n|}", expected: "Breeds\n\n| Name | Origin |\n| --- | --- |\n| Merino | Spain |\n| Suffolk | England |"},
+		{name: "removes nested tables", text: "{|\n! A\n|-\n| B\n{|\n| Nested\n|}\n|}", expected: "| A |\n| --- |\n| B |"},
+		{name: "removes reference and link sections", text: "Text\n== See also ==\n* [[Goat]]\n=== More ===\nStill see also\n== References ==\n{{Reflist}}\n== Biology ==\nMore text", expected: "Text\n\n## Biology\n\nMore text"},
+		{name: "keeps converting templates after an unclosed template", text: "Sheep {{cite web|url=x graze.\nThey {{cite web|url=y}}eat grass.", expected: "Sheep cite web|url=x graze.\nThey eat grass."},
+		{name: "keeps converting links after an unclosed link", text: "Sheep [[graze.\nThey eat [[grass]].[[File:Sheep.jpg|thumb]]\n[[Category:Animals]]", expected: "Sheep graze.\nThey eat grass."},
+		{name: "ends an unclosed table at the next blank line or heading", text: "{|\n! A\n|-\n| B\n\nSheep graze.\n== Diet ==\nGrass", expected: "| A |\n| --- |\n| B |\n\nSheep graze.\n\n## Diet\n\nGrass"},
+		{name: "ends an unclosed table at the next heading", text: "{|\n! A\n== Diet ==\nGrass", expected: "| A |\n| --- |\n\n## Diet\n\nGrass"},
+		{name: "unescapes entities and removes tags and magic words", text: "__NOTOC__\nSheep&nbsp;&amp; <small>goats</small><br />graze.", expected: "Sheep & goats graze."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is.Equal(t, test.expected, wikitext.Markdown(test.text))
+		})
+	}
+}